@@ -0,0 +1,341 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/rest-api/cron"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultJWTSecret is only acceptable outside of production
+const DefaultJWTSecret = "supersecret"
+
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite"
+
+	defaultMySQLDSN  = "root:@tcp(127.0.0.1:3306)/go_events?parseTime=true"
+	defaultSQLiteDSN = "file:go_events.db?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvTest        = "test"
+	EnvProduction  = "production"
+)
+
+const (
+	MailDriverNone = "none"
+	MailDriverSMTP = "smtp"
+	MailDriverFile = "file"
+)
+
+// Config holds every setting the application reads at startup
+type Config struct {
+	Env      string         `yaml:"env"`
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	JWT      JWTConfig      `yaml:"jwt"`
+	Jobs     JobsConfig     `yaml:"jobs"`
+	Mail     MailConfig     `yaml:"mail"`
+}
+
+type ServerConfig struct {
+	Port            string        `yaml:"port"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // how long in-flight requests and jobs may take to finish
+	RequestTimeout  time.Duration `yaml:"request_timeout"`  // deadline of each request except streams; 0 for none
+}
+
+type DatabaseConfig struct {
+	Driver       string `yaml:"driver"` // "mysql" or "sqlite"
+	DSN          string `yaml:"dsn"`
+	MaxOpenConns int    `yaml:"max_open_conns"`
+	MaxIdleConns int    `yaml:"max_idle_conns"`
+}
+
+type JWTConfig struct {
+	Secret     string        `yaml:"secret"`
+	TTL        time.Duration `yaml:"ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl"`
+}
+
+type JobsConfig struct {
+	NotificationInterval time.Duration `yaml:"notification_interval"`
+	DeliveryMaxAttempts  int           `yaml:"delivery_max_attempts"` // before a delivery is marked failed
+	WebhookInterval      time.Duration `yaml:"webhook_interval"`      // how often the webhook outbox is sent
+	WebhookTimeout       time.Duration `yaml:"webhook_timeout"`       // per request to a webhook endpoint
+	PollInterval         time.Duration `yaml:"poll_interval"`         // how often a worker looks for due jobs
+	Lease                time.Duration `yaml:"lease"`                 // how long a claimed job is hidden from other workers
+	RunTimeout           time.Duration `yaml:"run_timeout"`           // how long one run of a job may take
+	MaxAttempts          int           `yaml:"max_attempts"`          // before a job is marked dead
+	Retention            time.Duration `yaml:"retention"`             // how long finished jobs are kept
+
+	// Schedules override the schedule of recurring jobs by name, as cron
+	// expressions or "@every <duration>"
+	Schedules map[string]string `yaml:"schedules"`
+}
+
+// MailConfig selects how notification emails are sent. The file driver writes
+// each message as a .eml file into Dir instead, for development.
+type MailConfig struct {
+	Driver       string `yaml:"driver"` // "none", "smtp" or "file"
+	From         string `yaml:"from"`
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+	Dir          string `yaml:"dir"`
+}
+
+// Default returns the configuration used for local development
+func Default() *Config {
+	return &Config{
+		Env: EnvDevelopment,
+		Server: ServerConfig{
+			Port:            "8080",
+			ShutdownTimeout: 30 * time.Second,
+			RequestTimeout:  30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Driver:       DriverMySQL,
+			DSN:          defaultMySQLDSN,
+			MaxOpenConns: 10,
+			MaxIdleConns: 5,
+		},
+		JWT: JWTConfig{
+			Secret:     DefaultJWTSecret,
+			TTL:        2 * time.Hour,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		Jobs: JobsConfig{
+			NotificationInterval: time.Hour,
+			DeliveryMaxAttempts:  5,
+			WebhookInterval:      15 * time.Second,
+			WebhookTimeout:       10 * time.Second,
+			PollInterval:         5 * time.Second,
+			Lease:                10 * time.Minute,
+			RunTimeout:           5 * time.Minute,
+			MaxAttempts:          5,
+			Retention:            7 * 24 * time.Hour,
+		},
+		Mail: MailConfig{
+			Driver:   MailDriverNone,
+			From:     "Go Events <no-reply@localhost>",
+			SMTPPort: 587,
+			Dir:      "mail",
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, then the YAML file named by
+// CONFIG_FILE (if any), then environment variables, and validates the result
+func Load() (*Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	// Switching the driver without a DSN points at a local SQLite file
+	// rather than handing the MySQL default to the SQLite driver
+	if cfg.Database.Driver == DriverSQLite && cfg.Database.DSN == defaultMySQLDSN {
+		cfg.Database.DSN = defaultSQLiteDSN
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(content, c); err != nil {
+		return fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	if value, ok := lookup("APP_ENV"); ok {
+		c.Env = value
+	}
+	if value, ok := lookup("PORT"); ok {
+		c.Server.Port = value
+	}
+	if value, ok := lookup("DB_DRIVER"); ok {
+		c.Database.Driver = value
+	}
+	if value, ok := lookup("DB_DSN"); ok {
+		c.Database.DSN = value
+	}
+	if value, ok := lookup("JWT_SECRET"); ok {
+		c.JWT.Secret = value
+	}
+
+	texts := map[string]*string{
+		"MAIL_DRIVER":   &c.Mail.Driver,
+		"MAIL_FROM":     &c.Mail.From,
+		"MAIL_DIR":      &c.Mail.Dir,
+		"SMTP_HOST":     &c.Mail.SMTPHost,
+		"SMTP_USERNAME": &c.Mail.SMTPUsername,
+		"SMTP_PASSWORD": &c.Mail.SMTPPassword,
+	}
+	for name, target := range texts {
+		if value, ok := lookup(name); ok {
+			*target = value
+		}
+	}
+
+	ints := map[string]*int{
+		"DB_MAX_OPEN_CONNS":     &c.Database.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS":     &c.Database.MaxIdleConns,
+		"SMTP_PORT":             &c.Mail.SMTPPort,
+		"DELIVERY_MAX_ATTEMPTS": &c.Jobs.DeliveryMaxAttempts,
+		"JOB_MAX_ATTEMPTS":      &c.Jobs.MaxAttempts,
+	}
+	for name, target := range ints {
+		if value, ok := lookup(name); ok {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s must be an integer: %w", name, err)
+			}
+			*target = parsed
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"JWT_TTL":               &c.JWT.TTL,
+		"JWT_REFRESH_TTL":       &c.JWT.RefreshTTL,
+		"NOTIFICATION_INTERVAL": &c.Jobs.NotificationInterval,
+		"WEBHOOK_INTERVAL":      &c.Jobs.WebhookInterval,
+		"WEBHOOK_TIMEOUT":       &c.Jobs.WebhookTimeout,
+		"JOB_POLL_INTERVAL":     &c.Jobs.PollInterval,
+		"JOB_LEASE":             &c.Jobs.Lease,
+		"JOB_RUN_TIMEOUT":       &c.Jobs.RunTimeout,
+		"JOB_RETENTION":         &c.Jobs.Retention,
+		"SHUTDOWN_TIMEOUT":      &c.Server.ShutdownTimeout,
+		"REQUEST_TIMEOUT":       &c.Server.RequestTimeout,
+	}
+	for name, target := range durations {
+		if value, ok := lookup(name); ok {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s must be a duration such as 30m or 2h: %w", name, err)
+			}
+			*target = parsed
+		}
+	}
+
+	return nil
+}
+
+// Validate reports every missing or invalid setting at once
+func (c *Config) Validate() error {
+	var problems []string
+
+	switch c.Env {
+	case EnvDevelopment, EnvTest, EnvProduction:
+	default:
+		problems = append(problems, fmt.Sprintf("env must be one of development, test or production, got %q", c.Env))
+	}
+
+	if c.Server.Port == "" {
+		problems = append(problems, "server port is required")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		problems = append(problems, "server shutdown timeout must be positive")
+	}
+	if c.Server.RequestTimeout < 0 {
+		problems = append(problems, "server request timeout cannot be negative")
+	}
+	if c.Database.Driver != DriverMySQL && c.Database.Driver != DriverSQLite {
+		problems = append(problems, fmt.Sprintf("database driver must be mysql or sqlite, got %q", c.Database.Driver))
+	}
+	if c.Database.DSN == "" {
+		problems = append(problems, "database dsn is required")
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		problems = append(problems, "database connection limits cannot be negative")
+	}
+	if c.JWT.Secret == "" {
+		problems = append(problems, "jwt secret is required")
+	}
+	if c.Env == EnvProduction && c.JWT.Secret == DefaultJWTSecret {
+		problems = append(problems, "jwt secret must be changed from the default in production")
+	}
+	if c.JWT.TTL <= 0 {
+		problems = append(problems, "jwt ttl must be positive")
+	}
+	if c.JWT.RefreshTTL <= c.JWT.TTL {
+		problems = append(problems, "jwt refresh ttl must be longer than the access token ttl")
+	}
+	if c.Jobs.NotificationInterval <= 0 {
+		problems = append(problems, "notification interval must be positive")
+	}
+	if c.Jobs.WebhookInterval <= 0 || c.Jobs.WebhookTimeout <= 0 {
+		problems = append(problems, "webhook interval and timeout must be positive")
+	}
+
+	switch c.Mail.Driver {
+	case MailDriverNone:
+	case MailDriverSMTP:
+		if c.Mail.SMTPHost == "" || c.Mail.SMTPPort <= 0 {
+			problems = append(problems, "mail smtp host and port are required for the smtp driver")
+		}
+	case MailDriverFile:
+		if c.Mail.Dir == "" {
+			problems = append(problems, "mail dir is required for the file driver")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("mail driver must be one of none, smtp or file, got %q", c.Mail.Driver))
+	}
+	if c.Mail.Driver != MailDriverNone && c.Mail.From == "" {
+		problems = append(problems, "mail from address is required")
+	}
+	if c.Jobs.DeliveryMaxAttempts < 1 {
+		problems = append(problems, "delivery max attempts must be at least 1")
+	}
+	if c.Jobs.PollInterval <= 0 || c.Jobs.Lease <= 0 || c.Jobs.Retention <= 0 {
+		problems = append(problems, "job poll interval, lease and retention must be positive")
+	}
+	if c.Jobs.MaxAttempts < 1 {
+		problems = append(problems, "job max attempts must be at least 1")
+	}
+	if c.Jobs.RunTimeout <= 0 || c.Jobs.RunTimeout >= c.Jobs.Lease {
+		problems = append(problems, "job run timeout must be positive and shorter than the lease")
+	}
+	for name, spec := range c.Jobs.Schedules {
+		if _, err := cron.Parse(spec); err != nil {
+			problems = append(problems, fmt.Sprintf("job schedule %s: %v", name, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// Addr is the listen address for the HTTP server
+func (c *Config) Addr() string {
+	return ":" + strings.TrimPrefix(c.Server.Port, ":")
+}
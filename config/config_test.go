@@ -0,0 +1,179 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefault_IsValid(t *testing.T) {
+	assert.NoError(t, Default().Validate())
+}
+
+func TestLoad_FileAndEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+env: production
+server:
+  port: "9090"
+database:
+  dsn: "app:secret@tcp(db:3306)/go_events?parseTime=true"
+jwt:
+  secret: from-file
+  ttl: 30m
+jobs:
+  notification_interval: 15m
+`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("JWT_SECRET", "from-env")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+
+	assert.Equal(t, EnvProduction, cfg.Env)
+	assert.Equal(t, ":9090", cfg.Addr())
+	assert.Equal(t, "app:secret@tcp(db:3306)/go_events?parseTime=true", cfg.Database.DSN)
+	assert.Equal(t, "from-env", cfg.JWT.Secret)
+	assert.Equal(t, 30*time.Minute, cfg.JWT.TTL)
+	assert.Equal(t, 15*time.Minute, cfg.Jobs.NotificationInterval)
+	// Values not present in the file keep their defaults
+	assert.Equal(t, 10, cfg.Database.MaxOpenConns)
+}
+
+func TestLoadEnv(t *testing.T) {
+	env := map[string]string{
+		"APP_ENV":               "test",
+		"PORT":                  "3000",
+		"DB_DSN":                "dsn",
+		"DB_MAX_OPEN_CONNS":     "20",
+		"NOTIFICATION_INTERVAL": "5m",
+		"MAIL_DRIVER":           "smtp",
+		"SMTP_HOST":             "smtp.example.com",
+		"SMTP_PORT":             "2525",
+		"WEBHOOK_INTERVAL":      "1m",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	cfg := Default()
+	assert.NoError(t, cfg.loadEnv(lookup))
+
+	assert.Equal(t, EnvTest, cfg.Env)
+	assert.Equal(t, "3000", cfg.Server.Port)
+	assert.Equal(t, "dsn", cfg.Database.DSN)
+	assert.Equal(t, 20, cfg.Database.MaxOpenConns)
+	assert.Equal(t, 5*time.Minute, cfg.Jobs.NotificationInterval)
+	assert.Equal(t, "smtp", cfg.Mail.Driver)
+	assert.Equal(t, "smtp.example.com", cfg.Mail.SMTPHost)
+	assert.Equal(t, 2525, cfg.Mail.SMTPPort)
+	assert.Equal(t, time.Minute, cfg.Jobs.WebhookInterval)
+}
+
+func TestLoadEnv_InvalidValues(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{name: "Invalid integer", env: map[string]string{"DB_MAX_OPEN_CONNS": "many"}},
+		{name: "Invalid duration", env: map[string]string{"JWT_TTL": "two hours"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup := func(name string) (string, bool) {
+				value, ok := tt.env[name]
+				return value, ok
+			}
+
+			assert.Error(t, Default().loadEnv(lookup))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(cfg *Config)
+		wantErr string
+	}{
+		{
+			name:    "Default secret in production",
+			modify:  func(cfg *Config) { cfg.Env = EnvProduction },
+			wantErr: "must be changed from the default in production",
+		},
+		{
+			name:    "Missing DSN",
+			modify:  func(cfg *Config) { cfg.Database.DSN = "" },
+			wantErr: "database dsn is required",
+		},
+		{
+			name:    "Unknown environment",
+			modify:  func(cfg *Config) { cfg.Env = "staging" },
+			wantErr: "env must be one of",
+		},
+		{
+			name:    "Zero shutdown timeout",
+			modify:  func(cfg *Config) { cfg.Server.ShutdownTimeout = 0 },
+			wantErr: "server shutdown timeout must be positive",
+		},
+		{
+			name:    "Negative request timeout",
+			modify:  func(cfg *Config) { cfg.Server.RequestTimeout = -time.Second },
+			wantErr: "server request timeout cannot be negative",
+		},
+		{
+			name:    "Job run timeout outlasting the lease",
+			modify:  func(cfg *Config) { cfg.Jobs.RunTimeout = cfg.Jobs.Lease },
+			wantErr: "job run timeout must be positive and shorter than the lease",
+		},
+		{
+			name:    "Zero notification interval",
+			modify:  func(cfg *Config) { cfg.Jobs.NotificationInterval = 0 },
+			wantErr: "notification interval must be positive",
+		},
+		{
+			name:    "Zero webhook timeout",
+			modify:  func(cfg *Config) { cfg.Jobs.WebhookTimeout = 0 },
+			wantErr: "webhook interval and timeout must be positive",
+		},
+		{
+			name:    "SMTP without host",
+			modify:  func(cfg *Config) { cfg.Mail.Driver = MailDriverSMTP },
+			wantErr: "mail smtp host and port are required",
+		},
+		{
+			name:    "Unknown mail driver",
+			modify:  func(cfg *Config) { cfg.Mail.Driver = "pigeon" },
+			wantErr: "mail driver must be one of",
+		},
+		{
+			name: "Production with custom secret",
+			modify: func(cfg *Config) {
+				cfg.Env = EnvProduction
+				cfg.JWT.Secret = "a-long-random-secret"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
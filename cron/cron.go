@@ -0,0 +1,213 @@
+// Package cron parses job schedules: five-field cron expressions (minute,
+// hour, day of month, month and day of week) with lists, ranges and steps,
+// the @hourly, @daily, @weekly and @monthly shorthands, and "@every <duration>"
+// for fixed intervals.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSpec = errors.New("invalid schedule")
+
+// maxSearch bounds how far ahead Next looks for a matching minute, for
+// expressions such as February 30th that never match
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+var shorthands = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// Schedule reports when a job next runs
+type Schedule interface {
+	// Next returns the first run strictly after t
+	Next(t time.Time) time.Time
+}
+
+// Every runs at fixed multiples of an interval (as time.Truncate counts
+// them), so replicas agree on the run times
+type Every time.Duration
+
+func (e Every) Next(t time.Time) time.Time {
+	return t.Truncate(time.Duration(e)).Add(time.Duration(e))
+}
+
+// Expression is a parsed five-field cron expression, evaluated in the
+// location of the time passed to Next
+type Expression struct {
+	minutes, hours, days, months, weekdays uint64 // bit sets of allowed values
+
+	// Like Vixie cron, a restricted day of month and day of week match when
+	// either does
+	anyDay, anyWeekday bool
+}
+
+type field struct {
+	min, max int
+}
+
+var fields = []field{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// Parse parses a schedule
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if interval, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(interval))
+
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("%w %q: @every needs a duration of at least 1s", ErrInvalidSpec, spec)
+		}
+
+		return Every(d), nil
+	}
+
+	if expanded, ok := shorthands[spec]; ok {
+		spec = expanded
+	}
+
+	parts := strings.Fields(spec)
+
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("%w %q: expected 5 fields", ErrInvalidSpec, spec)
+	}
+
+	sets := make([]uint64, len(fields))
+
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidSpec, spec, err)
+		}
+
+		sets[i] = set
+	}
+
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return &Expression{
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     parts[2] == "*",
+		anyWeekday: parts[4] == "*",
+	}, nil
+}
+
+// parseField parses a comma separated list of *, n, a-b, each optionally
+// followed by /step
+func parseField(part string, f field) (uint64, error) {
+	var set uint64
+
+	for _, item := range strings.Split(part, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		low, high := f.min, f.max
+
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+
+			if low, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("%q is not a number", from)
+			}
+
+			high = low
+
+			if isRange {
+				if high, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("%q is not a number", to)
+				}
+			} else if hasStep {
+				high = f.max
+			}
+		}
+
+		if low < f.min || high > f.max || low > high {
+			return 0, fmt.Errorf("%q is outside %d-%d", item, f.min, f.max)
+		}
+
+		step := 1
+
+		if hasStep {
+			parsed, err := strconv.Atoi(stepPart)
+
+			if err != nil || parsed < 1 {
+				return 0, fmt.Errorf("step %q must be a positive number", stepPart)
+			}
+
+			step = parsed
+		}
+
+		for v := low; v <= high; v += step {
+			set |= 1 << v
+		}
+	}
+
+	return set, nil
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<v) != 0
+}
+
+func (e *Expression) dayMatches(t time.Time) bool {
+	day, weekday := has(e.days, t.Day()), has(e.weekdays, int(t.Weekday()))
+
+	switch {
+	case e.anyDay && e.anyWeekday:
+		return true
+	case e.anyDay:
+		return weekday
+	case e.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// Next returns the first matching minute after t, or the zero time if none
+// comes within five years
+func (e *Expression) Next(t time.Time) time.Time {
+	loc := t.Location()
+	limit := t.Add(maxSearch)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		if !has(e.months, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !e.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !has(e.hours, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if !has(e.minutes, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
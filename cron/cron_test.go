@@ -0,0 +1,49 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse_Next(t *testing.T) {
+	from := time.Date(2030, 1, 7, 10, 17, 30, 0, time.UTC) // a Monday
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2030, 1, 7, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2030, 1, 7, 10, 30, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2030, 1, 7, 11, 0, 0, 0, time.UTC)},
+		{"30 3 * * *", time.Date(2030, 1, 8, 3, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2030, 1, 7, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2030, 1, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 3 *", time.Date(2030, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2032, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either the 15th or a Friday
+		{"0 0 15 * 5", time.Date(2030, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"@every 1h", time.Date(2030, 1, 7, 11, 0, 0, 0, time.UTC)},
+		{"@every 15s", time.Date(2030, 1, 7, 10, 17, 45, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		schedule, err := Parse(tt.spec)
+		assert.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.want, schedule.Next(from), tt.spec)
+	}
+}
+
+func TestParse_NeverMatches(t *testing.T) {
+	schedule, err := Parse("0 0 30 2 *")
+	assert.NoError(t, err)
+	assert.True(t, schedule.Next(time.Now()).IsZero())
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *", "@every", "@every 10ms", "@yearly"} {
+		_, err := Parse(spec)
+		assert.ErrorIs(t, err, ErrInvalidSpec, spec)
+	}
+}
@@ -5,101 +5,57 @@ import (
 	"fmt"
 	"log"
 
-	_ "github.com/go-sql-driver/mysql"
+	"example.com/rest-api/config"
+	"example.com/rest-api/migrations"
 )
 
 var DB *sql.DB
 
-func InitDB() {
-	dsn := "root:@tcp(127.0.0.1:3306)/go_events?parseTime=true"
-	var err error
+// InitDB connects to the database and applies any pending schema migrations
+func InitDB(cfg config.DatabaseConfig) {
+	Connect(cfg)
 
-	DB, err = sql.Open("mysql", dsn)
+	migrator, err := migrations.New(DB, Dialect.Name())
 	if err != nil {
-		log.Fatal("Error opening DB connection:", err)
+		log.Fatal("Error loading migrations:", err)
 	}
 
-	if err = DB.Ping(); err != nil {
-		log.Fatal("Error connecting to the database:", err)
+	if err = migrator.Up(); err != nil {
+		log.Fatal("Error applying migrations:", err)
 	}
-
-	fmt.Println("Database connected!")
-
-	DB.SetMaxOpenConns(10)
-	DB.SetMaxIdleConns(5)
-
-	createTables()
 }
 
-func createTables() {
-	createUsersTable := `
-		CREATE TABLE IF NOT EXISTS users (
-    id INT AUTO_INCREMENT PRIMARY KEY,
-    email VARCHAR(255) NOT NULL UNIQUE,
-    password VARCHAR(255) NOT NULL
-);
-	`
-
-	_, err := DB.Exec(createUsersTable)
+// Connect opens the connection pool for the configured driver without touching the schema
+func Connect(cfg config.DatabaseConfig) {
+	var err error
 
+	Dialect, err = DialectFor(cfg.Driver)
 	if err != nil {
-		panic("Could not create users table")
+		log.Fatal(err)
 	}
 
-	createEventTables := `
-		CREATE TABLE IF NOT EXISTS events (
-    id INT AUTO_INCREMENT PRIMARY KEY,
-    name VARCHAR(255) NOT NULL,
-    description TEXT NOT NULL,
-    location VARCHAR(255) NOT NULL,
-    dateTime DATETIME NOT NULL,
-    user_id INT,
-    FOREIGN KEY (user_id) REFERENCES users(id)
-);
-
-	`
-
-	_, err = DB.Exec(createEventTables)
-
-	if err != nil {
-		panic("Could not create event tables")
+	dsn := cfg.DSN
+	if Dialect == SQLite {
+		dsn = SQLiteDSN(dsn)
 	}
 
-	createRegistrationTables := `
-		CREATE TABLE IF NOT EXISTS events_registry (
-    id INT AUTO_INCREMENT PRIMARY KEY,
-    event_id INT,
-    user_id INT,
-    FOREIGN KEY (event_id) REFERENCES events(id),
-    FOREIGN KEY (user_id) REFERENCES users(id)
-);
-
-	`
-
-	_, err = DB.Exec(createRegistrationTables)
-
+	DB, err = sql.Open(Dialect.DriverName(), dsn)
 	if err != nil {
-		panic("Could not create registrations tables")
+		log.Fatal("Error opening DB connection:", err)
 	}
 
-	createNotificationsTable := `
-		CREATE TABLE IF NOT EXISTS notifications (
-    id INT AUTO_INCREMENT PRIMARY KEY,
-    user_id INT NOT NULL,
-    event_id INT NOT NULL,
-    message TEXT NOT NULL,
-    type VARCHAR(50) NOT NULL,
-    is_read BOOLEAN DEFAULT FALSE,
-    created_at DATETIME NOT NULL,
-    FOREIGN KEY (user_id) REFERENCES users(id),
-    FOREIGN KEY (event_id) REFERENCES events(id)
-);
+	if err = DB.Ping(); err != nil {
+		log.Fatal("Error connecting to the database:", err)
+	}
 
-	`
+	fmt.Printf("Database connected! (%s)\n", Dialect.Name())
 
-	_, err = DB.Exec(createNotificationsTable)
+	DB.SetMaxOpenConns(cfg.MaxOpenConns)
+	DB.SetMaxIdleConns(cfg.MaxIdleConns)
 
-	if err != nil {
-		panic("Could not create notifications table")
+	// SQLite allows a single writer and gives every connection to ":memory:"
+	// its own database, so all access goes through one connection
+	if Dialect == SQLite {
+		DB.SetMaxOpenConns(1)
 	}
 }
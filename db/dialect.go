@@ -0,0 +1,51 @@
+package db
+
+import "fmt"
+
+// SQLDialect isolates the SQL that differs between the supported backends.
+// Everything else in the models is written in the portable subset shared by
+// MySQL and SQLite.
+type SQLDialect interface {
+	// Name identifies the backend and selects its migrations
+	Name() string
+	// DriverName is the database/sql driver to open
+	DriverName() string
+	// Now is the current timestamp
+	Now() string
+	// HoursFromNow is the timestamp the given number of hours from now
+	HoursFromNow(hours int) string
+	// Today is the current date
+	Today() string
+	// Date truncates a timestamp expression to its date
+	Date(expr string) string
+	// Timestamp normalizes a stored DATETIME column for comparisons
+	Timestamp(expr string) string
+	// ForUpdate is appended to a SELECT inside a transaction to lock the rows it reads
+	ForUpdate() string
+	// IsUniqueViolation reports whether err was caused by a UNIQUE or PRIMARY KEY constraint
+	IsUniqueViolation(err error) bool
+	// Search builds a relevance expression and a WHERE condition for a
+	// free-text query against the columns, most important column first
+	Search(text string, terms []string, columns ...string) (score SQLFragment, condition SQLFragment)
+}
+
+// SQLFragment is a piece of SQL together with the arguments for its placeholders
+type SQLFragment struct {
+	SQL  string
+	Args []any
+}
+
+// Dialect is the dialect of the open connection, MySQL unless configured otherwise
+var Dialect SQLDialect = MySQL
+
+// DialectFor returns the dialect for a configured driver name
+func DialectFor(driver string) (SQLDialect, error) {
+	switch driver {
+	case "", MySQL.Name():
+		return MySQL, nil
+	case SQLite.Name():
+		return SQLite, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
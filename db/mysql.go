@@ -0,0 +1,51 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// erDupEntry is MySQL's "Duplicate entry for key" error number
+const erDupEntry = 1062
+
+// MySQL is the production backend
+var MySQL SQLDialect = mysqlDialect{}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string       { return "mysql" }
+func (mysqlDialect) DriverName() string { return "mysql" }
+func (mysqlDialect) Now() string        { return "NOW()" }
+func (mysqlDialect) Today() string      { return "CURDATE()" }
+
+func (mysqlDialect) HoursFromNow(hours int) string {
+	return fmt.Sprintf("DATE_ADD(NOW(), INTERVAL %d HOUR)", hours)
+}
+
+func (mysqlDialect) Date(expr string) string {
+	return fmt.Sprintf("DATE(%s)", expr)
+}
+
+func (mysqlDialect) Timestamp(expr string) string {
+	return expr
+}
+
+func (mysqlDialect) ForUpdate() string {
+	return " FOR UPDATE"
+}
+
+func (mysqlDialect) IsUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == erDupEntry
+}
+
+// Search uses the FULLTEXT index over the columns in natural language mode,
+// which ranks by term frequency and rarity
+func (mysqlDialect) Search(text string, terms []string, columns ...string) (SQLFragment, SQLFragment) {
+	match := fmt.Sprintf("MATCH(%s) AGAINST (? IN NATURAL LANGUAGE MODE)", strings.Join(columns, ", "))
+
+	return SQLFragment{SQL: match, Args: []any{text}}, SQLFragment{SQL: match, Args: []any{text}}
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLite is a pure-Go embedded backend for development and CI
+var SQLite SQLDialect = sqliteDialect{}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string       { return "sqlite" }
+func (sqliteDialect) DriverName() string { return "sqlite" }
+func (sqliteDialect) Now() string        { return "datetime('now')" }
+func (sqliteDialect) Today() string      { return "date('now')" }
+
+func (sqliteDialect) HoursFromNow(hours int) string {
+	return fmt.Sprintf("datetime('now', '+%d hours')", hours)
+}
+
+func (sqliteDialect) Date(expr string) string {
+	return fmt.Sprintf("date(%s)", expr)
+}
+
+// Timestamp converts the driver's stored text (which may carry a UTC offset)
+// to SQLite's canonical UTC form so that comparisons are chronological
+func (sqliteDialect) Timestamp(expr string) string {
+	return fmt.Sprintf("datetime(%s)", expr)
+}
+
+// ForUpdate is empty because SQLite has no row locks; the single connection
+// and database-level write lock already serialize transactions
+func (sqliteDialect) ForUpdate() string {
+	return ""
+}
+
+func (sqliteDialect) IsUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+// Search scores each term found in a column, weighting earlier columns
+// higher, and matches rows containing any term
+func (sqliteDialect) Search(text string, terms []string, columns ...string) (SQLFragment, SQLFragment) {
+	var score, condition SQLFragment
+	var scoreParts, conditionParts []string
+
+	for _, term := range terms {
+		pattern := "%" + strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(term) + "%"
+
+		for i, column := range columns {
+			weight := len(columns) - i
+			scoreParts = append(scoreParts, fmt.Sprintf("CASE WHEN %s LIKE ? ESCAPE '!' THEN %d ELSE 0 END", column, weight))
+			score.Args = append(score.Args, pattern)
+
+			conditionParts = append(conditionParts, column+" LIKE ? ESCAPE '!'")
+			condition.Args = append(condition.Args, pattern)
+		}
+	}
+
+	if len(scoreParts) == 0 {
+		return SQLFragment{SQL: "0"}, SQLFragment{SQL: "1 = 0"}
+	}
+
+	score.SQL = "(" + strings.Join(scoreParts, " + ") + ")"
+	condition.SQL = "(" + strings.Join(conditionParts, " OR ") + ")"
+
+	return score, condition
+}
+
+// SQLiteDSN makes the driver store times as "YYYY-MM-DD HH:MM:SS-07:00" text,
+// which SQLite's date functions understand, instead of Go's time.String form
+func SQLiteDSN(dsn string) string {
+	if strings.Contains(dsn, "_time_format=") {
+		return dsn
+	}
+
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+
+	return dsn + separator + "_time_format=sqlite"
+}
@@ -0,0 +1,324 @@
+// Package i18n renders notification texts from a catalog of message templates
+// per locale, with plural forms and localized dates and digits.
+//
+// Locales are JSON files in locales/, named by language. A message is either
+// a template or an object of CLDR plural forms ("one", "other", ...). Message
+// templates can call time (a time.Time and the name of one of the locale's
+// formats) and number.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultLocale is the end of every fallback chain, so it must have every
+// message and format
+const DefaultLocale = "en"
+
+var ErrUnsupportedLocale = errors.New("locale must be a supported language tag such as en or bn-BD")
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+var catalog = mustLoad(localeFiles)
+
+var tagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// pluralRules pick the CLDR plural form of a count by language. Languages
+// without a rule only use "other".
+var pluralRules = map[string]func(n int) string{
+	"en": func(n int) string {
+		if n == 1 {
+			return "one"
+		}
+		return "other"
+	},
+	"bn": func(n int) string {
+		if n == 0 || n == 1 {
+			return "one"
+		}
+		return "other"
+	},
+}
+
+type locale struct {
+	messages map[string]map[string]*template.Template // by key, then plural form
+	formats  map[string]string                        // time layouts by name
+	names    *strings.Replacer                        // English month and weekday names
+	digits   []rune
+	plural   func(n int) string
+	fallback *locale // the default locale, for formats this one lacks
+}
+
+type localeFile struct {
+	Formats  map[string]string          `json:"formats"`
+	Months   []string                   `json:"months"`
+	Weekdays []string                   `json:"weekdays"` // from Sunday
+	Digits   string                     `json:"digits"`   // zero to nine
+	Messages map[string]json.RawMessage `json:"messages"`
+}
+
+func mustLoad(fsys fs.FS) map[string]*locale {
+	locales, err := load(fsys)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return locales
+}
+
+// load parses every locale in fsys
+func load(fsys fs.FS) (map[string]*locale, error) {
+	files, err := fs.Glob(fsys, "locales/*.json")
+
+	if err != nil {
+		return nil, err
+	}
+
+	locales := map[string]*locale{}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".json")
+		data, err := fs.ReadFile(fsys, file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		l, err := parseLocale(name, data)
+
+		if err != nil {
+			return nil, fmt.Errorf("locale %s: %w", name, err)
+		}
+
+		locales[name] = l
+	}
+
+	if locales[DefaultLocale] == nil {
+		return nil, fmt.Errorf("default locale %s is missing", DefaultLocale)
+	}
+
+	for _, l := range locales {
+		l.fallback = locales[DefaultLocale]
+	}
+
+	return locales, nil
+}
+
+func parseLocale(name string, data []byte) (*locale, error) {
+	var file localeFile
+
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	l := &locale{
+		messages: map[string]map[string]*template.Template{},
+		formats:  file.Formats,
+		plural:   pluralRules[strings.SplitN(name, "-", 2)[0]],
+	}
+
+	if l.plural == nil {
+		l.plural = func(int) string { return "other" }
+	}
+
+	var pairs []string
+
+	if len(file.Months) == 12 {
+		for i, month := range file.Months {
+			pairs = append(pairs, time.Month(i+1).String(), month)
+		}
+	} else if len(file.Months) > 0 {
+		return nil, errors.New("months must list all 12")
+	}
+
+	if len(file.Weekdays) == 7 {
+		for i, weekday := range file.Weekdays {
+			pairs = append(pairs, time.Weekday(i).String(), weekday)
+		}
+	} else if len(file.Weekdays) > 0 {
+		return nil, errors.New("weekdays must list all 7")
+	}
+
+	l.names = strings.NewReplacer(pairs...)
+
+	if file.Digits != "" {
+		l.digits = []rune(file.Digits)
+
+		if len(l.digits) != 10 {
+			return nil, errors.New("digits must list zero to nine")
+		}
+	}
+
+	funcs := template.FuncMap{"time": l.formatTime, "number": l.number}
+
+	for key, raw := range file.Messages {
+		forms := map[string]string{}
+
+		var text string
+		if err := json.Unmarshal(raw, &text); err == nil {
+			forms["other"] = text
+		} else if err := json.Unmarshal(raw, &forms); err != nil {
+			return nil, fmt.Errorf("message %s must be a string or plural forms", key)
+		}
+
+		if forms["other"] == "" {
+			return nil, fmt.Errorf("message %s has no \"other\" form", key)
+		}
+
+		l.messages[key] = map[string]*template.Template{}
+
+		for form, text := range forms {
+			tmpl, err := template.New(key).Option("missingkey=error").Funcs(funcs).Parse(text)
+
+			if err != nil {
+				return nil, err
+			}
+
+			l.messages[key][form] = tmpl
+		}
+	}
+
+	return l, nil
+}
+
+// formatTime writes t with the named layout, in the locale's names and digits
+func (l *locale) formatTime(t time.Time, format string) string {
+	layout, ok := l.formats[format]
+
+	if !ok {
+		layout, ok = l.fallback.formats[format]
+	}
+
+	if !ok {
+		layout = time.RFC1123
+	}
+
+	return l.localizeDigits(l.names.Replace(t.Format(layout)))
+}
+
+func (l *locale) number(n int) string {
+	return l.localizeDigits(strconv.Itoa(n))
+}
+
+func (l *locale) localizeDigits(s string) string {
+	if l.digits == nil {
+		return s
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return l.digits[r-'0']
+		}
+		return r
+	}, s)
+}
+
+// normalize lowercases tag and writes it with hyphens, as in "bn-bd"
+func normalize(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+// Chain returns the locales tried for tag, most specific first: "bn-BD" gives
+// bn-bd, bn and then the default locale
+func Chain(tag string) []string {
+	var chain []string
+	tag = normalize(tag)
+
+	for tag != "" {
+		chain = append(chain, tag)
+
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+
+	if len(chain) == 0 || chain[len(chain)-1] != DefaultLocale {
+		chain = append(chain, DefaultLocale)
+	}
+
+	return chain
+}
+
+// Supported reports whether tag is a well-formed language tag whose language
+// has a catalog, rather than only reaching the default by fallback
+func Supported(tag string) bool {
+	tag = normalize(tag)
+
+	if !tagPattern.MatchString(tag) {
+		return false
+	}
+
+	language := strings.SplitN(tag, "-", 2)[0]
+
+	for _, name := range Chain(tag) {
+		if catalog[name] != nil && strings.SplitN(name, "-", 2)[0] == language {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Render renders the message key for tag with data, falling back along the
+// chain for locales or keys that are missing. Unknown keys render as the key.
+func Render(tag, key string, data any) string {
+	return RenderPlural(tag, key, 0, data)
+}
+
+// RenderPlural is Render, picking the plural form for count
+func RenderPlural(tag, key string, count int, data any) string {
+	for _, name := range Chain(tag) {
+		l := catalog[name]
+
+		if l == nil {
+			continue
+		}
+
+		forms, ok := l.messages[key]
+
+		if !ok {
+			continue
+		}
+
+		tmpl, ok := forms[l.plural(count)]
+
+		if !ok {
+			tmpl = forms["other"]
+		}
+
+		var text strings.Builder
+
+		if err := tmpl.Execute(&text, data); err != nil {
+			continue
+		}
+
+		return text.String()
+	}
+
+	return key
+}
+
+// Time writes t with the named format of the first locale in tag's chain
+func Time(tag string, t time.Time, format string) string {
+	for _, name := range Chain(tag) {
+		if l := catalog[name]; l != nil {
+			return l.formatTime(t, format)
+		}
+	}
+
+	return t.Format(time.RFC1123)
+}
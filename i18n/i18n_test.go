@@ -0,0 +1,94 @@
+package i18n
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type reminder struct {
+	Event string
+	Start time.Time
+	Count int
+}
+
+func TestChain(t *testing.T) {
+	assert.Equal(t, []string{"bn-bd", "bn", "en"}, Chain("bn_BD"))
+	assert.Equal(t, []string{"en-gb", "en"}, Chain("en-GB"))
+	assert.Equal(t, []string{"en"}, Chain(""))
+}
+
+func TestSupported(t *testing.T) {
+	for _, tag := range []string{"en", "en-US", "bn", "bn-BD", "BN_bd"} {
+		assert.True(t, Supported(tag), tag)
+	}
+
+	for _, tag := range []string{"", "fr", "fr-FR", "english", "bn--BD"} {
+		assert.False(t, Supported(tag), tag)
+	}
+}
+
+func TestRenderPlural(t *testing.T) {
+	start := time.Date(2030, 1, 7, 18, 0, 0, 0, time.UTC)
+
+	one := RenderPlural("en-US", "upcoming_event.hours", 1, reminder{"Standup", start, 1})
+	assert.Equal(t, "Reminder: Your event 'Standup' is in 1 hour at 6:00 PM UTC on Jan 7", one)
+
+	other := RenderPlural("en", "upcoming_event.hours", 5, reminder{"Standup", start, 5})
+	assert.Equal(t, "Reminder: Your event 'Standup' is in 5 hours at 6:00 PM UTC on Jan 7", other)
+}
+
+func TestRender_Bengali(t *testing.T) {
+	dhaka, err := time.LoadLocation("Asia/Dhaka")
+	assert.NoError(t, err)
+
+	start := time.Date(2030, 1, 7, 12, 0, 0, 0, time.UTC).In(dhaka)
+
+	message := Render("bn-BD", "upcoming_event.later", reminder{Event: "Standup", Start: start})
+	assert.Equal(t, "অনুস্মারক: ৭ জানুয়ারি ২০৩০, ১৮:০০ +০৬-এ আপনার ইভেন্ট 'Standup' আছে", message)
+
+	assert.Equal(t, "সোমবার, ৭ জানুয়ারি ২০৩০, ১৮:০০ +০৬", Time("bn", start, "long"))
+}
+
+func TestRender_Fallback(t *testing.T) {
+	// Unsupported locales fall back to the default
+	message := Render("fr", "email.subject.upcoming_event", reminder{Event: "Standup"})
+	assert.Equal(t, "Reminder: Standup is coming up", message)
+
+	assert.Equal(t, "no.such.key", Render("bn", "no.such.key", nil))
+}
+
+func TestCatalogsAreComplete(t *testing.T) {
+	for name, l := range catalog {
+		for key := range catalog[DefaultLocale].messages {
+			assert.Contains(t, l.messages, key, "%s lacks %s", name, key)
+		}
+
+		for key := range l.messages {
+			assert.Contains(t, catalog[DefaultLocale].messages, key, "%s has %s, which %s lacks", name, key, DefaultLocale)
+		}
+	}
+}
+
+func TestLoad_FallsBackPerKey(t *testing.T) {
+	locales, err := load(fstest.MapFS{
+		"locales/en.json": {Data: []byte(`{"formats": {"day": "Monday"}, "messages": {"hi": "Hi {{.}}", "bye": "Bye"}}`)},
+		"locales/xx.json": {Data: []byte(`{"weekdays": ["su", "mo", "tu", "we", "th", "fr", "sa"], "messages": {"hi": "Yo"}}`)},
+	})
+	assert.NoError(t, err)
+
+	catalog, locales = locales, catalog
+	t.Cleanup(func() { catalog = locales })
+
+	assert.Equal(t, "Yo", Render("xx", "hi", nil))
+	assert.Equal(t, "Bye", Render("xx", "bye", nil))
+	assert.Equal(t, "mo", Time("xx", time.Date(2030, 1, 7, 0, 0, 0, 0, time.UTC), "day"))
+
+	_, err = load(fstest.MapFS{"locales/xx.json": {Data: []byte(`{}`)}})
+	assert.Error(t, err)
+
+	_, err = load(fstest.MapFS{"locales/en.json": {Data: []byte(`{"messages": {"n": {"one": "only one"}}}`)}})
+	assert.Error(t, err)
+}
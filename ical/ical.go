@@ -0,0 +1,310 @@
+// Package ical writes iCalendar (RFC 5545) documents containing VEVENTs.
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateTimeFormat  = "20060102T150405Z"
+	localTimeFormat = "20060102T150405"
+	maxLineOctets   = 75
+)
+
+// Calendar is a VCALENDAR. Name is shown by calendar apps that support the
+// X-WR-CALNAME extension.
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+// Event is a VEVENT. Times are written in UTC unless TimeZone is set, when
+// DTSTART, RECURRENCE-ID and EXDATE are written as local times in it and the
+// calendar gets a matching VTIMEZONE, so a series keeps its wall-clock time
+// across daylight saving changes. An event with RecurrenceID set overrides
+// that single occurrence of the series sharing its UID.
+type Event struct {
+	UID          string
+	Stamp        time.Time
+	Start        time.Time
+	Summary      string
+	Description  string
+	Location     string
+	Status       string // CONFIRMED, TENTATIVE or CANCELLED; omitted if empty
+	RRule        string // without the "RRULE:" prefix
+	ExDates      []time.Time
+	RecurrenceID time.Time
+	TimeZone     *time.Location
+}
+
+// Encode writes the calendar with CRLF line endings and long lines folded
+func (c *Calendar) Encode(w io.Writer) error {
+	var b strings.Builder
+
+	writeLine(&b, "BEGIN:VCALENDAR")
+	writeLine(&b, "VERSION:2.0")
+	writeLine(&b, "PRODID:"+escape(c.ProdID))
+	writeLine(&b, "CALSCALE:GREGORIAN")
+	writeLine(&b, "METHOD:PUBLISH")
+
+	if c.Name != "" {
+		writeLine(&b, "X-WR-CALNAME:"+escape(c.Name))
+	}
+
+	for _, span := range c.zoneSpans() {
+		writeTimezone(&b, span.zone, span.from, span.to)
+	}
+
+	for _, event := range c.Events {
+		event.encode(&b)
+	}
+
+	writeLine(&b, "END:VCALENDAR")
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// String returns the encoded calendar
+func (c *Calendar) String() string {
+	var b strings.Builder
+	c.Encode(&b)
+	return b.String()
+}
+
+// zoneSpan is the first and last time the calendar's events give in a zone
+type zoneSpan struct {
+	zone     *time.Location
+	from, to time.Time
+}
+
+// zoneSpans returns the zones the events are written in, in order of first use
+func (c *Calendar) zoneSpans() []zoneSpan {
+	var spans []zoneSpan
+
+	for _, event := range c.Events {
+		if event.local() == nil {
+			continue
+		}
+
+		times := append([]time.Time{event.Start}, event.ExDates...)
+		if !event.RecurrenceID.IsZero() {
+			times = append(times, event.RecurrenceID)
+		}
+
+		i := 0
+		for i < len(spans) && spans[i].zone.String() != event.TimeZone.String() {
+			i++
+		}
+
+		if i == len(spans) {
+			spans = append(spans, zoneSpan{zone: event.TimeZone, from: event.Start, to: event.Start})
+		}
+
+		for _, t := range times {
+			if t.Before(spans[i].from) {
+				spans[i].from = t
+			}
+			if t.After(spans[i].to) {
+				spans[i].to = t
+			}
+		}
+	}
+
+	return spans
+}
+
+// local returns the zone the event's times are written in, nil for UTC
+func (e *Event) local() *time.Location {
+	if e.TimeZone == nil || e.TimeZone.String() == "UTC" {
+		return nil
+	}
+
+	return e.TimeZone
+}
+
+// dateTimes formats a DATE-TIME property, with a TZID if the event has a zone
+func (e *Event) dateTimes(name string, times ...time.Time) string {
+	values := make([]string, len(times))
+	zone := e.local()
+
+	for i, t := range times {
+		if zone == nil {
+			values[i] = formatTime(t)
+		} else {
+			values[i] = t.In(zone).Format(localTimeFormat)
+		}
+	}
+
+	if zone != nil {
+		name += ";TZID=" + zone.String()
+	}
+
+	return name + ":" + strings.Join(values, ",")
+}
+
+func (e *Event) encode(b *strings.Builder) {
+	writeLine(b, "BEGIN:VEVENT")
+	writeLine(b, "UID:"+escape(e.UID))
+	writeLine(b, "DTSTAMP:"+formatTime(e.Stamp))
+	writeLine(b, e.dateTimes("DTSTART", e.Start))
+
+	if !e.RecurrenceID.IsZero() {
+		writeLine(b, e.dateTimes("RECURRENCE-ID", e.RecurrenceID))
+	}
+
+	writeLine(b, "SUMMARY:"+escape(e.Summary))
+
+	if e.Description != "" {
+		writeLine(b, "DESCRIPTION:"+escape(e.Description))
+	}
+
+	if e.Location != "" {
+		writeLine(b, "LOCATION:"+escape(e.Location))
+	}
+
+	if e.Status != "" {
+		writeLine(b, "STATUS:"+e.Status)
+	}
+
+	if e.RRule != "" {
+		writeLine(b, "RRULE:"+e.RRule)
+	}
+
+	if len(e.ExDates) > 0 {
+		writeLine(b, e.dateTimes("EXDATE", e.ExDates...))
+	}
+
+	writeLine(b, "END:VEVENT")
+}
+
+// writeTimezone writes a VTIMEZONE with the offsets of zone from the one in
+// effect at from until a year after to. The changes of that last year repeat
+// yearly, for series that run on past it.
+func writeTimezone(b *strings.Builder, zone *time.Location, from, to time.Time) {
+	writeLine(b, "BEGIN:VTIMEZONE")
+	writeLine(b, "TZID:"+zone.String())
+
+	t := from.In(zone)
+	onset, end := t.ZoneBounds()
+	_, offset := t.Zone()
+
+	// A zone that never changed is said to have had its offset since 1970
+	if onset.IsZero() {
+		writeObservance(b, t, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), offset, false)
+	} else {
+		_, before := onset.Add(-time.Second).In(zone).Zone()
+		writeObservance(b, t, onset.In(time.FixedZone("", before)), before, false)
+	}
+
+	until := to.AddDate(1, 0, 0)
+
+	for !end.IsZero() && end.Before(until) {
+		_, before := t.Zone()
+		t = end.In(zone)
+		writeObservance(b, t, end.In(time.FixedZone("", before)), before, !end.Before(to))
+		_, end = t.ZoneBounds()
+	}
+
+	writeLine(b, "END:VTIMEZONE")
+}
+
+// writeObservance writes the STANDARD or DAYLIGHT time in effect at t, which
+// starts at the wall-clock time start, still in the offset before it
+func writeObservance(b *strings.Builder, t, start time.Time, before int, repeat bool) {
+	name, offset := t.Zone()
+	kind := "STANDARD"
+
+	if t.IsDST() {
+		kind = "DAYLIGHT"
+	}
+
+	writeLine(b, "BEGIN:"+kind)
+	writeLine(b, "DTSTART:"+start.Format(localTimeFormat))
+	writeLine(b, "TZOFFSETFROM:"+formatOffset(before))
+	writeLine(b, "TZOFFSETTO:"+formatOffset(offset))
+
+	if repeat {
+		writeLine(b, "RRULE:"+yearlyOn(start))
+	}
+
+	writeLine(b, "TZNAME:"+escape(name))
+	writeLine(b, "END:"+kind)
+}
+
+// yearlyOn is the rule repeating t's weekday of its month yearly, such as the
+// second Sunday of March, or the last one when there is no later such day
+func yearlyOn(t time.Time) string {
+	week := (t.Day()-1)/7 + 1
+
+	if t.AddDate(0, 0, 7).Month() != t.Month() {
+		week = -1
+	}
+
+	weekday := strings.ToUpper(t.Weekday().String()[:2])
+
+	return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", t.Month(), week, weekday)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(dateTimeFormat)
+}
+
+// formatOffset writes a UTC offset in seconds as [+-]hhmm, with seconds only
+// when there are some
+func formatOffset(offset int) string {
+	sign := "+"
+
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+
+	formatted := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset/60%60)
+
+	if offset%60 != 0 {
+		formatted += fmt.Sprintf("%02d", offset%60)
+	}
+
+	return formatted
+}
+
+// escape quotes TEXT property values as section 3.3.11 requires
+func escape(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(value)
+}
+
+// writeLine folds a content line into chunks of at most 75 octets, each
+// continuation starting with a space, without splitting UTF-8 sequences
+func writeLine(b *strings.Builder, line string) {
+	limit := maxLineOctets
+
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+
+		// The leading space of a continuation line counts towards its length
+		limit = maxLineOctets - 1
+	}
+
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
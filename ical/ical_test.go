@@ -0,0 +1,185 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendar_Encode(t *testing.T) {
+	start := time.Date(2030, 1, 7, 18, 0, 0, 0, time.UTC)
+	stamp := time.Date(2029, 12, 1, 9, 30, 0, 0, time.FixedZone("UTC+6", 6*60*60))
+
+	calendar := Calendar{
+		ProdID: "-//go-events//EN",
+		Name:   "My events",
+		Events: []Event{
+			{
+				UID:         "event-1@go-events",
+				Stamp:       stamp,
+				Start:       start,
+				Summary:     "Go; Rust, and more",
+				Description: "Line one\nLine two \\ done",
+				Location:    "Dhaka",
+				RRule:       "FREQ=WEEKLY;COUNT=4",
+				ExDates:     []time.Time{start.AddDate(0, 0, 7), start.AddDate(0, 0, 14)},
+			},
+			{
+				UID:          "event-1@go-events",
+				Stamp:        stamp,
+				Start:        start.AddDate(0, 0, 22),
+				RecurrenceID: start.AddDate(0, 0, 21),
+				Summary:      "Moved",
+				Status:       "CONFIRMED",
+			},
+		},
+	}
+
+	expected := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//go-events//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:My events",
+		"BEGIN:VEVENT",
+		"UID:event-1@go-events",
+		"DTSTAMP:20291201T033000Z",
+		"DTSTART:20300107T180000Z",
+		`SUMMARY:Go\; Rust\, and more`,
+		`DESCRIPTION:Line one\nLine two \\ done`,
+		"LOCATION:Dhaka",
+		"RRULE:FREQ=WEEKLY;COUNT=4",
+		"EXDATE:20300114T180000Z,20300121T180000Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:event-1@go-events",
+		"DTSTAMP:20291201T033000Z",
+		"DTSTART:20300129T180000Z",
+		"RECURRENCE-ID:20300128T180000Z",
+		"SUMMARY:Moved",
+		"STATUS:CONFIRMED",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	assert.Equal(t, expected, calendar.String())
+}
+
+func TestCalendar_EncodeTimeZone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	start := time.Date(2030, 1, 7, 18, 0, 0, 0, newYork)
+	stamp := time.Date(2029, 12, 1, 9, 30, 0, 0, time.UTC)
+
+	calendar := Calendar{
+		ProdID: "-//go-events//EN",
+		Events: []Event{
+			{
+				UID:      "event-1@go-events",
+				Stamp:    stamp,
+				Start:    start,
+				Summary:  "Weekly",
+				RRule:    "FREQ=WEEKLY",
+				ExDates:  []time.Time{start.AddDate(0, 2, 4)},
+				TimeZone: newYork,
+			},
+			{
+				UID:          "event-1@go-events",
+				Stamp:        stamp,
+				Start:        start.AddDate(0, 0, 7).Add(time.Hour),
+				RecurrenceID: start.AddDate(0, 0, 7),
+				Summary:      "Weekly",
+				TimeZone:     newYork,
+			},
+		},
+	}
+
+	expected := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//go-events//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"BEGIN:VTIMEZONE",
+		"TZID:America/New_York",
+		"BEGIN:STANDARD",
+		"DTSTART:20291104T020000",
+		"TZOFFSETFROM:-0400",
+		"TZOFFSETTO:-0500",
+		"TZNAME:EST",
+		"END:STANDARD",
+		"BEGIN:DAYLIGHT",
+		"DTSTART:20300310T020000",
+		"TZOFFSETFROM:-0500",
+		"TZOFFSETTO:-0400",
+		"TZNAME:EDT",
+		"END:DAYLIGHT",
+		"BEGIN:STANDARD",
+		"DTSTART:20301103T020000",
+		"TZOFFSETFROM:-0400",
+		"TZOFFSETTO:-0500",
+		"RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU",
+		"TZNAME:EST",
+		"END:STANDARD",
+		"BEGIN:DAYLIGHT",
+		"DTSTART:20310309T020000",
+		"TZOFFSETFROM:-0500",
+		"TZOFFSETTO:-0400",
+		"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU",
+		"TZNAME:EDT",
+		"END:DAYLIGHT",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:event-1@go-events",
+		"DTSTAMP:20291201T093000Z",
+		"DTSTART;TZID=America/New_York:20300107T180000",
+		"SUMMARY:Weekly",
+		"RRULE:FREQ=WEEKLY",
+		"EXDATE;TZID=America/New_York:20300311T180000",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:event-1@go-events",
+		"DTSTAMP:20291201T093000Z",
+		"DTSTART;TZID=America/New_York:20300114T190000",
+		"RECURRENCE-ID;TZID=America/New_York:20300114T180000",
+		"SUMMARY:Weekly",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	assert.Equal(t, expected, calendar.String())
+}
+
+func TestFormatOffset(t *testing.T) {
+	assert.Equal(t, "+0000", formatOffset(0))
+	assert.Equal(t, "+0530", formatOffset(5*3600+30*60))
+	assert.Equal(t, "-0330", formatOffset(-(3*3600 + 30*60)))
+	assert.Equal(t, "+001815", formatOffset(18*60+15))
+}
+
+func TestWriteLine_Folds(t *testing.T) {
+	var b strings.Builder
+	// 'é' is two octets, so a naive cut at 75 would split it
+	writeLine(&b, "DESCRIPTION:"+strings.Repeat("a", 62)+"é"+strings.Repeat("b", 100))
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+	assert.Len(t, lines, 3)
+
+	for i, line := range lines {
+		assert.LessOrEqual(t, len(line), 75)
+		if i > 0 {
+			assert.True(t, strings.HasPrefix(line, " "))
+		}
+	}
+
+	assert.Equal(t, "DESCRIPTION:"+strings.Repeat("a", 62), lines[0])
+
+	unfolded := strings.ReplaceAll(strings.TrimSuffix(b.String(), "\r\n"), "\r\n ", "")
+	assert.Equal(t, "DESCRIPTION:"+strings.Repeat("a", 62)+"é"+strings.Repeat("b", 100), unfolded)
+}
@@ -0,0 +1,221 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"example.com/rest-api/config"
+	"example.com/rest-api/db"
+	"example.com/rest-api/models"
+	"example.com/rest-api/notify"
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// testRepositories returns repositories over the database the test set up
+func testRepositories() models.Repositories {
+	return models.NewSQLRepositories(db.DB, db.Dialect)
+}
+
+// createNotification stores a notification for a new user and event, which
+// queues its email delivery
+func createNotification(t *testing.T) models.Notification {
+	user := models.User{Email: "mail@example.com", Password: "hashed"}
+	assert.NoError(t, testRepositories().Users.Save(context.Background(), &user))
+
+	event := models.Event{
+		Name:        "Mail Night",
+		Description: "Testing email",
+		Location:    "Dhaka",
+		DateTime:    time.Now().Add(3 * time.Hour).UTC().Truncate(time.Second),
+		UserID:      user.ID,
+	}
+	assert.NoError(t, testRepositories().Events.Save(context.Background(), &event))
+
+	notification := models.Notification{
+		UserID:    user.ID,
+		EventID:   event.ID,
+		Message:   "Reminder: Mail Night is in 3 hour(s)",
+		Type:      models.NotificationTypeUpcomingEvent,
+		CreatedAt: time.Now(),
+	}
+	assert.NoError(t, testRepositories().Notifications.Save(context.Background(), &notification))
+
+	return notification
+}
+
+func TestNotificationService_DeliverPending(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	notification := createNotification(t)
+	notifier := &notify.MemoryNotifier{}
+	service := NewNotificationService(config.Default().Jobs, testRepositories(), notifier)
+
+	assert.NoError(t, service.deliverPending(context.Background()))
+
+	sent := notifier.Sent()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "mail@example.com", sent[0].To)
+	assert.Equal(t, "Reminder: Mail Night is coming up", sent[0].Subject)
+
+	deliveries, err := testRepositories().Deliveries.ForNotification(context.Background(), notification.ID)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, models.DeliveryStatusSent, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.NotNil(t, deliveries[0].SentAt)
+
+	// Sent deliveries are not picked up again
+	assert.NoError(t, service.deliverPending(context.Background()))
+	assert.Len(t, notifier.Sent(), 1)
+}
+
+func TestNotificationService_DeliverPendingRetries(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	notification := createNotification(t)
+	notifier := &notify.MemoryNotifier{}
+	notifier.SetErr(errors.New("connection refused"))
+
+	cfg := config.Default().Jobs
+	cfg.DeliveryMaxAttempts = 2
+	service := NewNotificationService(cfg, testRepositories(), notifier)
+
+	assert.NoError(t, service.deliverPending(context.Background()))
+
+	deliveries, err := testRepositories().Deliveries.ForNotification(context.Background(), notification.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DeliveryStatusPending, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, "connection refused", deliveries[0].LastError)
+	assert.True(t, deliveries[0].NextAttemptAt.After(time.Now()))
+
+	// Not due yet, so nothing is attempted
+	assert.NoError(t, service.deliverPending(context.Background()))
+
+	deliveries, err = testRepositories().Deliveries.ForNotification(context.Background(), notification.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+
+	// Once due, the last allowed attempt fails for good
+	_, err = db.DB.Exec(`UPDATE notification_deliveries SET next_attempt_at = ?`, time.Now().Add(-time.Minute).UTC())
+	assert.NoError(t, err)
+
+	assert.NoError(t, service.deliverPending(context.Background()))
+
+	deliveries, err = testRepositories().Deliveries.ForNotification(context.Background(), notification.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DeliveryStatusFailed, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Empty(t, notifier.Sent())
+}
+
+func TestNotificationService_DeliverPendingDefersQuietHours(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	notification := createNotification(t)
+
+	// Quiet from an hour ago until an hour from now
+	now := time.Now().UTC()
+	clock := func(t time.Time) models.ClockTime { return models.ClockTime(t.Hour()*60 + t.Minute()) }
+	preferences := models.DefaultNotificationPreferences(notification.UserID)
+	quiet := []models.QuietHours{{Start: clock(now.Add(-time.Hour)), End: clock(now.Add(time.Hour))}}
+	assert.NoError(t, preferences.Merge(models.NotificationPreferences{QuietHours: quiet}))
+	assert.NoError(t, testRepositories().Notifications.SavePreferences(context.Background(), preferences))
+
+	notifier := &notify.MemoryNotifier{}
+	service := NewNotificationService(config.Default().Jobs, testRepositories(), notifier)
+
+	assert.NoError(t, service.deliverPending(context.Background()))
+	assert.Empty(t, notifier.Sent())
+
+	deliveries, err := testRepositories().Deliveries.ForNotification(context.Background(), notification.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DeliveryStatusPending, deliveries[0].Status)
+	assert.Zero(t, deliveries[0].Attempts)
+	assert.WithinDuration(t, now.Add(time.Hour), deliveries[0].NextAttemptAt, time.Minute)
+}
+
+// overlappingNotifier runs another delivery run in the middle of its first
+// send, like a second replica working through the same queue
+type overlappingNotifier struct {
+	notify.MemoryNotifier
+	during func()
+}
+
+func (n *overlappingNotifier) Send(ctx context.Context, msg notify.Message) error {
+	if err := n.MemoryNotifier.Send(ctx, msg); err != nil {
+		return err
+	}
+
+	if during := n.during; during != nil {
+		n.during = nil
+		during()
+	}
+
+	return nil
+}
+
+func TestNotificationService_DeliverPendingOverlappingRuns(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	first := createNotification(t)
+	second := first
+	second.ID = 0
+	assert.NoError(t, testRepositories().Notifications.Save(context.Background(), &second))
+
+	notifier := &overlappingNotifier{}
+	service := NewNotificationService(config.Default().Jobs, testRepositories(), notifier)
+
+	// The overlapping run sends the second email, which the first run has
+	// already read as pending
+	notifier.during = func() { assert.NoError(t, service.deliverPending(context.Background())) }
+
+	assert.NoError(t, service.deliverPending(context.Background()))
+	assert.Len(t, notifier.Sent(), 2)
+
+	for _, notification := range []models.Notification{first, second} {
+		deliveries, err := testRepositories().Deliveries.ForNotification(context.Background(), notification.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.DeliveryStatusSent, deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+	}
+}
+
+func TestNotificationService_RemindersAreEmailedInTheSameRun(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	user := models.User{Email: "mail@example.com", Password: "hashed"}
+	assert.NoError(t, testRepositories().Users.Save(context.Background(), &user))
+
+	// Within the default reminder offset of a day
+	event := models.Event{Name: "Mail Night", Description: "Testing email", Location: "Dhaka",
+		DateTime: time.Now().Add(3 * time.Hour).UTC().Truncate(time.Second), UserID: user.ID}
+	assert.NoError(t, testRepositories().Events.Save(context.Background(), &event))
+
+	registration := models.EventRegister{EventID: event.ID, UserID: user.ID}
+	assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registration))
+
+	notifier := &notify.MemoryNotifier{}
+	w := newTestWorker(t, "replica-a")
+	assert.NoError(t, NewNotificationService(config.Default().Jobs, testRepositories(), notifier).Register(context.Background(), w))
+
+	// send_emails comes due together with send_reminders and runs first
+	w.RunOnce(context.Background())
+
+	sent := notifier.Sent()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "mail@example.com", sent[0].To)
+}
@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"example.com/rest-api/models"
+)
+
+// JobPruneEventChanges deletes stored event changes every replica has read
+const JobPruneEventChanges = "prune_event_changes"
+
+// eventChangeRetention is how long event changes are kept. Replicas read them
+// within seconds; the rest is slack for one that was paused.
+const eventChangeRetention = time.Hour
+
+// RegisterEventChangePruning adds the hourly job pruning the event changes
+// in events to the worker
+func RegisterEventChangePruning(ctx context.Context, w *Worker, events models.EventRepository) error {
+	w.Handle(JobPruneEventChanges, func(ctx context.Context, _ *models.Job) error {
+		pruned, err := events.PruneChanges(ctx, time.Now().Add(-eventChangeRetention))
+		if err == nil && pruned > 0 {
+			log.Printf("Pruned %d event changes", pruned)
+		}
+		return err
+	})
+
+	return w.Schedule(ctx, JobPruneEventChanges, JobPruneEventChanges, "@hourly")
+}
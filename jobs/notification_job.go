@@ -1,116 +1,248 @@
 package jobs
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"example.com/rest-api/config"
 	"example.com/rest-api/models"
+	"example.com/rest-api/notify"
 )
 
-// NotificationService handles background job for notifications
+// deliveryBatchSize bounds how many pending emails one run sends
+const deliveryBatchSize = 100
+
+// Job types the notification service runs
+const (
+	JobSendReminders = "send_reminders"
+	JobSendEmails    = "send_emails"
+)
+
+// NotificationService creates reminders and emails notifications as jobs run
+// by a Worker
 type NotificationService struct {
-	stopChan chan bool
+	interval    time.Duration
+	repos       models.Repositories
+	notifier    notify.Notifier
+	maxAttempts int
 }
 
-// NewNotificationService creates a new notification service
-func NewNotificationService() *NotificationService {
+// NewNotificationService creates a new notification service storing through
+// repos. A nil notifier leaves email deliveries pending.
+func NewNotificationService(cfg config.JobsConfig, repos models.Repositories, notifier notify.Notifier) *NotificationService {
 	return &NotificationService{
-		stopChan: make(chan bool),
+		interval:    cfg.NotificationInterval,
+		repos:       repos,
+		notifier:    notifier,
+		maxAttempts: cfg.DeliveryMaxAttempts,
 	}
 }
 
-// Start begins the background job that runs every hour
-func (ns *NotificationService) Start() {
-	ticker := time.NewTicker(1 * time.Hour)
+// Register adds the service's jobs to the worker, scheduled on the
+// configured interval
+func (ns *NotificationService) Register(ctx context.Context, w *Worker) error {
+	w.Handle(JobSendReminders, func(ctx context.Context, _ *models.Job) error { return ns.sendReminders(ctx) })
+	w.Handle(JobSendEmails, func(ctx context.Context, _ *models.Job) error { return ns.deliverPending(ctx) })
 
-	go func() {
-		log.Println("Notification service started")
-
-		// Run immediately when started
-		ns.processUpcomingEvents()
+	if err := w.Schedule(ctx, JobSendReminders, JobSendReminders, every(ns.interval)); err != nil {
+		return err
+	}
 
-		for {
-			select {
-			case <-ticker.C:
-				ns.processUpcomingEvents()
-			case <-ns.stopChan:
-				ticker.Stop()
-				log.Println("Notification service stopped")
-				return
-			}
-		}
-	}()
+	return w.Schedule(ctx, JobSendEmails, JobSendEmails, every(ns.interval))
 }
 
-// Stop stops the background job
-func (ns *NotificationService) Stop() {
-	ns.stopChan <- true
+// sendReminders creates the reminders that are due and emails them right
+// away, rather than leaving them to the next send_emails run a whole interval
+// later
+func (ns *NotificationService) sendReminders(ctx context.Context) error {
+	if err := ns.processUpcomingEvents(ctx); err != nil {
+		return err
+	}
+
+	return ns.deliverPending(ctx)
 }
 
-// processUpcomingEvents finds upcoming events and creates notifications
-func (ns *NotificationService) processUpcomingEvents() {
+// processUpcomingEvents sends the reminders that have come due under each
+// registration's reminder schedule. Each reminder is delivered in its own
+// transaction, so when ctx is cancelled the run stops between two of them and
+// the rest are sent by the next run.
+func (ns *NotificationService) processUpcomingEvents(ctx context.Context) error {
 	log.Println("Processing upcoming events for notifications...")
 
-	upcomingEvents, err := models.GetUpcomingEventsForNotification()
+	now := time.Now()
+
+	reminders, err := ns.repos.Reminders.Due(ctx, now)
 	if err != nil {
-		log.Printf("Error fetching upcoming events: %v", err)
-		return
+		return fmt.Errorf("fetching due reminders: %w", err)
 	}
 
-	if len(upcomingEvents) == 0 {
+	if len(reminders) == 0 {
 		log.Println("No upcoming events found for notifications")
-		return
+		return nil
 	}
 
 	notificationsCreated := 0
 
-	for _, event := range upcomingEvents {
-		notification := &models.Notification{
-			UserID:    event.UserID,
-			EventID:   event.EventID,
-			Message:   ns.generateNotificationMessage(event.EventName, event.DateTime),
-			Type:      "upcoming_event",
-			IsRead:    false,
-			CreatedAt: time.Now(),
+	// Reminders are stored even during quiet hours, which only hold back
+	// their emails
+	for _, reminder := range reminders {
+		if err := ctx.Err(); err != nil {
+			log.Printf("Stopped after creating %d notifications: %v", notificationsCreated, err)
+			return err
 		}
 
-		err := notification.Save()
+		message := ns.generateNotificationMessage(reminder.EventName, reminder.Start)
+
+		_, err := ns.repos.Reminders.Deliver(ctx, &reminder, message, now)
+		if errors.Is(err, models.ErrReminderAlreadyDelivered) {
+			continue
+		}
 		if err != nil {
 			log.Printf("Error creating notification for user %d, event %d: %v",
-				event.UserID, event.EventID, err)
+				reminder.UserID, reminder.EventID, err)
 			continue
 		}
 
 		notificationsCreated++
 		log.Printf("Created notification for user %d for event '%s'",
-			event.UserID, event.EventName)
+			reminder.UserID, reminder.EventName)
 	}
 
 	log.Printf("Successfully created %d notifications for upcoming events", notificationsCreated)
+	return nil
 }
 
-// generateNotificationMessage creates a user-friendly notification message
-func (ns *NotificationService) generateNotificationMessage(eventName string, eventTime time.Time) string {
+// generateNotificationMessage picks the reminder message for how soon the
+// event starts. It is rendered in each reader's locale and timezone.
+func (ns *NotificationService) generateNotificationMessage(eventName string, eventTime time.Time) models.LocalizedMessage {
 	hoursUntil := time.Until(eventTime).Hours()
+	params := models.MessageParams{Event: eventName, Start: eventTime}
 
 	if hoursUntil <= 1 {
-		return fmt.Sprintf("Reminder: Your event '%s' is starting soon at %s!",
-			eventName, eventTime.Format("3:04 PM"))
+		return models.LocalizedMessage{Key: "upcoming_event.soon", Params: params}
 	} else if hoursUntil <= 24 {
-		hours := int(hoursUntil)
-		return fmt.Sprintf("Reminder: Your event '%s' is in %d hour(s) at %s",
-			eventName, hours, eventTime.Format("3:04 PM on Jan 2"))
+		params.Count = int(hoursUntil)
+		return models.LocalizedMessage{Key: "upcoming_event.hours", Params: params}
 	}
 
-	return fmt.Sprintf("Reminder: You have an upcoming event '%s' on %s",
-		eventName, eventTime.Format("January 2, 2006 at 3:04 PM"))
+	return models.LocalizedMessage{Key: "upcoming_event.later", Params: params}
 }
 
-// ProcessManually allows manual triggering of the notification process
-// This can be useful for testing or manual runs
-func (ns *NotificationService) ProcessManually() error {
-	log.Println("Manual notification processing triggered")
-	ns.processUpcomingEvents()
+// deliverPending emails notifications whose delivery is due, including
+// retries of earlier failures. It stops between two emails when ctx is
+// cancelled.
+func (ns *NotificationService) deliverPending(ctx context.Context) error {
+	if ns.notifier == nil {
+		return nil
+	}
+
+	deliveries, err := ns.repos.Deliveries.Pending(ctx, models.DeliveryChannelEmail, deliveryBatchSize)
+	if err != nil {
+		return fmt.Errorf("fetching pending deliveries: %w", err)
+	}
+
+	sent := 0
+	now := time.Now()
+	preferences := newPreferenceCache(ns.repos.Notifications)
+
+	for _, delivery := range deliveries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// A retry can come due during quiet hours set after it was queued
+		if until, quiet := preferences.quietUntil(ctx, delivery.Notification.UserID, delivery.Settings.Location(), now); quiet {
+			if err := ns.repos.Deliveries.Defer(ctx, &delivery.Delivery, until); err != nil {
+				log.Printf("Error deferring delivery %d: %v", delivery.ID, err)
+			}
+			continue
+		}
+
+		claimed, err := ns.repos.Deliveries.Claim(ctx, &delivery.Delivery)
+		if err != nil {
+			log.Printf("Error claiming delivery %d: %v", delivery.ID, err)
+			continue
+		}
+
+		// Another run is sending it
+		if !claimed {
+			continue
+		}
+
+		err = ns.send(ctx, delivery)
+
+		if err != nil {
+			log.Printf("Error emailing notification %d to user %d (attempt %d): %v",
+				delivery.NotificationID, delivery.Notification.UserID, delivery.Attempts+1, err)
+
+			if err := ns.repos.Deliveries.MarkFailed(ctx, &delivery.Delivery, err, ns.maxAttempts); err != nil {
+				log.Printf("Error recording failed delivery %d: %v", delivery.ID, err)
+			}
+			continue
+		}
+
+		if err := ns.repos.Deliveries.MarkSent(ctx, &delivery.Delivery); err != nil {
+			log.Printf("Error recording delivery %d as sent: %v", delivery.ID, err)
+			continue
+		}
+
+		sent++
+	}
+
+	if len(deliveries) > 0 {
+		log.Printf("Emailed %d of %d pending notifications", sent, len(deliveries))
+	}
+
 	return nil
 }
+
+func (ns *NotificationService) send(ctx context.Context, delivery models.PendingDelivery) error {
+	delivery.Notification.Localize(&delivery.Settings)
+
+	msg, err := notify.Compose(delivery.Email, notify.Content{
+		Type:      delivery.Notification.Type,
+		Locale:    delivery.Settings.Locale,
+		Message:   delivery.Notification.Message,
+		EventName: delivery.EventName,
+		EventTime: delivery.EventTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	return ns.notifier.Send(ctx, msg)
+}
+
+// preferenceCache loads each user's notification preferences once per run
+type preferenceCache struct {
+	notifications models.NotificationRepository
+	loaded        map[int64]*models.NotificationPreferences
+}
+
+func newPreferenceCache(notifications models.NotificationRepository) preferenceCache {
+	return preferenceCache{notifications: notifications, loaded: map[int64]*models.NotificationPreferences{}}
+}
+
+// quietUntil reports whether the user is in quiet hours at now, in their
+// timezone, and until when. If the preferences cannot be loaded the user is
+// treated as available.
+func (c preferenceCache) quietUntil(ctx context.Context, userID int64, location *time.Location, now time.Time) (time.Time, bool) {
+	preferences, ok := c.loaded[userID]
+
+	if !ok {
+		var err error
+		preferences, err = c.notifications.Preferences(ctx, userID)
+
+		if err != nil {
+			log.Printf("Error fetching notification preferences for user %d: %v", userID, err)
+			preferences = models.DefaultNotificationPreferences(userID)
+		}
+
+		c.loaded[userID] = preferences
+	}
+
+	return preferences.QuietUntil(now, location)
+}
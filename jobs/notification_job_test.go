@@ -1,40 +1,56 @@
 package jobs
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
 
+	"example.com/rest-api/config"
+	"example.com/rest-api/i18n"
 	"example.com/rest-api/test"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestNotificationService_NewNotificationService(t *testing.T) {
-	service := NewNotificationService()
-
-	assert.NotNil(t, service)
-	assert.NotNil(t, service.stopChan)
+// dueRemindersQuery is the registrations query of ReminderRepository.Due
+const dueRemindersQuery = `FROM events_registry er\s+INNER JOIN events e`
+
+var dueReminderColumns = []string{"id", "name", "description", "location", "dateTime", "user_id",
+	"capacity", "waitlist_enabled", "rrule", "timezone", "user_id", "occurrence", "timezone"}
+
+// expectDueReminder mocks the queries that find one registration for a one-off
+// event starting at start, with the default schedule and nothing sent yet
+func expectDueReminder(mock sqlmock.Sqlmock, event test.TestEvent, start time.Time) {
+	rows := sqlmock.NewRows(dueReminderColumns).
+		AddRow(event.ID, event.Name, event.Description, event.Location, start, event.UserID,
+			nil, false, "", "UTC", event.UserID, "", "UTC")
+	mock.ExpectQuery(dueRemindersQuery).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT user_id, event_id, offsets FROM reminder_schedules`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "event_id", "offsets"}))
+	mock.ExpectQuery(`SELECT user_id, event_id, occurrence, offset_minutes FROM reminder_deliveries`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "event_id", "occurrence", "offset_minutes"}))
 }
 
-func TestNotificationService_ProcessManually(t *testing.T) {
-	mock, cleanup, err := test.SetupMockDB()
-	assert.NoError(t, err)
-	defer cleanup()
+// expectChannelPreferences mocks reading which channels the recipient wants
+// while saving a notification
+func expectChannelPreferences(mock sqlmock.Sqlmock) {
+	mock.ExpectPrepare(`SELECT channel, enabled FROM notification_preferences`).ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"channel", "enabled"}))
+}
 
-	service := NewNotificationService()
+// expectNoQuietHours mocks reading the recipient's quiet hours, of which
+// there are none, before queueing an email
+func expectNoQuietHours(mock sqlmock.Sqlmock) {
+	mock.ExpectPrepare(`FROM quiet_hours q INNER JOIN users u`).ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"start_minute", "end_minute", "timezone"}))
+}
 
-	// Test with no upcoming events
-	t.Run("No upcoming events", func(t *testing.T) {
-		// Mock the query to return no results
-		columns := []string{"id", "name", "dateTime", "user_id"}
-		rows := sqlmock.NewRows(columns)
-		mock.ExpectQuery(`SELECT e\.id, e\.name, e\.dateTime, er\.user_id`).WillReturnRows(rows)
+func TestNotificationService_NewNotificationService(t *testing.T) {
+	service := NewNotificationService(config.Default().Jobs, testRepositories(), nil)
 
-		err := service.ProcessManually()
-		assert.NoError(t, err)
-		assert.NoError(t, mock.ExpectationsWereMet())
-	})
+	assert.NotNil(t, service)
+	assert.Equal(t, config.Default().Jobs.NotificationInterval, service.interval)
 }
 
 func TestNotificationService_ProcessUpcomingEvents(t *testing.T) {
@@ -42,7 +58,7 @@ func TestNotificationService_ProcessUpcomingEvents(t *testing.T) {
 	assert.NoError(t, err)
 	defer cleanup()
 
-	service := NewNotificationService()
+	service := NewNotificationService(config.Default().Jobs, testRepositories(), nil)
 
 	testEvent := test.GetTestEvent()
 	futureTime := time.Now().Add(12 * time.Hour) // 12 hours from now
@@ -55,32 +71,33 @@ func TestNotificationService_ProcessUpcomingEvents(t *testing.T) {
 		{
 			name: "Successful processing with events",
 			mockFn: func() {
-				// Mock GetUpcomingEventsForNotification
-				columns := []string{"id", "name", "dateTime", "user_id"}
-				rows := sqlmock.NewRows(columns).
-					AddRow(testEvent.ID, testEvent.Name, futureTime, testEvent.UserID)
-				mock.ExpectQuery(`SELECT e\.id, e\.name, e\.dateTime, er\.user_id`).WillReturnRows(rows)
-
-				// Mock notification save
-				insertQuery := `INSERT INTO notifications \(user_id, event_id, message, type, is_read, created_at\) VALUES \(\?, \?, \?, \?, \?, \?\)`
+				expectDueReminder(mock, testEvent, futureTime)
+
+				// Mock the notification, its email delivery and the reminder record
+				mock.ExpectBegin()
+				expectChannelPreferences(mock)
+				insertQuery := `INSERT INTO notifications \(user_id, event_id, message, type, is_read, created_at, in_app, message_key, message_params\) VALUES \(\?, \?, \?, \?, \?, \?, \?, \?, \?\)`
 				mock.ExpectPrepare(insertQuery).ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+				expectNoQuietHours(mock)
+				mock.ExpectPrepare(`INSERT INTO notification_deliveries`).ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(`INSERT INTO reminder_deliveries`).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
 			},
 			wantErr: false,
 		},
 		{
 			name: "Error fetching events",
 			mockFn: func() {
-				mock.ExpectQuery(`SELECT e\.id, e\.name, e\.dateTime, er\.user_id`).
+				mock.ExpectQuery(dueRemindersQuery).
 					WillReturnError(errors.New("database error"))
 			},
-			wantErr: false, // processUpcomingEvents logs errors but doesn't return them
+			wantErr: true, // so the job is retried
 		},
 		{
 			name: "No upcoming events",
 			mockFn: func() {
-				columns := []string{"id", "name", "dateTime", "user_id"}
-				rows := sqlmock.NewRows(columns)
-				mock.ExpectQuery(`SELECT e\.id, e\.name, e\.dateTime, er\.user_id`).WillReturnRows(rows)
+				rows := sqlmock.NewRows(dueReminderColumns)
+				mock.ExpectQuery(dueRemindersQuery).WillReturnRows(rows)
 			},
 			wantErr: false,
 		},
@@ -91,7 +108,8 @@ func TestNotificationService_ProcessUpcomingEvents(t *testing.T) {
 			tt.mockFn()
 
 			// Call processUpcomingEvents directly for testing
-			service.processUpcomingEvents()
+			err := service.processUpcomingEvents(context.Background())
+			assert.Equal(t, tt.wantErr, err != nil)
 
 			// Verify expectations were met
 			assert.NoError(t, mock.ExpectationsWereMet())
@@ -100,7 +118,7 @@ func TestNotificationService_ProcessUpcomingEvents(t *testing.T) {
 }
 
 func TestNotificationService_GenerateNotificationMessage(t *testing.T) {
-	service := NewNotificationService()
+	service := NewNotificationService(config.Default().Jobs, testRepositories(), nil)
 
 	now := time.Now()
 
@@ -144,54 +162,40 @@ func TestNotificationService_GenerateNotificationMessage(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			message := service.generateNotificationMessage(tt.eventName, tt.eventTime)
+			message := service.generateNotificationMessage(tt.eventName, tt.eventTime).Render(i18n.DefaultLocale, time.UTC)
 			assert.Contains(t, message, tt.wantMsg)
 			assert.Contains(t, message, tt.eventName)
 		})
 	}
 }
 
-func TestNotificationService_StartStop(t *testing.T) {
-	// Setup mock database for this test
-	mock, cleanup, err := test.SetupMockDB()
+func TestNotificationService_GenerateMessage_Plural(t *testing.T) {
+	service := NewNotificationService(config.Default().Jobs, testRepositories(), nil)
+
+	message := service.generateNotificationMessage("Standup", time.Now().Add(90*time.Minute))
+	assert.Equal(t, 1, message.Params.Count)
+	assert.Contains(t, message.Render(i18n.DefaultLocale, time.UTC), "is in 1 hour at")
+
+	message = service.generateNotificationMessage("Standup", time.Now().Add(150*time.Minute))
+	assert.Contains(t, message.Render(i18n.DefaultLocale, time.UTC), "is in 2 hours at")
+}
+
+func TestNotificationService_GenerateMessage_Localized(t *testing.T) {
+	service := NewNotificationService(config.Default().Jobs, testRepositories(), nil)
+
+	dhaka, err := time.LoadLocation("Asia/Dhaka")
 	assert.NoError(t, err)
-	defer cleanup()
 
-	service := NewNotificationService()
-
-	// Mock the query to return no results to avoid database processing
-	columns := []string{"id", "name", "dateTime", "user_id"}
-	rows := sqlmock.NewRows(columns)
-	mock.ExpectQuery(`SELECT e\.id, e\.name, e\.dateTime, er\.user_id`).WillReturnRows(rows)
-
-	// Test that service can be stopped
-	done := make(chan bool)
-	
-	go func() {
-		service.Start()
-		done <- true
-	}()
-
-	// Give it a moment to start and process
-	time.Sleep(50 * time.Millisecond)
-	
-	// Stop the service
-	service.Stop()
-
-	// Wait for completion or timeout
-	select {
-	case <-done:
-		// Service stopped successfully
-	case <-time.After(200 * time.Millisecond):
-		t.Error("Service did not stop within timeout")
-	}
+	start := time.Date(2030, 1, 7, 12, 0, 0, 0, time.UTC)
+	message := service.generateNotificationMessage("Standup", start)
 
-	// Verify the mock expectations
-	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, "upcoming_event.later", message.Key)
+	assert.Contains(t, message.Render(i18n.DefaultLocale, dhaka), "January 7, 2030 at 6:00 PM +06")
+	assert.Contains(t, message.Render("bn", dhaka), "৭ জানুয়ারি ২০৩০, ১৮:০০ +০৬")
 }
 
 func TestNotificationService_GenerateMessage_EdgeCases(t *testing.T) {
-	service := NewNotificationService()
+	service := NewNotificationService(config.Default().Jobs, testRepositories(), nil)
 
 	now := time.Now()
 
@@ -237,7 +241,7 @@ func TestNotificationService_GenerateMessage_EdgeCases(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			message := service.generateNotificationMessage(tt.eventName, tt.eventTime)
+			message := service.generateNotificationMessage(tt.eventName, tt.eventTime).Render(i18n.DefaultLocale, time.UTC)
 			assert.True(t, tt.wantCheck(message), "Message validation failed: %s", message)
 		})
 	}
@@ -248,23 +252,40 @@ func TestNotificationService_ProcessUpcomingEvents_NotificationSaveError(t *test
 	assert.NoError(t, err)
 	defer cleanup()
 
-	service := NewNotificationService()
+	service := NewNotificationService(config.Default().Jobs, testRepositories(), nil)
 
 	testEvent := test.GetTestEvent()
 	futureTime := time.Now().Add(12 * time.Hour)
 
-	// Mock GetUpcomingEventsForNotification to return an event
-	columns := []string{"id", "name", "dateTime", "user_id"}
-	rows := sqlmock.NewRows(columns).
-		AddRow(testEvent.ID, testEvent.Name, futureTime, testEvent.UserID)
-	mock.ExpectQuery(`SELECT e\.id, e\.name, e\.dateTime, er\.user_id`).WillReturnRows(rows)
+	expectDueReminder(mock, testEvent, futureTime)
 
 	// Mock notification save to fail
-	insertQuery := `INSERT INTO notifications \(user_id, event_id, message, type, is_read, created_at\) VALUES \(\?, \?, \?, \?, \?, \?\)`
+	mock.ExpectBegin()
+	expectChannelPreferences(mock)
+	insertQuery := `INSERT INTO notifications \(user_id, event_id, message, type, is_read, created_at, in_app, message_key, message_params\) VALUES \(\?, \?, \?, \?, \?, \?, \?, \?, \?\)`
 	mock.ExpectPrepare(insertQuery).ExpectExec().WillReturnError(errors.New("save failed"))
+	mock.ExpectRollback()
+
+	// A failed reminder is logged and retried on the next run, not by failing
+	// the job
+	err = service.processUpcomingEvents(context.Background())
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationService_ProcessUpcomingEvents_Cancelled(t *testing.T) {
+	mock, cleanup, err := test.SetupMockDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	service := NewNotificationService(config.Default().Jobs, testRepositories(), nil)
 
-	// This should not panic even when save fails
-	service.processUpcomingEvents()
+	// A cancelled run gives up before querying, and reports why
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
+	err = service.processUpcomingEvents(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
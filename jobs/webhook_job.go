@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"example.com/rest-api/config"
+	"example.com/rest-api/models"
+	"example.com/rest-api/webhook"
+)
+
+// webhookBatchSize bounds how many queued webhook deliveries one run sends
+const webhookBatchSize = 100
+
+// JobSendWebhooks sends the webhook outbox
+const JobSendWebhooks = "send_webhooks"
+
+// WebhookService sends the webhook outbox as a job run by a Worker.
+// Deliveries are stored before they are sent, so anything queued before a
+// restart is sent once a worker runs again.
+type WebhookService struct {
+	interval    time.Duration
+	webhooks    models.WebhookRepository
+	sender      *webhook.Sender
+	maxAttempts int
+}
+
+// NewWebhookService creates a webhook service sending the outbox in webhooks
+func NewWebhookService(cfg config.JobsConfig, webhooks models.WebhookRepository) *WebhookService {
+	return &WebhookService{
+		interval:    cfg.WebhookInterval,
+		webhooks:    webhooks,
+		sender:      webhook.NewSender(cfg.WebhookTimeout),
+		maxAttempts: cfg.DeliveryMaxAttempts,
+	}
+}
+
+// Register adds the service's job to the worker, scheduled on the configured
+// interval
+func (ws *WebhookService) Register(ctx context.Context, w *Worker) error {
+	w.Handle(JobSendWebhooks, func(ctx context.Context, _ *models.Job) error { return ws.dispatchPending(ctx) })
+
+	return w.Schedule(ctx, JobSendWebhooks, JobSendWebhooks, every(ws.interval))
+}
+
+// dispatchPending posts the deliveries that are due, including retries of
+// earlier failures
+func (ws *WebhookService) dispatchPending(ctx context.Context) error {
+	deliveries, err := ws.webhooks.Pending(ctx, webhookBatchSize)
+	if err != nil {
+		return fmt.Errorf("fetching pending webhook deliveries: %w", err)
+	}
+
+	delivered := 0
+
+	for _, delivery := range deliveries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		claimed, err := ws.webhooks.Claim(ctx, &delivery.WebhookDelivery)
+		if err != nil {
+			log.Printf("Error claiming webhook delivery %d: %v", delivery.ID, err)
+			continue
+		}
+
+		// Another run is sending it
+		if !claimed {
+			continue
+		}
+
+		code, err := ws.sender.Send(ctx, webhook.Request{
+			URL:        delivery.URL,
+			Secret:     delivery.Secret,
+			Event:      delivery.EventType,
+			DeliveryID: delivery.ID,
+			Payload:    delivery.Payload,
+		})
+
+		if err != nil {
+			log.Printf("Error delivering webhook %d to %s (attempt %d): %v",
+				delivery.ID, delivery.URL, delivery.Attempts+1, err)
+
+			if err := ws.webhooks.MarkFailed(ctx, &delivery.WebhookDelivery, code, err, ws.maxAttempts); err != nil {
+				log.Printf("Error recording failed webhook delivery %d: %v", delivery.ID, err)
+			}
+			continue
+		}
+
+		if err := ws.webhooks.MarkDelivered(ctx, &delivery.WebhookDelivery, code); err != nil {
+			log.Printf("Error recording webhook delivery %d as delivered: %v", delivery.ID, err)
+			continue
+		}
+
+		delivered++
+	}
+
+	if len(deliveries) > 0 {
+		log.Printf("Delivered %d of %d pending webhooks", delivered, len(deliveries))
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/rest-api/config"
+	"example.com/rest-api/db"
+	"example.com/rest-api/models"
+	"example.com/rest-api/test"
+	webhooks "example.com/rest-api/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookService_DispatchPending(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	user := models.User{Email: "hooks@example.com", Password: "hashed"}
+	assert.NoError(t, testRepositories().Users.Save(context.Background(), &user))
+
+	// The test server listens on loopback, which webhooks may not point at
+	// and the real sender refuses to connect to
+	webhook := models.Webhook{UserID: user.ID, URL: "https://hooks.example.com"}
+	assert.NoError(t, testRepositories().Webhooks.Save(context.Background(), &webhook))
+	_, err = db.DB.Exec(`UPDATE webhooks SET url = ?`, server.URL)
+	assert.NoError(t, err)
+	assert.NoError(t, testRepositories().Webhooks.Queue(context.Background(), []int64{user.ID}, models.WebhookEventCreated, map[string]string{"Name": "Launch"}))
+
+	service := NewWebhookService(config.Default().Jobs, testRepositories().Webhooks)
+	service.sender = &webhooks.Sender{Client: server.Client()}
+
+	assert.NoError(t, service.dispatchPending(context.Background()))
+
+	deliveries, err := testRepositories().Webhooks.Deliveries(context.Background(), webhook.ID, 0)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, models.DeliveryStatusPending, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, *deliveries[0].ResponseCode)
+
+	// The retry waits for its backoff
+	assert.NoError(t, service.dispatchPending(context.Background()))
+	assert.Equal(t, int32(1), calls.Load())
+
+	_, err = db.DB.Exec(`UPDATE webhook_deliveries SET next_attempt_at = ?`, time.Now().Add(-time.Minute).UTC())
+	assert.NoError(t, err)
+
+	status.Store(http.StatusOK)
+	assert.NoError(t, service.dispatchPending(context.Background()))
+
+	deliveries, err = testRepositories().Webhooks.Deliveries(context.Background(), webhook.ID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DeliveryStatusSent, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusOK, *deliveries[0].ResponseCode)
+	assert.Equal(t, int32(2), calls.Load())
+}
@@ -0,0 +1,270 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"example.com/rest-api/config"
+	"example.com/rest-api/cron"
+	"example.com/rest-api/models"
+)
+
+// JobPruneJobs deletes finished jobs older than the retention period
+const JobPruneJobs = "prune_jobs"
+
+// workerBatchSize bounds how many jobs one poll runs
+const workerBatchSize = 10
+
+// Handler runs one job. Returning an error retries the job with backoff
+// until it has used its attempts. ctx ends after the run timeout, which fails
+// the attempt, or when the worker is stopped and its shutdown deadline has
+// passed, which hands the job back to the queue; either way the handler
+// should return promptly.
+type Handler func(ctx context.Context, job *models.Job) error
+
+// Worker runs the jobs in the database queue. Any number of workers, in one
+// process or across replicas, can share the queue: each job is leased to the
+// worker that claims it, and claimed again if that worker dies before
+// finishing it.
+type Worker struct {
+	id           string
+	jobs         models.JobRepository
+	handlers     map[string]Handler
+	schedules    map[string]cron.Schedule // by job schedule name
+	overrides    map[string]string
+	pollInterval time.Duration
+	lease        time.Duration
+	runTimeout   time.Duration
+	maxAttempts  int
+	now          func() time.Time
+
+	ctx      context.Context // cancelled to abort running jobs
+	cancel   context.CancelFunc
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{} // closed when the polling goroutine exits
+}
+
+// NewWorker creates a worker on the queue in jobs that prunes finished jobs
+// daily and runs no others until they are registered with Handle
+func NewWorker(ctx context.Context, cfg config.JobsConfig, jobs models.JobRepository) (*Worker, error) {
+	hostname, _ := os.Hostname()
+	runCtx, cancel := context.WithCancel(context.Background())
+
+	w := &Worker{
+		id:           fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		jobs:         jobs,
+		handlers:     map[string]Handler{},
+		schedules:    map[string]cron.Schedule{},
+		overrides:    cfg.Schedules,
+		pollInterval: cfg.PollInterval,
+		lease:        cfg.Lease,
+		runTimeout:   cfg.RunTimeout,
+		maxAttempts:  cfg.MaxAttempts,
+		now:          time.Now,
+		ctx:          runCtx,
+		cancel:       cancel,
+		stop:         make(chan struct{}),
+	}
+
+	w.Handle(JobPruneJobs, func(ctx context.Context, _ *models.Job) error {
+		pruned, err := w.jobs.Prune(ctx, time.Now().Add(-cfg.Retention))
+		if err == nil && pruned > 0 {
+			log.Printf("Pruned %d finished jobs", pruned)
+		}
+		return err
+	})
+
+	if err := w.Schedule(ctx, JobPruneJobs, JobPruneJobs, "@daily"); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Handle registers the handler for jobs of the type
+func (w *Worker) Handle(jobType string, handler Handler) {
+	w.handlers[jobType] = handler
+}
+
+// Schedule enqueues a job of the type whenever spec comes due, unless the
+// configuration overrides the spec for this name. The first run is due
+// immediately.
+func (w *Worker) Schedule(ctx context.Context, name, jobType, spec string) error {
+	if override, ok := w.overrides[name]; ok {
+		spec = override
+	}
+
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return err
+	}
+
+	if err := w.jobs.SaveSchedule(ctx, name, jobType, spec, time.Now()); err != nil {
+		return err
+	}
+
+	w.schedules[name] = schedule
+	return nil
+}
+
+// Start begins polling for due jobs on the configured interval
+func (w *Worker) Start() {
+	ticker := time.NewTicker(w.pollInterval)
+	w.done = make(chan struct{})
+
+	// Not cancelled by Stop, so jobs aborted by a shutdown are still handed
+	// back to the queue
+	ctx := context.Background()
+
+	go func() {
+		defer close(w.done)
+		log.Printf("Job worker %s started", w.id)
+
+		w.RunOnce(ctx)
+
+		for {
+			select {
+			case <-ticker.C:
+				w.RunOnce(ctx)
+			case <-w.stop:
+				ticker.Stop()
+				log.Printf("Job worker %s stopped", w.id)
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops polling and waits for the jobs being run to finish. If ctx ends
+// first, the running jobs are cancelled and handed back to the queue, and
+// Stop returns ctx's error once they have returned. It is safe to call more
+// than once, and without Start.
+func (w *Worker) Stop(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stop) })
+
+	if w.done == nil {
+		w.cancel()
+		return nil
+	}
+
+	select {
+	case <-w.done:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		<-w.done
+		return ctx.Err()
+	}
+}
+
+// stopping reports whether Stop has been called
+func (w *Worker) stopping() bool {
+	select {
+	case <-w.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// RunOnce enqueues the scheduled jobs that are due, then runs up to a batch
+// of due jobs. Each job is claimed right before it runs, so its lease covers
+// its own run however long the ones before it took. ctx is used to update the
+// queue; each job runs with a context of its own. It returns how many jobs it
+// ran.
+func (w *Worker) RunOnce(ctx context.Context) int {
+	w.enqueueScheduled(ctx, w.now())
+
+	types := make([]string, 0, len(w.handlers))
+	for jobType := range w.handlers {
+		types = append(types, jobType)
+	}
+
+	ran := 0
+
+	// Jobs not yet claimed when the worker stops are left to the next worker
+	for ran < workerBatchSize && !w.stopping() {
+		jobs, err := w.jobs.Claim(ctx, w.id, types, 1, w.lease, w.now())
+		if err != nil {
+			log.Printf("Error claiming jobs: %v", err)
+			break
+		}
+
+		if len(jobs) == 0 {
+			break
+		}
+
+		w.run(ctx, &jobs[0])
+		ran++
+	}
+
+	return ran
+}
+
+// enqueueScheduled queues one run of each schedule that came due. Runs missed
+// while no worker was up are not made up for.
+func (w *Worker) enqueueScheduled(ctx context.Context, now time.Time) {
+	due, err := w.jobs.DueSchedules(ctx, now)
+	if err != nil {
+		log.Printf("Error fetching job schedules: %v", err)
+		return
+	}
+
+	for _, schedule := range due {
+		// Schedules registered by other replicas' versions are theirs to run
+		parsed, ok := w.schedules[schedule.Name]
+		if !ok {
+			continue
+		}
+
+		next := parsed.Next(now.UTC())
+		if next.IsZero() {
+			log.Printf("Job schedule %s never comes due again", schedule.Name)
+			continue
+		}
+
+		if _, err := w.jobs.AdvanceSchedule(ctx, &schedule, next); err != nil {
+			log.Printf("Error enqueueing scheduled job %s: %v", schedule.Name, err)
+		}
+	}
+}
+
+// run runs a claimed job and records the outcome. The job must finish within
+// the run timeout, which is shorter than its lease.
+func (w *Worker) run(ctx context.Context, job *models.Job) {
+	handler := w.handlers[job.Type]
+
+	runCtx, cancel := context.WithTimeout(w.ctx, w.runTimeout)
+	defer cancel()
+
+	var err error
+
+	// A job whose lease ran out on its last attempt is not run again
+	if job.Attempts > w.maxAttempts {
+		err = w.jobs.Fail(ctx, job, errors.New("lease expired on the last attempt"), w.maxAttempts)
+	} else if err = handler(runCtx, job); err != nil && w.ctx.Err() != nil {
+		log.Printf("Job %d (%s) aborted by shutdown: %v", job.ID, job.Type, err)
+		err = w.jobs.Release(ctx, job)
+	} else if err != nil {
+		log.Printf("Job %d (%s) failed on attempt %d: %v", job.ID, job.Type, job.Attempts, err)
+		err = w.jobs.Fail(ctx, job, err, w.maxAttempts)
+	} else {
+		err = w.jobs.Complete(ctx, job)
+	}
+
+	if err != nil {
+		log.Printf("Error recording the outcome of job %d (%s): %v", job.ID, job.Type, err)
+	}
+}
+
+// every is the schedule spec for a fixed interval
+func every(interval time.Duration) string {
+	return "@every " + interval.String()
+}
@@ -0,0 +1,231 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"example.com/rest-api/config"
+	"example.com/rest-api/db"
+	"example.com/rest-api/models"
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestWorker(t *testing.T, id string) *Worker {
+	cfg := config.Default().Jobs
+	cfg.MaxAttempts = 2
+
+	w, err := NewWorker(context.Background(), cfg, testRepositories().Jobs)
+	assert.NoError(t, err)
+	w.id = id
+
+	return w
+}
+
+func TestWorker_ScheduledJobRunsOnceAcrossReplicas(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	runs := 0
+
+	for _, id := range []string{"replica-a", "replica-b"} {
+		w := newTestWorker(t, id)
+		w.Handle("tick", func(context.Context, *models.Job) error {
+			runs++
+			return nil
+		})
+		assert.NoError(t, w.Schedule(context.Background(), "tick", "tick", "@every 1h"))
+
+		w.RunOnce(context.Background())
+	}
+
+	assert.Equal(t, 1, runs)
+
+	done, err := testRepositories().Jobs.List(context.Background(), models.JobStatusDone, 10)
+	assert.NoError(t, err)
+	// The tick and the first daily prune
+	assert.Len(t, done, 2)
+}
+
+func TestWorker_ScheduleOverride(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	cfg := config.Default().Jobs
+	cfg.Schedules = map[string]string{"tick": "*/5 * * * *"}
+
+	w, err := NewWorker(context.Background(), cfg, testRepositories().Jobs)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Schedule(context.Background(), "tick", "tick", "@every 1h"))
+
+	var spec string
+	assert.NoError(t, db.DB.QueryRow(`SELECT spec FROM job_schedules WHERE name = ?`, "tick").Scan(&spec))
+	assert.Equal(t, "*/5 * * * *", spec)
+
+	assert.Error(t, w.Schedule(context.Background(), "broken", "tick", "every hour"))
+}
+
+func TestWorker_FailedJobIsRetriedThenDead(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	w := newTestWorker(t, "replica-a")
+	attempts := 0
+	w.Handle("flaky", func(context.Context, *models.Job) error {
+		attempts++
+		return errors.New("upstream unavailable")
+	})
+
+	job, err := testRepositories().Jobs.Enqueue(context.Background(), "flaky", nil, time.Now())
+	assert.NoError(t, err)
+
+	w.RunOnce(context.Background())
+	assert.Equal(t, 1, attempts)
+
+	// Skip the backoff
+	_, err = db.DB.Exec(`UPDATE jobs SET run_at = ? WHERE id = ?`, time.Now().UTC().Add(-time.Second), job.ID)
+	assert.NoError(t, err)
+
+	w.RunOnce(context.Background())
+	assert.Equal(t, 2, attempts)
+
+	dead, err := testRepositories().Jobs.List(context.Background(), models.JobStatusDead, 10)
+	assert.NoError(t, err)
+	assert.Len(t, dead, 1)
+	assert.Equal(t, "upstream unavailable", dead[0].LastError)
+
+	// Dead jobs stay dead until retried by hand
+	_, err = db.DB.Exec(`UPDATE jobs SET run_at = ? WHERE id = ?`, time.Now().UTC().Add(-time.Second), job.ID)
+	assert.NoError(t, err)
+	w.RunOnce(context.Background())
+	assert.Equal(t, 2, attempts)
+}
+
+func TestWorker_ExpiredLastAttemptIsDead(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	w := newTestWorker(t, "replica-b")
+	w.Handle("slow", func(context.Context, *models.Job) error {
+		t.Error("a job out of attempts ran again")
+		return nil
+	})
+
+	_, err = testRepositories().Jobs.Enqueue(context.Background(), "slow", nil, time.Now())
+	assert.NoError(t, err)
+
+	// Another replica claimed the job on its last attempt and died holding it
+	_, err = db.DB.Exec(`UPDATE jobs SET status = ?, attempts = ?, locked_by = ?, locked_until = ?`,
+		models.JobStatusRunning, 2, "replica-a", time.Now().UTC().Add(-time.Second))
+	assert.NoError(t, err)
+
+	w.RunOnce(context.Background())
+
+	dead, err := testRepositories().Jobs.List(context.Background(), models.JobStatusDead, 10)
+	assert.NoError(t, err)
+	assert.Len(t, dead, 1)
+}
+
+func TestWorker_StopIsIdempotent(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	// Never started
+	w := newTestWorker(t, "replica-a")
+	assert.NoError(t, w.Stop(context.Background()))
+	assert.NoError(t, w.Stop(context.Background()))
+
+	w = newTestWorker(t, "replica-a")
+	w.Start()
+
+	stopped := make(chan error)
+	go func() {
+		w.Stop(context.Background())
+		stopped <- w.Stop(context.Background())
+	}()
+
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+}
+
+func TestWorker_StopDeadlineReleasesRunningJob(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	w := newTestWorker(t, "replica-a")
+	started := make(chan struct{})
+	w.Handle("long", func(ctx context.Context, _ *models.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	job, err := testRepositories().Jobs.Enqueue(context.Background(), "long", nil, time.Now())
+	assert.NoError(t, err)
+
+	w.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Stop(ctx), context.DeadlineExceeded)
+
+	// Back in the queue for another worker, without using up an attempt
+	queued, err := testRepositories().Jobs.List(context.Background(), models.JobStatusQueued, 10)
+	assert.NoError(t, err)
+	assert.Len(t, queued, 1)
+	assert.Equal(t, job.ID, queued[0].ID)
+	assert.Zero(t, queued[0].Attempts)
+	assert.Empty(t, queued[0].LockedBy)
+}
+
+func TestWorker_LeaseCountsFromEachJobsStart(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	clock := time.Now()
+	w := newTestWorker(t, "replica-a")
+	w.now = func() time.Time { return clock }
+
+	var ran []int64
+	w.Handle("slow", func(_ context.Context, job *models.Job) error {
+		ran = append(ran, job.ID)
+
+		if len(ran) == 1 {
+			// The first job runs past the lease the whole batch would have had
+			clock = clock.Add(w.lease + time.Minute)
+			return nil
+		}
+
+		// The second job started after that; another replica must not take it over
+		taken, err := testRepositories().Jobs.Claim(context.Background(), "replica-b", []string{"slow"}, 10, w.lease, clock)
+		assert.NoError(t, err)
+		assert.Empty(t, taken)
+		return nil
+	})
+
+	for range 2 {
+		_, err := testRepositories().Jobs.Enqueue(context.Background(), "slow", nil, clock.Add(-time.Second))
+		assert.NoError(t, err)
+	}
+
+	w.RunOnce(context.Background())
+	assert.Len(t, ran, 2)
+
+	queued, err := testRepositories().Jobs.List(context.Background(), models.JobStatusQueued, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, queued)
+}
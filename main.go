@@ -1,24 +1,141 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	// Quiet hours name IANA timezones, which must load even without a
+	// system zoneinfo database
+	_ "time/tzdata"
+
+	"example.com/rest-api/config"
 	"example.com/rest-api/db"
 	"example.com/rest-api/jobs"
+	"example.com/rest-api/migrations"
+	"example.com/rest-api/models"
+	"example.com/rest-api/notify"
+	"example.com/rest-api/realtime"
 	"example.com/rest-api/routes"
+	"example.com/rest-api/utils"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
 
-	db.InitDB()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// `go-events migrate <up|down|status>` manages the schema without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		db.Connect(cfg.Database)
+		defer db.DB.Close()
+
+		if err := migrations.Run(db.DB, db.Dialect.Name(), os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	db.InitDB(cfg.Database)
+	utils.InitJWT(cfg.JWT)
+
+	if cfg.Env == config.EnvProduction {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	notifier, err := notify.New(cfg.Mail)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Push new notifications to clients connected to the stream
+	models.OnNotificationCreated(realtime.Notifications.Publish)
+
+	// Changes stored here are pushed at once; Follow below reads them, and
+	// those of other replicas, from the database
+	models.OnEventChanged(realtime.Events.Wake)
+
+	repos := models.NewSQLRepositories(db.DB, db.Dialect)
+
+	// Cancelled on SIGINT or SIGTERM, which starts the shutdown below
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Push event edits and attendee counts to WebSocket subscribers
+	go realtime.Events.Follow(ctx, repos.Events)
+
+	// Reminders, emails and the webhook outbox run as jobs from the database
+	// queue, shared by every replica
+	worker, err := jobs.NewWorker(ctx, cfg.Jobs, repos.Jobs)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Start the notification service
-	notificationService := jobs.NewNotificationService()
-	notificationService.Start()
+	if err := jobs.NewNotificationService(cfg.Jobs, repos, notifier).Register(ctx, worker); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := jobs.NewWebhookService(cfg.Jobs, repos.Webhooks).Register(ctx, worker); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := jobs.RegisterEventChangePruning(ctx, worker, repos.Events); err != nil {
+		log.Fatal(err)
+	}
+
+	worker.Start()
 
 	server := gin.Default()
 
-	routes.RegisterRoutes(server)
+	routes.RegisterRoutes(server, cfg, repos)
+
+	srv := &http.Server{
+		Addr:    cfg.Addr(),
+		Handler: server,
+	}
+
+	// Live streams and sockets would hold the server open until the deadline;
+	// closing them makes clients reconnect to another replica
+	srv.RegisterOnShutdown(realtime.Notifications.Close)
+	srv.RegisterOnShutdown(realtime.Events.Close)
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+
+	log.Println("Shutting down...")
+
+	// Requests and jobs share the deadline; anything still running after it
+	// is cancelled
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	workerStopped := make(chan error, 1)
+	go func() { workerStopped <- worker.Stop(shutdownCtx) }()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error draining requests: %v", err)
+	}
+
+	if err := <-workerStopped; err != nil {
+		log.Printf("Error stopping job worker: %v", err)
+	}
 
-	server.Run(":8080")
+	// Last, since requests and jobs use the database until they finish
+	if err := db.DB.Close(); err != nil {
+		log.Printf("Error closing database: %v", err)
+	}
 
+	log.Println("Server stopped")
 }
@@ -1,27 +1,56 @@
 package middlewares
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
+	"example.com/rest-api/models"
+	"example.com/rest-api/realtime"
 	"example.com/rest-api/utils"
 	"github.com/gin-gonic/gin"
 )
 
-func Authenticate(context *gin.Context) {
-	token := context.Request.Header.Get("Authorization")
-	if token == "" {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Not authorized"})
-		return
-	}
+// Authenticate accepts requests carrying an access token that tokens has
+// not revoked
+func Authenticate(tokens models.TokenRepository) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		token := context.Request.Header.Get("Authorization")
 
-	userId, err := utils.VerifyToken(token)
+		// Browsers cannot set headers on a WebSocket handshake other than the
+		// subprotocols, so those requests may offer the token as one instead
+		if token == "" && strings.EqualFold(context.GetHeader("Upgrade"), "websocket") {
+			token = realtime.ProtocolToken(context.Request)
+		}
 
-	if err != nil {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Not authorized"})
-		return
-	}
+		if token == "" {
+			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Not authorized"})
+			return
+		}
+
+		claims, err := utils.ParseToken(token)
+
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Not authorized"})
+			return
+		}
 
-	context.Set("userId", userId)
+		err = tokens.CheckAccess(context.Request.Context(), claims)
 
-	context.Next()
-}
\ No newline at end of file
+		if errors.Is(err, models.ErrTokenRevoked) {
+			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Not authorized"})
+			return
+		}
+
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not authorize"})
+			return
+		}
+
+		context.Set("userId", claims.UserID)
+		context.Set("role", models.Role(claims.Role))
+		context.Set("tokenClaims", claims)
+
+		context.Next()
+	}
+}
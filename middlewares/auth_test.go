@@ -1,15 +1,45 @@
 package middlewares
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
+	"example.com/rest-api/db"
+	"example.com/rest-api/models"
+	"example.com/rest-api/test"
 	"example.com/rest-api/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
+// TestMain runs the tests against a SQLite database holding the users the
+// tokens are issued for, since Authenticate checks them for revocation
+func TestMain(m *testing.M) {
+	cleanup, err := test.SetupSQLiteDB()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, id := range []int64{123, 456, 789, 999} {
+		_, err := db.DB.Exec(`INSERT INTO users (id, email, password) VALUES (?, ?, ?)`, id, fmt.Sprintf("user%d@example.com", id), "hash")
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	code := m.Run()
+	cleanup()
+	os.Exit(code)
+}
+
+func testTokens() models.TokenRepository {
+	return models.NewSQLRepositories(db.DB, db.Dialect).Tokens
+}
+
 func TestAuthenticate(t *testing.T) {
 	// Set gin to test mode
 	gin.SetMode(gin.TestMode)
@@ -17,7 +47,7 @@ func TestAuthenticate(t *testing.T) {
 	// Generate a valid token for testing
 	email := "test@example.com"
 	userID := int64(123)
-	validToken, err := utils.GenerateToken(email, userID)
+	validToken, err := utils.GenerateToken(email, userID, "organizer")
 	assert.NoError(t, err)
 
 	tests := []struct {
@@ -70,7 +100,7 @@ func TestAuthenticate(t *testing.T) {
 			router := gin.New()
 
 			// Add the middleware
-			router.Use(Authenticate)
+			router.Use(Authenticate(testTokens()))
 
 			// Add a test route that should only be accessible with valid auth
 			router.GET("/test", func(c *gin.Context) {
@@ -117,11 +147,11 @@ func TestAuthenticate_Integration(t *testing.T) {
 	// Create a complete flow test
 	email := "integration@example.com"
 	userID := int64(456)
-	token, err := utils.GenerateToken(email, userID)
+	token, err := utils.GenerateToken(email, userID, "organizer")
 	assert.NoError(t, err)
 
 	router := gin.New()
-	router.Use(Authenticate)
+	router.Use(Authenticate(testTokens()))
 
 	// Handler that checks if userId is properly set
 	router.GET("/protected", func(c *gin.Context) {
@@ -148,14 +178,14 @@ func TestAuthenticate_ContextValues(t *testing.T) {
 
 	email := "context@example.com"
 	userID := int64(789)
-	token, err := utils.GenerateToken(email, userID)
+	token, err := utils.GenerateToken(email, userID, "organizer")
 	assert.NoError(t, err)
 
 	var capturedUserID int64
 	var contextExists bool
 
 	router := gin.New()
-	router.Use(Authenticate)
+	router.Use(Authenticate(testTokens()))
 
 	router.GET("/capture", func(c *gin.Context) {
 		userId, exists := c.Get("userId")
@@ -183,13 +213,13 @@ func TestAuthenticate_Next(t *testing.T) {
 
 	email := "next@example.com"
 	userID := int64(999)
-	token, err := utils.GenerateToken(email, userID)
+	token, err := utils.GenerateToken(email, userID, "organizer")
 	assert.NoError(t, err)
 
 	nextCalled := false
 
 	router := gin.New()
-	router.Use(Authenticate)
+	router.Use(Authenticate(testTokens()))
 	router.Use(func(c *gin.Context) {
 		nextCalled = true
 		c.Next()
@@ -209,3 +239,113 @@ func TestAuthenticate_Next(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.True(t, nextCalled, "Next middleware should be called when authentication succeeds")
 }
+
+func TestAuthenticate_RevokedToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	token, err := utils.GenerateToken("revoked@example.com", 123, "organizer")
+	assert.NoError(t, err)
+
+	claims, err := utils.ParseToken(token)
+	assert.NoError(t, err)
+
+	router := gin.New()
+	router.Use(Authenticate(testTokens()))
+	router.GET("/revoked", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/revoked", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", token)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, request().Code)
+
+	assert.NoError(t, testTokens().Logout(context.Background(), claims, ""))
+
+	w := request()
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Not authorized")
+}
+
+func TestAuthenticate_UnknownUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	token, err := utils.GenerateToken("deleted@example.com", 404, "organizer")
+	assert.NoError(t, err)
+
+	router := gin.New()
+	router.Use(Authenticate(testTokens()))
+	router.GET("/unknown", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req, err := http.NewRequest("GET", "/unknown", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", token)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthenticate_WebSocketProtocolToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	token, err := utils.GenerateToken("socket@example.com", 456, "attendee")
+	assert.NoError(t, err)
+
+	router := gin.New()
+	router.Use(Authenticate(testTokens()))
+	router.GET("/ws", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(path, upgrade string) int {
+		req, err := http.NewRequest("GET", path, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Sec-WebSocket-Protocol", "access_token, "+token)
+		if upgrade != "" {
+			req.Header.Set("Upgrade", upgrade)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("/ws", "websocket"))
+	assert.Equal(t, http.StatusOK, request("/ws", "WebSocket"))
+
+	// Plain requests must use the Authorization header
+	assert.Equal(t, http.StatusUnauthorized, request("/ws", ""))
+}
+
+func TestAuthenticate_IgnoresQueryToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	token, err := utils.GenerateToken("socket@example.com", 456, "attendee")
+	assert.NoError(t, err)
+
+	router := gin.New()
+	router.Use(Authenticate(testTokens()))
+	router.GET("/ws", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	// A token in the URL would end up in access logs
+	req, err := http.NewRequest("GET", "/ws?access_token="+token, nil)
+	assert.NoError(t, err)
+	req.Header.Set("Upgrade", "websocket")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission lets the request through only for users whose role grants
+// the permission. It must run after Authenticate.
+func RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if !CurrentRole(context).Can(permission) {
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
+			return
+		}
+
+		context.Next()
+	}
+}
+
+// CurrentRole is the role from the request's access token
+func CurrentRole(context *gin.Context) models.Role {
+	role, _ := context.Get("role")
+	r, _ := role.(models.Role)
+	return r
+}
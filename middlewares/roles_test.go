@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveWithRole(role models.Role, guard gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("role", role)
+	})
+	router.GET("/guarded", guard, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/guarded", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	guard := RequirePermission(models.PermissionTriggerNotifications)
+
+	assert.Equal(t, http.StatusOK, serveWithRole(models.RoleAdmin, guard).Code)
+
+	w := serveWithRole(models.RoleOrganizer, guard)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Forbidden")
+
+	create := RequirePermission(models.PermissionCreateEvents)
+
+	assert.Equal(t, http.StatusOK, serveWithRole(models.RoleOrganizer, create).Code)
+	assert.Equal(t, http.StatusForbidden, serveWithRole(models.RoleAttendee, create).Code)
+}
@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout gives each request's context a deadline, which cancels the database
+// queries it is still running when the deadline passes or the client goes
+// away. Routes in exempt, such as long-lived streams, get no deadline. A zero
+// timeout disables it.
+func Timeout(timeout time.Duration, exempt ...string) gin.HandlerFunc {
+	skip := map[string]bool{}
+	for _, route := range exempt {
+		skip[route] = true
+	}
+
+	return func(context *gin.Context) {
+		if timeout <= 0 || skip[context.FullPath()] {
+			context.Next()
+			return
+		}
+
+		request, cancel := withTimeout(context.Request, timeout)
+		defer cancel()
+
+		context.Request = request
+		context.Next()
+
+		// Handlers report failed queries themselves; this covers those that
+		// gave up without writing anything
+		if timedOut(request) && !context.Writer.Written() {
+			context.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"message": "Request timed out"})
+		}
+	}
+}
+
+func withTimeout(r *http.Request, timeout time.Duration) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	return r.WithContext(ctx), cancel
+}
+
+func timedOut(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.DeadlineExceeded)
+}
@@ -0,0 +1,193 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0002_add_capacity.up.sql":     {Data: []byte("ALTER TABLE events ADD COLUMN capacity INT;")},
+		"sql/0002_add_capacity.down.sql":   {Data: []byte("ALTER TABLE events DROP COLUMN capacity;")},
+		"sql/0001_initial_schema.up.sql":   {Data: []byte("CREATE TABLE users (id INT);")},
+		"sql/0001_initial_schema.down.sql": {Data: []byte("DROP TABLE users;")},
+	}
+
+	migrations, err := Load(fsys, "sql")
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "initial_schema", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE users;", migrations[0].Down)
+	assert.Equal(t, int64(2), migrations[1].Version)
+	assert.Len(t, migrations[1].Checksum, 64)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "Missing up script",
+			fsys: fstest.MapFS{"sql/0001_init.down.sql": {Data: []byte("DROP TABLE users;")}},
+		},
+		{
+			name: "Invalid version",
+			fsys: fstest.MapFS{"sql/abc_init.up.sql": {Data: []byte("SELECT 1;")}},
+		},
+		{
+			name: "Missing direction",
+			fsys: fstest.MapFS{"sql/0001_init.sql": {Data: []byte("SELECT 1;")}},
+		},
+		{
+			name: "Conflicting names",
+			fsys: fstest.MapFS{
+				"sql/0001_init.up.sql":  {Data: []byte("SELECT 1;")},
+				"sql/0001_other.up.sql": {Data: []byte("SELECT 2;")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys, "sql")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	mysql, err := Load(files, "mysql")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, mysql)
+
+	sqlite, err := Load(files, "sqlite")
+	assert.NoError(t, err)
+
+	// Every schema change must be written for both backends
+	assert.Equal(t, len(mysql), len(sqlite))
+
+	for i, migration := range mysql {
+		assert.NotEmpty(t, migration.Down, "migration %d_%s has no down script", migration.Version, migration.Name)
+		if i > 0 {
+			assert.Greater(t, migration.Version, mysql[i-1].Version)
+		}
+		if i < len(sqlite) {
+			assert.Equal(t, migration.Version, sqlite[i].Version)
+			assert.Equal(t, migration.Name, sqlite[i].Name)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `
+-- create things; with a semicolon in a comment
+CREATE TABLE a (id INT);
+INSERT INTO a (name) VALUES ('semi;colon');
+
+CREATE TABLE b (id INT)
+`
+
+	statements := splitStatements(script)
+
+	assert.Equal(t, []string{
+		"CREATE TABLE a (id INT)",
+		"INSERT INTO a (name) VALUES ('semi;colon')",
+		"CREATE TABLE b (id INT)",
+	}, statements)
+}
+
+func TestMigrator_Up(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	migrations := []Migration{
+		{Version: 1, Name: "initial_schema", Up: "CREATE TABLE users (id INT);", Checksum: "aaa"},
+		{Version: 2, Name: "add_capacity", Up: "ALTER TABLE events ADD COLUMN capacity INT;", Checksum: "bbb"},
+	}
+
+	mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, name, checksum, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"}).
+			AddRow(1, "initial_schema", "aaa", time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER TABLE events ADD COLUMN capacity INT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).
+		WithArgs(int64(2), "add_capacity", "bbb", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT RELEASE_LOCK\(\?\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = NewWithMigrations(mockDB, "mysql", migrations).Up()
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Up_ChecksumMismatch(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	migrations := []Migration{
+		{Version: 1, Name: "initial_schema", Up: "CREATE TABLE users (id INT);", Checksum: "changed"},
+	}
+
+	mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, name, checksum, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"}).
+			AddRow(1, "initial_schema", "original", time.Now()))
+	mock.ExpectExec(`SELECT RELEASE_LOCK\(\?\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = NewWithMigrations(mockDB, "mysql", migrations).Up()
+	assert.ErrorContains(t, err, "checksum mismatch")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Up_LockTimeout(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(0))
+
+	err = NewWithMigrations(mockDB, "mysql", nil).Up()
+	assert.ErrorIs(t, err, ErrLockTimeout)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Down(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	migrations := []Migration{
+		{Version: 1, Name: "initial_schema", Up: "CREATE TABLE users (id INT);", Down: "DROP TABLE users;", Checksum: "aaa"},
+		{Version: 2, Name: "add_capacity", Up: "ALTER TABLE events ADD COLUMN capacity INT;", Down: "ALTER TABLE events DROP COLUMN capacity;", Checksum: "bbb"},
+	}
+
+	mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, name, checksum, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"}).
+			AddRow(1, "initial_schema", "aaa", time.Now()).
+			AddRow(2, "add_capacity", "bbb", time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER TABLE events DROP COLUMN capacity`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM schema_migrations WHERE version = \?`).
+		WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT RELEASE_LOCK\(\?\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = NewWithMigrations(mockDB, "mysql", migrations).Down(1)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,304 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+const (
+	lockName    = "go_events_schema_migrations"
+	lockTimeout = 60 // seconds
+)
+
+// ErrLockTimeout is returned when another instance holds the migration lock for too long
+var ErrLockTimeout = errors.New("timed out waiting for the schema migration lock")
+
+// Migrator applies and rolls back migrations against a database
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// AppliedMigration is a row of the schema_migrations tracking table
+type AppliedMigration struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Status describes the state of a single migration
+type Status struct {
+	Version   int64
+	Name      string
+	State     string // "applied", "pending", "checksum mismatch" or "missing file"
+	AppliedAt *time.Time
+}
+
+// New creates a migrator using the migrations embedded in the binary for the
+// given dialect ("mysql" or "sqlite")
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := Load(files, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithMigrations(db, dialect, migrations), nil
+}
+
+// NewWithMigrations creates a migrator for an explicit set of migrations
+func NewWithMigrations(db *sql.DB, dialect string, migrations []Migration) *Migrator {
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}
+}
+
+// Up applies every pending migration in version order
+func (m *Migrator) Up() error {
+	return m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if err := m.verifyChecksums(applied); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, done := applied[migration.Version]; done {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+
+			log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+		}
+
+		return nil
+	})
+}
+
+// Down rolls back the most recently applied migrations, steps at a time
+func (m *Migrator) Down(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("steps must be positive, got %d", steps)
+	}
+
+	return m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if err := m.verifyChecksums(applied); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if _, done := applied[migration.Version]; !done {
+				continue
+			}
+
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+
+			log.Printf("Rolled back migration %d_%s", migration.Version, migration.Name)
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// Status reports every known and applied migration
+func (m *Migrator) Status() ([]Status, error) {
+	ctx := context.Background()
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	known := map[int64]bool{}
+
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		status := Status{Version: migration.Version, Name: migration.Name, State: "pending"}
+
+		if row, done := applied[migration.Version]; done {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+			status.State = "applied"
+			if row.Checksum != migration.Checksum {
+				status.State = "checksum mismatch"
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	for version, row := range applied {
+		if known[version] {
+			continue
+		}
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, Status{Version: version, Name: row.Name, State: "missing file", AppliedAt: &appliedAt})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// withLock runs fn on a dedicated connection that holds the migration lock so
+// that concurrently starting instances apply migrations one at a time
+func (m *Migrator) withLock(fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// SQLite serializes writers on the database file and runs DDL inside the
+	// migration's transaction, so a racing instance fails on the
+	// schema_migrations primary key and rolls back instead of applying twice
+	if m.dialect == "sqlite" {
+		return fn(ctx, conn)
+	}
+
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, lockTimeout).Scan(&acquired)
+	if err != nil {
+		return err
+	}
+
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return ErrLockTimeout
+	}
+
+	defer func() {
+		if _, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName); err != nil {
+			log.Printf("Error releasing migration lock: %v", err)
+		}
+	}()
+
+	return fn(ctx, conn)
+}
+
+func ensureTrackingTable(ctx context.Context, conn *sql.Conn) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`
+
+	_, err := conn.ExecContext(ctx, query)
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]AppliedMigration, error) {
+	if err := ensureTrackingTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]AppliedMigration{}
+	for rows.Next() {
+		var row AppliedMigration
+		if err := rows.Scan(&row.Version, &row.Name, &row.Checksum, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[row.Version] = row
+	}
+
+	return applied, rows.Err()
+}
+
+// verifyChecksums refuses to continue if an applied migration was edited after the fact
+func (m *Migrator) verifyChecksums(applied map[int64]AppliedMigration) error {
+	for _, migration := range m.migrations {
+		row, done := applied[migration.Version]
+		if done && row.Checksum != migration.Checksum {
+			return fmt.Errorf("checksum mismatch for applied migration %d_%s: the file was modified after it was applied",
+				migration.Version, migration.Name)
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range splitStatements(migration.Up) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`,
+		migration.Version, migration.Name, migration.Checksum, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	if migration.Down == "" {
+		return fmt.Errorf("migration %d_%s has no down script", migration.Version, migration.Name)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range splitStatements(migration.Down) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("rollback of %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, migration.Version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
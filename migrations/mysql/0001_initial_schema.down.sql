@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS events_registry;
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS users;
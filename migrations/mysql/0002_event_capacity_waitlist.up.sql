@@ -0,0 +1,7 @@
+ALTER TABLE events ADD COLUMN capacity INT NULL;
+ALTER TABLE events ADD COLUMN waitlist_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE events_registry ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'registered';
+ALTER TABLE events_registry ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_events_registry_event_status ON events_registry (event_id, status, created_at);
@@ -0,0 +1,6 @@
+-- Keep the earliest row of any duplicate registrations before enforcing uniqueness
+DELETE r1 FROM events_registry r1
+INNER JOIN events_registry r2
+    ON r1.event_id = r2.event_id AND r1.user_id = r2.user_id AND r1.id > r2.id;
+
+CREATE UNIQUE INDEX uq_events_registry_event_user ON events_registry (event_id, user_id);
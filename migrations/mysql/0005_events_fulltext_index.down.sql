@@ -0,0 +1 @@
+ALTER TABLE events DROP INDEX ft_events_name_description;
@@ -0,0 +1 @@
+ALTER TABLE events ADD FULLTEXT INDEX ft_events_name_description (name, description);
@@ -0,0 +1,12 @@
+DROP TABLE event_exceptions;
+
+-- Keep the earliest registration per user before the old unique index returns
+DELETE r1 FROM events_registry r1
+INNER JOIN events_registry r2
+    ON r1.event_id = r2.event_id AND r1.user_id = r2.user_id AND r1.id > r2.id;
+
+CREATE UNIQUE INDEX uq_events_registry_event_user ON events_registry (event_id, user_id);
+DROP INDEX uq_events_registry_event_user_occurrence ON events_registry;
+ALTER TABLE events_registry DROP COLUMN occurrence;
+
+ALTER TABLE events DROP COLUMN rrule;
@@ -0,0 +1,18 @@
+ALTER TABLE events ADD COLUMN rrule VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Registrations for a recurring event are per occurrence, keyed by the
+-- occurrence's original start in UTC; one-off events use ''
+ALTER TABLE events_registry ADD COLUMN occurrence VARCHAR(20) NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX uq_events_registry_event_user_occurrence ON events_registry (event_id, user_id, occurrence);
+DROP INDEX uq_events_registry_event_user ON events_registry;
+
+CREATE TABLE event_exceptions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    event_id INT NOT NULL,
+    occurrence VARCHAR(20) NOT NULL,
+    canceled BOOLEAN NOT NULL DEFAULT FALSE,
+    new_start DATETIME NULL,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX uq_event_exceptions_event_occurrence ON event_exceptions (event_id, occurrence);
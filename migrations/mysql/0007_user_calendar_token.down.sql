@@ -0,0 +1,2 @@
+DROP INDEX uq_users_calendar_token_hash ON users;
+ALTER TABLE users DROP COLUMN calendar_token_hash;
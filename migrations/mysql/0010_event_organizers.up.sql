@@ -0,0 +1,11 @@
+-- Co-organizers can edit an event alongside its owner (events.user_id)
+CREATE TABLE event_organizers (
+    event_id INT NOT NULL,
+    user_id INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (event_id, user_id),
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_event_organizers_user ON event_organizers (user_id);
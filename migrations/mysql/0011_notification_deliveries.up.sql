@@ -0,0 +1,17 @@
+-- One row per notification and channel, tracking whether it was delivered.
+-- Failed attempts are retried at next_attempt_at until max attempts is reached.
+CREATE TABLE notification_deliveries (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    notification_id INT NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    next_attempt_at DATETIME NOT NULL,
+    sent_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (notification_id) REFERENCES notifications(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX uq_notification_deliveries_notification_channel ON notification_deliveries (notification_id, channel);
+CREATE INDEX idx_notification_deliveries_status_next_attempt ON notification_deliveries (status, next_attempt_at);
@@ -0,0 +1,2 @@
+DROP TABLE reminder_deliveries;
+DROP TABLE reminder_schedules;
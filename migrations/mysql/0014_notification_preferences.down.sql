@@ -0,0 +1,3 @@
+DROP TABLE quiet_hours;
+DROP TABLE notification_preferences;
+ALTER TABLE notifications DROP COLUMN in_app;
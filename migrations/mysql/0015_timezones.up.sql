@@ -0,0 +1,6 @@
+-- dateTime is stored in UTC; timezone is the IANA zone it is shown in and
+-- that recurrences follow
+ALTER TABLE events ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- Reminders and quiet hours are in the user's timezone
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
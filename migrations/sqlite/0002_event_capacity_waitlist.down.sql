@@ -0,0 +1,7 @@
+DROP INDEX idx_events_registry_event_status;
+
+ALTER TABLE events_registry DROP COLUMN created_at;
+ALTER TABLE events_registry DROP COLUMN status;
+
+ALTER TABLE events DROP COLUMN waitlist_enabled;
+ALTER TABLE events DROP COLUMN capacity;
@@ -0,0 +1,9 @@
+ALTER TABLE events ADD COLUMN capacity INTEGER NULL;
+ALTER TABLE events ADD COLUMN waitlist_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE events_registry ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'registered';
+-- SQLite cannot add a column with a non-constant default, so backfill instead
+ALTER TABLE events_registry ADD COLUMN created_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+UPDATE events_registry SET created_at = datetime('now');
+
+CREATE INDEX idx_events_registry_event_status ON events_registry (event_id, status, created_at);
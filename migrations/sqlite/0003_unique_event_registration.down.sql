@@ -0,0 +1 @@
+DROP INDEX uq_events_registry_event_user;
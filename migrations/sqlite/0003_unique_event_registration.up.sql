@@ -0,0 +1,5 @@
+-- Keep the earliest row of any duplicate registrations before enforcing uniqueness
+DELETE FROM events_registry
+WHERE id NOT IN (SELECT MIN(id) FROM events_registry GROUP BY event_id, user_id);
+
+CREATE UNIQUE INDEX uq_events_registry_event_user ON events_registry (event_id, user_id);
@@ -0,0 +1 @@
+DROP INDEX idx_events_datetime;
@@ -0,0 +1 @@
+CREATE INDEX idx_events_datetime ON events (dateTime, id);
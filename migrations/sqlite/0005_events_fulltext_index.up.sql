@@ -0,0 +1,2 @@
+-- SQLite has no FULLTEXT index; event search falls back to LIKE matching
+-- (see sqliteDialect.Search). Kept so versions line up across backends.
@@ -0,0 +1,11 @@
+DROP TABLE event_exceptions;
+
+-- Keep the earliest registration per user before the old unique index returns
+DELETE FROM events_registry
+WHERE id NOT IN (SELECT MIN(id) FROM events_registry GROUP BY event_id, user_id);
+
+CREATE UNIQUE INDEX uq_events_registry_event_user ON events_registry (event_id, user_id);
+DROP INDEX uq_events_registry_event_user_occurrence;
+ALTER TABLE events_registry DROP COLUMN occurrence;
+
+ALTER TABLE events DROP COLUMN rrule;
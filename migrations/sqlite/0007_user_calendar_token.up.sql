@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN calendar_token_hash CHAR(64) NULL;
+
+CREATE UNIQUE INDEX uq_users_calendar_token_hash ON users (calendar_token_hash);
@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN tokens_valid_after;
+
+DROP TABLE revoked_tokens;
+DROP TABLE refresh_tokens;
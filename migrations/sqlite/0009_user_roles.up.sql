@@ -0,0 +1,3 @@
+-- Everyone could create events before roles existed, so existing and new
+-- users default to organizer; admins are promoted by hand or via the API
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'organizer';
@@ -0,0 +1,32 @@
+-- How long before an occurrence starts reminders are sent, as a comma
+-- separated list of minutes; '' sends none. A row with only event_id is the
+-- organizer's schedule for the event, one with only user_id is the user's
+-- default and one with both is the user's override for that event.
+CREATE TABLE reminder_schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NULL,
+    user_id INTEGER NULL,
+    offsets VARCHAR(255) NOT NULL,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_reminder_schedules_event_id ON reminder_schedules (event_id);
+CREATE INDEX idx_reminder_schedules_user_id ON reminder_schedules (user_id);
+
+-- One row per reminder sent, or skipped because a later one was already due,
+-- so every (user, occurrence, offset) is handled exactly once
+CREATE TABLE reminder_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    event_id INTEGER NOT NULL,
+    occurrence VARCHAR(20) NOT NULL,
+    offset_minutes INTEGER NOT NULL,
+    notification_id INTEGER NULL,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX uq_reminder_deliveries_user_event_offset ON reminder_deliveries (user_id, event_id, occurrence, offset_minutes);
+CREATE INDEX idx_reminder_deliveries_event_id ON reminder_deliveries (event_id);
@@ -0,0 +1,26 @@
+-- Notifications a user turned off in the app are kept only for their other
+-- channels and left out of the inbox and stream
+ALTER TABLE notifications ADD COLUMN in_app BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Per type and channel toggles; a missing row means enabled
+CREATE TABLE notification_preferences (
+    user_id INTEGER NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, type, channel),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Daily windows, in minutes after midnight in timezone, during which
+-- deliveries wait. A window may cross midnight (start_minute > end_minute).
+CREATE TABLE quiet_hours (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    start_minute INTEGER NOT NULL,
+    end_minute INTEGER NOT NULL,
+    timezone VARCHAR(64) NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_quiet_hours_user_id ON quiet_hours (user_id);
@@ -0,0 +1,3 @@
+ALTER TABLE notifications DROP COLUMN message_params;
+ALTER TABLE notifications DROP COLUMN message_key;
+ALTER TABLE users DROP COLUMN locale;
@@ -0,0 +1,7 @@
+-- Notifications are rendered in the reader's locale
+ALTER TABLE users ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT 'en';
+
+-- message_key and message_params (JSON) name the catalog message; message
+-- holds it rendered in the default locale
+ALTER TABLE notifications ADD COLUMN message_key VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE notifications ADD COLUMN message_params TEXT;
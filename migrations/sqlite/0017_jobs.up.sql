@@ -0,0 +1,28 @@
+-- The background job queue. A worker claims a queued job by setting its
+-- status to running with a lease (locked_by, locked_until) in a conditional
+-- UPDATE, so each run goes to one replica; a job whose lease runs out is
+-- claimed again. Failed jobs are retried at run_at and end up dead after
+-- their last attempt.
+CREATE TABLE jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type VARCHAR(64) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_at DATETIME NOT NULL,
+    locked_by VARCHAR(128) NULL,
+    locked_until DATETIME NULL,
+    last_error TEXT NULL,
+    created_at DATETIME NOT NULL,
+    finished_at DATETIME NULL
+);
+
+CREATE INDEX idx_jobs_status_run_at ON jobs (status, run_at);
+
+-- Recurring jobs. Whichever replica moves next_run_at forward enqueues the run.
+CREATE TABLE job_schedules (
+    name VARCHAR(64) NOT NULL PRIMARY KEY,
+    type VARCHAR(64) NOT NULL,
+    spec VARCHAR(100) NOT NULL,
+    next_run_at DATETIME NOT NULL
+);
@@ -0,0 +1,12 @@
+-- Changes to events, stored in the same transaction as the change. Every
+-- replica reads them in id order to push them to its own WebSocket
+-- subscribers; rows are pruned once every replica has long since read them.
+CREATE TABLE event_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL,
+    type VARCHAR(40) NOT NULL,
+    data TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_event_changes_created_at ON event_changes (created_at);
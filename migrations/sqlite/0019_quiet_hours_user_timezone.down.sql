@@ -0,0 +1,3 @@
+ALTER TABLE quiet_hours ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+UPDATE quiet_hours SET timezone = (SELECT u.timezone FROM users u WHERE u.id = quiet_hours.user_id);
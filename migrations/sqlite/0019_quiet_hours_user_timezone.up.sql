@@ -0,0 +1,9 @@
+-- Quiet hours follow the user's settings timezone. Users who never chose one
+-- take the zone of their first window, so their quiet hours keep meaning the
+-- same times.
+UPDATE users SET timezone = (
+    SELECT q.timezone FROM quiet_hours q WHERE q.user_id = users.id ORDER BY q.id LIMIT 1
+)
+WHERE timezone = 'UTC' AND EXISTS (SELECT 1 FROM quiet_hours q WHERE q.user_id = users.id);
+
+ALTER TABLE quiet_hours DROP COLUMN timezone;
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const attendeeCursorSort = "attendees"
+
+// Attendee is one registration for an event together with the user's email.
+// RegistrationID orders attendees by when they registered.
+type Attendee struct {
+	RegistrationID int64     `json:"registration_id"`
+	UserID         int64     `json:"user_id"`
+	Email          string    `json:"email"`
+	Occurrence     string    `json:"occurrence"`
+	Status         string    `json:"status"`
+	RegisteredAt   time.Time `json:"registered_at"`
+}
+
+// AttendeeQuery selects the attendees of one event. Occurrence and Status are
+// optional filters; a nil Occurrence lists every occurrence.
+type AttendeeQuery struct {
+	EventID    int64
+	Occurrence *string
+	Status     string
+	Cursor     string
+	Limit      int
+}
+
+// AttendeePage is one page of attendees. NextCursor is empty on the last page.
+type AttendeePage struct {
+	Attendees  []Attendee `json:"attendees"`
+	NextCursor string     `json:"next_cursor"`
+	Total      int64      `json:"total"`
+}
+
+// Normalize fills in the page size and rejects unknown statuses
+func (q *AttendeeQuery) Normalize() error {
+	switch q.Status {
+	case "", RegistrationStatusRegistered, RegistrationStatusWaitlisted:
+	default:
+		return errors.New("status must be registered or waitlisted")
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultEventPageSize
+	}
+
+	if q.Limit > MaxEventPageSize {
+		q.Limit = MaxEventPageSize
+	}
+
+	return nil
+}
+
+func (q *AttendeeQuery) filters() ([]string, []any) {
+	conditions := []string{"r.event_id = ?"}
+	args := []any{q.EventID}
+
+	if q.Occurrence != nil {
+		conditions = append(conditions, "r.occurrence = ?")
+		args = append(args, *q.Occurrence)
+	}
+
+	if q.Status != "" {
+		conditions = append(conditions, "r.status = ?")
+		args = append(args, q.Status)
+	}
+
+	return conditions, args
+}
+
+// List returns one page of an event's attendees in registration order
+func (r sqlAttendees) List(ctx context.Context, q AttendeeQuery) (*AttendeePage, error) {
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	conditions, args := q.filters()
+
+	var total int64
+
+	err := r.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM events_registry r"+where(conditions), args...).Scan(&total)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Cursor != "" {
+		cursor, err := decodeEventCursor(q.Cursor, attendeeCursorSort)
+
+		if err != nil {
+			return nil, err
+		}
+
+		conditions = append(conditions, "r.id > ?")
+		args = append(args, cursor.ID)
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	attendees, err := r.query(ctx, conditions, append(args, q.Limit+1), " LIMIT ?")
+
+	if err != nil {
+		return nil, err
+	}
+
+	page := AttendeePage{Attendees: attendees, Total: total}
+
+	if len(page.Attendees) > q.Limit {
+		page.Attendees = page.Attendees[:q.Limit]
+		last := page.Attendees[len(page.Attendees)-1]
+		page.NextCursor = encodeEventCursor(eventCursor{Sort: attendeeCursorSort, ID: last.RegistrationID})
+	}
+
+	return &page, nil
+}
+
+// All returns every attendee matching the query, for exports.
+// Cursor and Limit are ignored.
+func (r sqlAttendees) All(ctx context.Context, q AttendeeQuery) ([]Attendee, error) {
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	conditions, args := q.filters()
+
+	return r.query(ctx, conditions, args, "")
+}
+
+func (r sqlAttendees) query(ctx context.Context, conditions []string, args []any, limit string) ([]Attendee, error) {
+	query := `
+		SELECT r.id, r.user_id, u.email, r.occurrence, r.status, r.created_at
+		FROM events_registry r
+		INNER JOIN users u ON u.id = r.user_id` + where(conditions) + `
+		ORDER BY r.id` + limit
+
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	attendees := []Attendee{}
+
+	for rows.Next() {
+		var attendee Attendee
+
+		err := rows.Scan(&attendee.RegistrationID, &attendee.UserID, &attendee.Email, &attendee.Occurrence,
+			&attendee.Status, &attendee.RegisteredAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		attendees = append(attendees, attendee)
+	}
+
+	return attendees, rows.Err()
+}
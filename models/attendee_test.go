@@ -0,0 +1,49 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListAttendees(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 4)
+	capacity := int64(2)
+	event := createTestEvent(t, users[0].ID, &capacity, true)
+
+	for _, user := range users[1:] {
+		registration := EventRegister{EventID: event.ID, UserID: user.ID}
+		assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registration))
+	}
+
+	first, err := testRepositories().Attendees.List(context.Background(), AttendeeQuery{EventID: event.ID, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), first.Total)
+	assert.Len(t, first.Attendees, 2)
+	assert.Equal(t, users[1].Email, first.Attendees[0].Email)
+	assert.Equal(t, RegistrationStatusRegistered, first.Attendees[0].Status)
+	assert.NotEmpty(t, first.NextCursor)
+
+	second, err := testRepositories().Attendees.List(context.Background(), AttendeeQuery{EventID: event.ID, Limit: 2, Cursor: first.NextCursor})
+	assert.NoError(t, err)
+	assert.Len(t, second.Attendees, 1)
+	assert.Equal(t, users[3].ID, second.Attendees[0].UserID)
+	assert.Equal(t, RegistrationStatusWaitlisted, second.Attendees[0].Status)
+	assert.Empty(t, second.NextCursor)
+
+	waitlisted, err := testRepositories().Attendees.All(context.Background(), AttendeeQuery{EventID: event.ID, Status: RegistrationStatusWaitlisted})
+	assert.NoError(t, err)
+	assert.Len(t, waitlisted, 1)
+
+	_, err = testRepositories().Attendees.List(context.Background(), AttendeeQuery{EventID: event.ID, Status: "maybe"})
+	assert.Error(t, err)
+
+	_, err = testRepositories().Attendees.List(context.Background(), AttendeeQuery{EventID: event.ID, Cursor: "bogus"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
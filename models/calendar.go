@@ -0,0 +1,165 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/rest-api/ical"
+	"example.com/rest-api/utils"
+)
+
+const calendarProdID = "-//go-events//Go Events API//EN"
+
+// NewCalendarToken gives the user a new calendar feed token, invalidating the
+// previous one. Only a hash of the token is stored.
+func (r sqlTokens) NewCalendarToken(ctx context.Context, userID int64) (string, error) {
+	token, err := utils.RandomToken(32)
+
+	if err != nil {
+		return "", err
+	}
+
+	_, err = r.conn.ExecContext(ctx, `UPDATE users SET calendar_token_hash = ? WHERE id = ?`, utils.HashToken(token), userID)
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// UserByCalendarToken returns the owner of a calendar feed token, or
+// sql.ErrNoRows if no user has it
+func (r sqlTokens) UserByCalendarToken(ctx context.Context, token string) (*User, error) {
+	if token == "" {
+		return nil, sql.ErrNoRows
+	}
+
+	query := `SELECT id, email FROM users WHERE calendar_token_hash = ?`
+	row := r.conn.QueryRowContext(ctx, query, utils.HashToken(token))
+
+	var user User
+
+	err := row.Scan(&user.ID, &user.Email)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Calendar renders an event as iCalendar. A recurring event is a single
+// series with skipped dates as EXDATEs and moved dates as overrides, written
+// in the event's timezone so it repeats at the same local time the way
+// occurrences are expanded.
+func (r sqlEvents) Calendar(ctx context.Context, event *Event) (*ical.Calendar, error) {
+	calendar := ical.Calendar{ProdID: calendarProdID}
+	now := time.Now()
+
+	master := icalEvent(event, eventUID(event.ID), now)
+	master.RRule = event.RRule
+
+	if event.RRule != "" {
+		master.TimeZone = event.Zone()
+	}
+
+	var overrides []ical.Event
+
+	if event.RRule != "" {
+		exceptions, err := r.getEventExceptions(ctx, []int64{event.ID})
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys := make([]string, 0, len(exceptions[event.ID]))
+		for key := range exceptions[event.ID] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			exception := exceptions[event.ID][key]
+			start, err := parseOccurrenceKey(key)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if exception.Canceled {
+				master.ExDates = append(master.ExDates, start)
+				continue
+			}
+
+			if exception.NewStart != nil {
+				override := icalEvent(event, master.UID, now)
+				override.Start = *exception.NewStart
+				override.RecurrenceID = start
+				override.TimeZone = master.TimeZone
+				overrides = append(overrides, override)
+			}
+		}
+	}
+
+	calendar.Events = append([]ical.Event{master}, overrides...)
+
+	return &calendar, nil
+}
+
+// Calendar renders the user's registrations as a subscribable feed. Each
+// registered occurrence of a series is its own event, since the user may
+// only attend some of them.
+func (r sqlUsers) Calendar(ctx context.Context, user *User) (*ical.Calendar, error) {
+	entries, err := r.registrations(ctx, user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := ical.Calendar{ProdID: calendarProdID, Name: "Go Events: " + user.Email}
+	now := time.Now()
+
+	for _, entry := range entries {
+		uid := eventUID(entry.ID)
+
+		if entry.Occurrence.Occurrence != "" {
+			compact := strings.NewReplacer("-", "", ":", "").Replace(entry.Occurrence.Occurrence)
+			uid = fmt.Sprintf("event-%d-%s@go-events", entry.ID, compact)
+		}
+
+		event := icalEvent(&entry.Event, uid, now)
+
+		switch {
+		case entry.Canceled:
+			event.Status = "CANCELLED"
+		case entry.Status == RegistrationStatusWaitlisted:
+			event.Status = "TENTATIVE"
+		default:
+			event.Status = "CONFIRMED"
+		}
+
+		calendar.Events = append(calendar.Events, event)
+	}
+
+	return &calendar, nil
+}
+
+func eventUID(eventID int64) string {
+	return fmt.Sprintf("event-%d@go-events", eventID)
+}
+
+func icalEvent(event *Event, uid string, stamp time.Time) ical.Event {
+	return ical.Event{
+		UID:         uid,
+		Stamp:       stamp,
+		Start:       event.DateTime,
+		Summary:     event.Name,
+		Description: event.Description,
+		Location:    event.Location,
+	}
+}
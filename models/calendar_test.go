@@ -0,0 +1,142 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendarToken(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+
+	first, err := testRepositories().Tokens.NewCalendarToken(context.Background(), users[0].ID)
+	assert.NoError(t, err)
+	assert.Len(t, first, 64)
+
+	user, err := testRepositories().Tokens.UserByCalendarToken(context.Background(), first)
+	assert.NoError(t, err)
+	assert.Equal(t, users[0].ID, user.ID)
+
+	// Rotating invalidates the old token
+	second, err := testRepositories().Tokens.NewCalendarToken(context.Background(), users[0].ID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	_, err = testRepositories().Tokens.UserByCalendarToken(context.Background(), first)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = testRepositories().Tokens.UserByCalendarToken(context.Background(), "")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestEventCalendar_Series(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	weekly := createWeeklyEvent(t, users[0].ID, nil)
+
+	skip := EventException{EventID: weekly.ID, Occurrence: "2030-01-14T18:00:00Z", Canceled: true}
+	assert.NoError(t, testRepositories().Events.SaveException(context.Background(), &skip))
+
+	moved := time.Date(2030, 1, 22, 19, 0, 0, 0, time.UTC)
+	move := EventException{EventID: weekly.ID, Occurrence: "2030-01-21T18:00:00Z", NewStart: &moved}
+	assert.NoError(t, testRepositories().Events.SaveException(context.Background(), &move))
+
+	calendar, err := testRepositories().Events.Calendar(context.Background(), &weekly)
+	assert.NoError(t, err)
+	assert.Len(t, calendar.Events, 2)
+
+	encoded := calendar.String()
+	assert.Contains(t, encoded, "RRULE:FREQ=WEEKLY;COUNT=4\r\n")
+	assert.Contains(t, encoded, "EXDATE:20300114T180000Z\r\n")
+	assert.Contains(t, encoded, "RECURRENCE-ID:20300121T180000Z\r\nSUMMARY:Weekly Meetup\r\n")
+	assert.Contains(t, encoded, "DTSTART:20300122T190000Z\r\n")
+	assert.Equal(t, 2, strings.Count(encoded, "UID:event-1@go-events\r\n"))
+}
+
+func TestEventCalendar_SeriesInEventTimezone(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	weekly := Event{Name: "Weekly Meetup", Description: "Every Monday", Location: "New York",
+		DateTime: time.Date(2030, 1, 7, 18, 0, 0, 0, newYork), UserID: users[0].ID,
+		RRule: "FREQ=WEEKLY;COUNT=12", Timezone: "America/New_York"}
+	assert.NoError(t, testRepositories().Events.Save(context.Background(), &weekly))
+
+	// After daylight saving starts the series still meets at 18:00 local time
+	skip := EventException{EventID: weekly.ID, Occurrence: "2030-03-11T22:00:00Z", Canceled: true}
+	assert.NoError(t, testRepositories().Events.SaveException(context.Background(), &skip))
+
+	moved := time.Date(2030, 1, 15, 0, 0, 0, 0, time.UTC)
+	move := EventException{EventID: weekly.ID, Occurrence: "2030-01-14T23:00:00Z", NewStart: &moved}
+	assert.NoError(t, testRepositories().Events.SaveException(context.Background(), &move))
+
+	calendar, err := testRepositories().Events.Calendar(context.Background(), &weekly)
+	assert.NoError(t, err)
+
+	encoded := calendar.String()
+	assert.Contains(t, encoded, "BEGIN:VTIMEZONE\r\nTZID:America/New_York\r\n")
+	assert.Contains(t, encoded, "TZOFFSETFROM:-0500\r\nTZOFFSETTO:-0400\r\n")
+	assert.Contains(t, encoded, "DTSTART;TZID=America/New_York:20300107T180000\r\n")
+	assert.Contains(t, encoded, "EXDATE;TZID=America/New_York:20300311T180000\r\n")
+	assert.Contains(t, encoded, "DTSTART;TZID=America/New_York:20300114T190000\r\n")
+	assert.Contains(t, encoded, "RECURRENCE-ID;TZID=America/New_York:20300114T180000\r\n")
+	assert.NotContains(t, encoded, "DTSTART:20300107")
+}
+
+func TestUserCalendar(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 2)
+	capacity := int64(1)
+	weekly := createWeeklyEvent(t, users[0].ID, &capacity)
+	weekly.WaitlistEnabled = true
+	assert.NoError(t, testRepositories().Events.Update(context.Background(), &weekly))
+	oneOff := createTestEvent(t, users[0].ID, nil, false)
+
+	for _, registration := range []EventRegister{
+		{EventID: weekly.ID, UserID: users[0].ID, Occurrence: "2030-01-07T18:00:00Z"},
+		{EventID: weekly.ID, UserID: users[1].ID, Occurrence: "2030-01-07T18:00:00Z"},
+		{EventID: weekly.ID, UserID: users[1].ID, Occurrence: "2030-01-14T18:00:00Z"},
+		{EventID: oneOff.ID, UserID: users[1].ID},
+	} {
+		assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registration))
+	}
+
+	skip := EventException{EventID: weekly.ID, Occurrence: "2030-01-14T18:00:00Z", Canceled: true}
+	assert.NoError(t, testRepositories().Events.SaveException(context.Background(), &skip))
+
+	entries, err := testRepositories().Users.(sqlUsers).registrations(context.Background(), users[1].ID)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	calendar, err := testRepositories().Users.Calendar(context.Background(), &users[1])
+	assert.NoError(t, err)
+
+	encoded := calendar.String()
+	assert.Contains(t, encoded, "X-WR-CALNAME:Go Events: user1@example.com\r\n")
+	assert.Contains(t, encoded, "UID:event-1-20300107T180000Z@go-events\r\n")
+	assert.Contains(t, encoded, "STATUS:TENTATIVE\r\n")
+	assert.Contains(t, encoded, "UID:event-1-20300114T180000Z@go-events\r\n")
+	assert.Contains(t, encoded, "STATUS:CANCELLED\r\n")
+	assert.Contains(t, encoded, "UID:event-2@go-events\r\n")
+	assert.NotContains(t, encoded, "RRULE")
+}
@@ -0,0 +1,240 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	DeliveryStatusPending = "pending"
+	DeliveryStatusSent    = "sent"
+	DeliveryStatusFailed  = "failed"
+
+	DeliveryChannelEmail = "email"
+)
+
+// maxDeliveryBackoff caps the wait between retries of a failed delivery
+const maxDeliveryBackoff = time.Hour
+
+// deliveryClaim is how long a claimed delivery is held for its sender. If the
+// sender dies before recording the attempt, the delivery is due again after
+// this.
+const deliveryClaim = 10 * time.Minute
+
+// Delivery tracks sending one notification over one channel
+type Delivery struct {
+	ID             int64
+	NotificationID int64
+	Channel        string
+	Status         string
+	Attempts       int
+	LastError      string
+	NextAttemptAt  time.Time
+	SentAt         *time.Time
+	CreatedAt      time.Time
+}
+
+// PendingDelivery is a delivery that is due, with what is needed to send it
+type PendingDelivery struct {
+	Delivery
+	Notification Notification
+	Email        string
+	Settings     UserSettings // the recipient's
+	EventName    string
+	EventTime    time.Time // in the recipient's timezone
+}
+
+// queueDelivery records that the notification still has to be sent over the
+// channel, not before the given time
+func queueDelivery(ctx context.Context, p preparer, notificationID int64, channel string, notBefore time.Time) error {
+	query := `
+		INSERT INTO notification_deliveries (notification_id, channel, status, attempts, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	stmt, err := p.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, notificationID, channel, DeliveryStatusPending, 0, notBefore.UTC(), time.Now().UTC())
+	return err
+}
+
+// Pending returns up to limit deliveries over the channel whose
+// next attempt is due, oldest first
+func (r sqlDeliveries) Pending(ctx context.Context, channel string, limit int) ([]PendingDelivery, error) {
+	query := `
+		SELECT d.id, d.notification_id, d.channel, d.status, d.attempts, d.last_error, d.next_attempt_at, d.created_at,
+			n.user_id, n.event_id, n.message, n.type, n.created_at, n.message_key, n.message_params,
+			u.email, u.timezone, u.locale, e.name, e.dateTime
+		FROM notification_deliveries d
+		INNER JOIN notifications n ON n.id = d.notification_id
+		INNER JOIN users u ON u.id = n.user_id
+		INNER JOIN events e ON e.id = n.event_id
+		WHERE d.channel = ? AND d.status = ? AND ` + r.dialect.Timestamp("d.next_attempt_at") + ` <= ` + r.dialect.Timestamp("?") + `
+		ORDER BY d.id
+		LIMIT ?
+	`
+	rows, err := r.conn.QueryContext(ctx, query, channel, DeliveryStatusPending, time.Now().UTC(), limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var deliveries []PendingDelivery
+
+	for rows.Next() {
+		var delivery PendingDelivery
+		var lastError, params sql.NullString
+		var key string
+
+		err := rows.Scan(&delivery.ID, &delivery.NotificationID, &delivery.Channel, &delivery.Status, &delivery.Attempts,
+			&lastError, &delivery.NextAttemptAt, &delivery.CreatedAt,
+			&delivery.Notification.UserID, &delivery.Notification.EventID, &delivery.Notification.Message,
+			&delivery.Notification.Type, &delivery.Notification.CreatedAt, &key, &params,
+			&delivery.Email, &delivery.Settings.Timezone, &delivery.Settings.Locale, &delivery.EventName, &delivery.EventTime)
+
+		if err != nil {
+			return nil, err
+		}
+
+		delivery.Notification.Localized, err = decodeLocalizedMessage(key, params)
+
+		if err != nil {
+			return nil, err
+		}
+
+		delivery.EventTime = delivery.EventTime.In(delivery.Settings.Location())
+
+		delivery.LastError = lastError.String
+		delivery.Notification.ID = delivery.NotificationID
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// claimDelivery reserves a pending delivery in the table for the caller by
+// moving its next attempt past the claim period, and reports whether it got
+// it. The conditional UPDATE lets only one of several racing senders win.
+func (s sqlStore) claimDelivery(ctx context.Context, table string, id int64) (bool, error) {
+	now := time.Now().UTC()
+
+	query := `UPDATE ` + table + ` SET next_attempt_at = ? WHERE id = ? AND status = ? AND ` +
+		s.dialect.Timestamp("next_attempt_at") + ` <= ` + s.dialect.Timestamp("?")
+	result, err := s.conn.ExecContext(ctx, query, now.Add(deliveryClaim), id, DeliveryStatusPending, now)
+
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// Claim reserves the delivery for sending. It reports false if another run
+// claimed it first, in which case it must not be sent.
+func (r sqlDeliveries) Claim(ctx context.Context, d *Delivery) (bool, error) {
+	return r.claimDelivery(ctx, "notification_deliveries", d.ID)
+}
+
+// MarkSent records a successful delivery
+func (r sqlDeliveries) MarkSent(ctx context.Context, d *Delivery) error {
+	now := time.Now().UTC()
+	d.Attempts++
+
+	query := `UPDATE notification_deliveries SET status = ?, attempts = ?, sent_at = ? WHERE id = ?`
+	_, err := r.conn.ExecContext(ctx, query, DeliveryStatusSent, d.Attempts, now, d.ID)
+
+	if err != nil {
+		return err
+	}
+
+	d.Status, d.SentAt = DeliveryStatusSent, &now
+
+	return nil
+}
+
+// MarkFailed records a failed attempt. The delivery is retried with
+// exponential backoff until maxAttempts is reached, then marked failed.
+func (r sqlDeliveries) MarkFailed(ctx context.Context, d *Delivery, sendErr error, maxAttempts int) error {
+	d.Attempts++
+	d.LastError = sendErr.Error()
+	d.NextAttemptAt = time.Now().UTC().Add(deliveryBackoff(d.Attempts))
+	d.Status = DeliveryStatusPending
+
+	if d.Attempts >= maxAttempts {
+		d.Status = DeliveryStatusFailed
+	}
+
+	query := `UPDATE notification_deliveries SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ? WHERE id = ?`
+	_, err := r.conn.ExecContext(ctx, query, d.Status, d.Attempts, d.LastError, d.NextAttemptAt, d.ID)
+
+	return err
+}
+
+// Defer postpones the next attempt without counting one, e.g. until the
+// recipient's quiet hours are over
+func (r sqlDeliveries) Defer(ctx context.Context, d *Delivery, until time.Time) error {
+	d.NextAttemptAt = until.UTC()
+
+	query := `UPDATE notification_deliveries SET next_attempt_at = ? WHERE id = ?`
+	_, err := r.conn.ExecContext(ctx, query, d.NextAttemptAt, d.ID)
+
+	return err
+}
+
+// deliveryBackoff waits one minute after the first failure, doubling each time
+func deliveryBackoff(attempts int) time.Duration {
+	backoff := time.Minute
+
+	for i := 1; i < attempts && backoff < maxDeliveryBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxDeliveryBackoff)
+}
+
+// ForNotification lists the deliveries of a notification
+func (r sqlDeliveries) ForNotification(ctx context.Context, notificationID int64) ([]Delivery, error) {
+	query := `
+		SELECT id, notification_id, channel, status, attempts, last_error, next_attempt_at, sent_at, created_at
+		FROM notification_deliveries WHERE notification_id = ? ORDER BY id
+	`
+	rows, err := r.conn.QueryContext(ctx, query, notificationID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var deliveries []Delivery
+
+	for rows.Next() {
+		var delivery Delivery
+		var lastError sql.NullString
+
+		err := rows.Scan(&delivery.ID, &delivery.NotificationID, &delivery.Channel, &delivery.Status, &delivery.Attempts,
+			&lastError, &delivery.NextAttemptAt, &delivery.SentAt, &delivery.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		delivery.LastError = lastError.String
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
@@ -1,49 +1,328 @@
 package models
 
-import "example.com/rest-api/db"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
 
+const (
+	RegistrationStatusRegistered = "registered"
+	RegistrationStatusWaitlisted = "waitlisted"
+)
+
+var (
+	ErrEventFull          = errors.New("event is full")
+	ErrAlreadyRegistered  = errors.New("already registered for event")
+	ErrNotRegistered      = errors.New("not registered for event")
+	ErrOccurrenceCanceled = errors.New("occurrence is canceled")
+)
+
+// EventRegister is a user's place at an event. Occurrence is the key of the
+// occurrence of a recurring event and empty for a one-off event.
 type EventRegister struct {
-	ID int64
-	EventID int64
-	UserID int64
+	ID         int64
+	EventID    int64
+	UserID     int64
+	Occurrence string
+	Status     string
+	CreatedAt  time.Time
+	// Promoted is the waitlisted registration Cancel confirmed in its place
+	Promoted *EventRegister `json:"-"`
 }
 
-func (ER *EventRegister) Register() error {
-	query := `INSERT INTO events_registry (event_id, user_id) VALUES (?, ?)`
+// Register adds the user to the event, or to its waitlist once capacity is
+// reached. The event row is locked so concurrent registrations cannot
+// overfill it.
+func (r sqlRegistrations) Register(ctx context.Context, ER *EventRegister) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var event Event
+	var capacity sql.NullInt64
+	var waitlistEnabled bool
 
-	stmt, err := db.DB.Prepare(query)
+	query := `SELECT user_id, dateTime, rrule, timezone, capacity, waitlist_enabled FROM events WHERE id = ?` + r.dialect.ForUpdate()
+	err = tx.QueryRowContext(ctx, query, ER.EventID).Scan(&event.UserID, &event.DateTime, &event.RRule, &event.Timezone, &capacity, &waitlistEnabled)
 
 	if err != nil {
 		return err
 	}
 
-	defer stmt.Close()
+	if !event.ValidOccurrence(ER.Occurrence) {
+		return ErrInvalidOccurrence
+	}
+
+	if ER.Occurrence != "" {
+		var canceled bool
+
+		query = `SELECT canceled FROM event_exceptions WHERE event_id = ? AND occurrence = ?`
+		err = tx.QueryRowContext(ctx, query, ER.EventID, ER.Occurrence).Scan(&canceled)
+
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		if canceled {
+			return ErrOccurrenceCanceled
+		}
+	}
+
+	var existingStatus string
+
+	query = `SELECT status FROM events_registry WHERE event_id = ? AND user_id = ? AND occurrence = ?`
+	err = tx.QueryRowContext(ctx, query, ER.EventID, ER.UserID, ER.Occurrence).Scan(&existingStatus)
+
+	if err == nil {
+		return ErrAlreadyRegistered
+	}
+
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	ER.Status = RegistrationStatusRegistered
+
+	if capacity.Valid {
+		registered, err := countRegistered(ctx, tx, ER.EventID, ER.Occurrence)
+
+		if err != nil {
+			return err
+		}
+
+		if registered >= capacity.Int64 {
+			if !waitlistEnabled {
+				return ErrEventFull
+			}
+
+			ER.Status = RegistrationStatusWaitlisted
+		}
+	}
+
+	ER.CreatedAt = time.Now().UTC()
 
-	_, err = stmt.Exec(ER.EventID, ER.UserID)
+	query = `INSERT INTO events_registry (event_id, user_id, occurrence, status, created_at) VALUES (?, ?, ?, ?, ?)`
+	result, err := tx.ExecContext(ctx, query, ER.EventID, ER.UserID, ER.Occurrence, ER.Status, ER.CreatedAt)
+
+	// The unique (event_id, user_id, occurrence) index catches a concurrent duplicate
+	if r.dialect.IsUniqueViolation(err) {
+		return ErrAlreadyRegistered
+	}
 
 	if err != nil {
 		return err
 	}
 
+	ER.ID, err = result.LastInsertId()
+
+	if err != nil {
+		return err
+	}
+
+	counts, err := countAttendees(ctx, tx, ER.EventID, ER.Occurrence)
+
+	if err != nil {
+		return err
+	}
+
+	if err := queueEventWebhooks(ctx, tx, ER.EventID, event.UserID, WebhookRegistrationCreated, *ER); err != nil {
+		return err
+	}
+
+	change := EventChange{Type: EventChangeRegistrationCreated, EventID: ER.EventID, Occurrence: ER.Occurrence, Counts: counts}
+
+	if err := storeEventChange(ctx, tx, &change); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	eventChanged(change)
+
 	return nil
 }
 
-func (ER *EventRegister) Cancel() error {
-	query := `DELETE FROM events_registry WHERE event_id = ? AND user_id = ?`
+// Cancel removes the user's registration. When a confirmed spot is freed the
+// longest-waiting user on the waitlist is promoted and notified.
+func (r sqlRegistrations) Cancel(ctx context.Context, ER *EventRegister) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var eventName string
+	var ownerID int64
+	var capacity sql.NullInt64
+
+	query := `SELECT name, user_id, capacity FROM events WHERE id = ?` + r.dialect.ForUpdate()
+	err = tx.QueryRowContext(ctx, query, ER.EventID).Scan(&eventName, &ownerID, &capacity)
+
+	if err != nil {
+		return err
+	}
+
+	query = `SELECT status FROM events_registry WHERE event_id = ? AND user_id = ? AND occurrence = ?`
+	err = tx.QueryRowContext(ctx, query, ER.EventID, ER.UserID, ER.Occurrence).Scan(&ER.Status)
 
-	stmt, err := db.DB.Prepare(query)
+	if err == sql.ErrNoRows {
+		return ErrNotRegistered
+	}
+
+	if err != nil {
+		return err
+	}
+
+	query = `DELETE FROM events_registry WHERE event_id = ? AND user_id = ? AND occurrence = ?`
+	_, err = tx.ExecContext(ctx, query, ER.EventID, ER.UserID, ER.Occurrence)
 
 	if err != nil {
 		return err
 	}
 
-	defer stmt.Close()
+	var promotion *Notification
+
+	if ER.Status == RegistrationStatusRegistered && capacity.Valid {
+		ER.Promoted, promotion, err = promoteFromWaitlist(ctx, tx, ER.EventID, ER.Occurrence, eventName, capacity.Int64)
+
+		if err != nil {
+			return err
+		}
+	}
 
-	_, err = stmt.Exec(ER.EventID, ER.UserID)
+	counts, err := countAttendees(ctx, tx, ER.EventID, ER.Occurrence)
 
 	if err != nil {
 		return err
 	}
 
+	if err := queueEventWebhooks(ctx, tx, ER.EventID, ownerID, WebhookRegistrationCanceled, *ER); err != nil {
+		return err
+	}
+
+	if ER.Promoted != nil {
+		if err := queueEventWebhooks(ctx, tx, ER.EventID, ownerID, WebhookRegistrationPromoted, ER.Promoted); err != nil {
+			return err
+		}
+	}
+
+	change := EventChange{Type: EventChangeRegistrationCanceled, EventID: ER.EventID, Occurrence: ER.Occurrence, Counts: counts}
+
+	if err := storeEventChange(ctx, tx, &change); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if promotion != nil {
+		notificationCreated(*promotion)
+	}
+
+	eventChanged(change)
+
 	return nil
-}
\ No newline at end of file
+}
+
+// promoteFromWaitlist confirms the earliest waitlisted user if a spot is
+// free, returning their registration and the notification telling them
+func promoteFromWaitlist(ctx context.Context, tx *sql.Tx, eventID int64, occurrence, eventName string, capacity int64) (*EventRegister, *Notification, error) {
+	registered, err := countRegistered(ctx, tx, eventID, occurrence)
+
+	if err != nil || registered >= capacity {
+		return nil, nil, err
+	}
+
+	next := EventRegister{EventID: eventID, Occurrence: occurrence}
+
+	query := `
+		SELECT id, user_id, created_at FROM events_registry
+		WHERE event_id = ? AND occurrence = ? AND status = ?
+		ORDER BY created_at, id
+		LIMIT 1
+	`
+	err = tx.QueryRowContext(ctx, query, eventID, occurrence, RegistrationStatusWaitlisted).Scan(&next.ID, &next.UserID, &next.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE events_registry SET status = ? WHERE id = ?`, RegistrationStatusRegistered, next.ID)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	next.Status = RegistrationStatusRegistered
+
+	message := LocalizedMessage{Key: "waitlist_promoted", Params: MessageParams{Event: eventName}}
+
+	if occurrence != "" {
+		start, err := parseOccurrenceKey(occurrence)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		message.Key, message.Params.Start = "waitlist_promoted.occurrence", start
+	}
+
+	notification := Notification{
+		UserID:    next.UserID,
+		EventID:   eventID,
+		Type:      NotificationTypeWaitlistPromoted,
+		CreatedAt: time.Now(),
+		Localized: &message,
+	}
+
+	if err := notification.saveWith(ctx, tx); err != nil {
+		return nil, nil, err
+	}
+
+	return &next, &notification, nil
+}
+
+func countRegistered(ctx context.Context, tx *sql.Tx, eventID int64, occurrence string) (int64, error) {
+	var count int64
+
+	query := `SELECT COUNT(*) FROM events_registry WHERE event_id = ? AND occurrence = ? AND status = ?`
+	err := tx.QueryRowContext(ctx, query, eventID, occurrence, RegistrationStatusRegistered).Scan(&count)
+
+	return count, err
+}
+
+// Get returns the user's registration for an event occurrence, or
+// sql.ErrNoRows if there is none
+func (r sqlRegistrations) Get(ctx context.Context, eventID, userID int64, occurrence string) (*EventRegister, error) {
+	query := `
+		SELECT id, event_id, user_id, occurrence, status, created_at FROM events_registry
+		WHERE event_id = ? AND user_id = ? AND occurrence = ?
+	`
+	row := r.conn.QueryRowContext(ctx, query, eventID, userID, occurrence)
+
+	var registration EventRegister
+
+	err := row.Scan(&registration.ID, &registration.EventID, &registration.UserID, &registration.Occurrence,
+		&registration.Status, &registration.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &registration, nil
+}
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// testRepositories returns repositories over the database the test set up
+func testRepositories() Repositories {
+	return NewSQLRepositories(db.DB, db.Dialect)
+}
+
+func createTestUsers(t *testing.T, count int) []User {
+	users := make([]User, count)
+
+	for i := range users {
+		users[i] = User{Email: fmt.Sprintf("user%d@example.com", i), Password: "hashed"}
+		assert.NoError(t, testRepositories().Users.Save(context.Background(), &users[i]))
+	}
+
+	return users
+}
+
+func createTestEvent(t *testing.T, ownerID int64, capacity *int64, waitlist bool) Event {
+	event := Event{
+		Name:            "Limited Workshop",
+		Description:     "Only a few seats",
+		Location:        "Room 1",
+		DateTime:        time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second),
+		UserID:          ownerID,
+		Capacity:        capacity,
+		WaitlistEnabled: waitlist,
+	}
+	assert.NoError(t, testRepositories().Events.Save(context.Background(), &event))
+
+	return event
+}
+
+func TestEventRegister_CapacityWithoutWaitlist(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 2)
+	capacity := int64(1)
+	event := createTestEvent(t, users[0].ID, &capacity, false)
+
+	first := EventRegister{EventID: event.ID, UserID: users[0].ID}
+	assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &first))
+	assert.Equal(t, RegistrationStatusRegistered, first.Status)
+
+	second := EventRegister{EventID: event.ID, UserID: users[1].ID}
+	assert.ErrorIs(t, testRepositories().Registrations.Register(context.Background(), &second), ErrEventFull)
+}
+
+func TestEventRegister_UnlimitedCapacity(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 3)
+	event := createTestEvent(t, users[0].ID, nil, false)
+
+	for _, user := range users {
+		registration := EventRegister{EventID: event.ID, UserID: user.ID}
+		assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registration))
+		assert.Equal(t, RegistrationStatusRegistered, registration.Status)
+	}
+}
+
+func TestEventRegister_WaitlistPromotion(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 3)
+	capacity := int64(1)
+	event := createTestEvent(t, users[0].ID, &capacity, true)
+
+	var registrations []EventRegister
+	for _, user := range users {
+		registration := EventRegister{EventID: event.ID, UserID: user.ID}
+		assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registration))
+		registrations = append(registrations, registration)
+	}
+
+	assert.Equal(t, RegistrationStatusRegistered, registrations[0].Status)
+	assert.Equal(t, RegistrationStatusWaitlisted, registrations[1].Status)
+	assert.Equal(t, RegistrationStatusWaitlisted, registrations[2].Status)
+
+	// Freeing the only spot promotes the user who joined the waitlist first
+	assert.NoError(t, testRepositories().Registrations.Cancel(context.Background(), &registrations[0]))
+	assert.NotNil(t, registrations[0].Promoted)
+	assert.Equal(t, users[1].ID, registrations[0].Promoted.UserID)
+	assert.Equal(t, RegistrationStatusRegistered, registrations[0].Promoted.Status)
+
+	notifications, err := testRepositories().Notifications.GetByUserID(context.Background(), users[1].ID)
+	assert.NoError(t, err)
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, NotificationTypeWaitlistPromoted, notifications[0].Type)
+	assert.Contains(t, notifications[0].Message, event.Name)
+
+	notifications, err = testRepositories().Notifications.GetByUserID(context.Background(), users[2].ID)
+	assert.NoError(t, err)
+	assert.Empty(t, notifications)
+
+	// A waitlisted user leaving does not promote anyone
+	assert.NoError(t, testRepositories().Registrations.Cancel(context.Background(), &registrations[2]))
+	assert.Nil(t, registrations[2].Promoted)
+
+	third := EventRegister{EventID: event.ID, UserID: users[2].ID}
+	assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &third))
+	assert.Equal(t, RegistrationStatusWaitlisted, third.Status)
+}
+
+func TestEventRegister_Duplicate(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	event := createTestEvent(t, users[0].ID, nil, false)
+
+	registration := EventRegister{EventID: event.ID, UserID: users[0].ID}
+	assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registration))
+
+	again := EventRegister{EventID: event.ID, UserID: users[0].ID}
+	assert.ErrorIs(t, testRepositories().Registrations.Register(context.Background(), &again), ErrAlreadyRegistered)
+
+	stored, err := testRepositories().Registrations.Get(context.Background(), event.ID, users[0].ID, "")
+	assert.NoError(t, err)
+	assert.Equal(t, registration.ID, stored.ID)
+	assert.Equal(t, RegistrationStatusRegistered, stored.Status)
+}
+
+func TestEventRegister_CancelWithoutRegistration(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	event := createTestEvent(t, users[0].ID, nil, false)
+
+	registration := EventRegister{EventID: event.ID, UserID: users[0].ID}
+	assert.ErrorIs(t, testRepositories().Registrations.Cancel(context.Background(), &registration), ErrNotRegistered)
+
+	assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registration))
+	assert.NoError(t, testRepositories().Registrations.Cancel(context.Background(), &registration))
+	assert.ErrorIs(t, testRepositories().Registrations.Cancel(context.Background(), &registration), ErrNotRegistered)
+
+	_, err = testRepositories().Registrations.Get(context.Background(), event.ID, users[0].ID, "")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestSQLiteDialect_IsUniqueViolation(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	event := createTestEvent(t, users[0].ID, nil, false)
+
+	query := `INSERT INTO events_registry (event_id, user_id, status, created_at) VALUES (?, ?, ?, ?)`
+	_, err = db.DB.Exec(query, event.ID, users[0].ID, RegistrationStatusRegistered, time.Now())
+	assert.NoError(t, err)
+
+	_, err = db.DB.Exec(query, event.ID, users[0].ID, RegistrationStatusRegistered, time.Now())
+	assert.True(t, db.Dialect.IsUniqueViolation(err))
+	assert.False(t, db.Dialect.IsUniqueViolation(sql.ErrNoRows))
+}
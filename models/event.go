@@ -1,28 +1,139 @@
 package models
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"time"
 
-	"example.com/rest-api/db"
+	"example.com/rest-api/rrule"
 )
 
 type Event struct {
-	ID          int64
-	Name        string    `binding:"required"`
-	Description string    `binding:"required"`
-	Location    string    `binding:"required"`
-	DateTime    time.Time `binding:"required"`
-	UserID      int64
+	ID              int64
+	Name            string    `binding:"required"`
+	Description     string    `binding:"required"`
+	Location        string    `binding:"required"`
+	DateTime        time.Time `binding:"required"`
+	UserID          int64
+	Capacity        *int64 `binding:"omitempty,min=1"` // nil means unlimited
+	WaitlistEnabled bool   // join a waitlist instead of being refused once full
+	RRule           string // iCalendar recurrence rule; empty for a one-off event
+	Timezone        string // IANA zone DateTime is shown in and recurrences follow; UTC by default
 }
 
+// DefaultTimezone is the zone of events and users that have not chosen one
+const DefaultTimezone = "UTC"
+
+var ErrInvalidTimezone = errors.New("timezone must be an IANA name such as Asia/Dhaka")
+
+const eventColumns = "id, name, description, location, dateTime, user_id, capacity, waitlist_enabled, rrule, timezone"
+
+// qualifiedEventColumns is eventColumns prefixed with a table alias, for joins
+func qualifiedEventColumns(alias string) string {
+	return alias + "." + strings.ReplaceAll(eventColumns, ", ", ", "+alias+".")
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanEvent reads the eventColumns of a row, followed by any extra columns.
+// DateTime is stored in UTC and returned in the event's timezone.
+func scanEvent(row rowScanner, extra ...any) (Event, error) {
+	var event Event
+
+	dest := []any{&event.ID, &event.Name, &event.Description, &event.Location, &event.DateTime, &event.UserID,
+		&event.Capacity, &event.WaitlistEnabled, &event.RRule, &event.Timezone}
+
+	err := row.Scan(append(dest, extra...)...)
+
+	if err != nil {
+		return event, err
+	}
+
+	event.DateTime = event.DateTime.In(event.Zone())
+
+	return event, nil
+}
+
+// Zone returns the event's timezone, or UTC if it has none or it is unknown
+func (e *Event) Zone() *time.Location {
+	return loadZone(e.Timezone)
+}
+
+// NormalizeTimezone validates the timezone, defaulting it to UTC, and moves
+// DateTime into it
+func (e *Event) NormalizeTimezone() error {
+	if e.Timezone == "" {
+		e.Timezone = DefaultTimezone
+	}
+
+	if !validTimezone(e.Timezone) {
+		return ErrInvalidTimezone
+	}
+
+	e.DateTime = e.DateTime.In(e.Zone())
+
+	return nil
+}
+
+// loadZone loads a stored IANA zone name, falling back to UTC
+func loadZone(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+
+	location, err := time.LoadLocation(name)
+
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+// Recurrence returns the parsed RRule, or nil for a one-off event
+func (e *Event) Recurrence() (*rrule.Rule, error) {
+	if e.RRule == "" {
+		return nil, nil
+	}
+
+	return rrule.Parse(e.RRule)
+}
+
+// NormalizeRRule validates the RRule and rewrites it in canonical form
+func (e *Event) NormalizeRRule() error {
+	rule, err := e.Recurrence()
+
+	if err != nil || rule == nil {
+		return err
+	}
+
+	e.RRule = rule.String()
+
+	return nil
+}
+
+func (r sqlEvents) Save(ctx context.Context, e *Event) error {
+	if e.Timezone == "" {
+		e.Timezone = DefaultTimezone
+	}
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
 
-func (e *Event) Save() error {
 	query := `
-		INSERT INTO events (name, description, location, dateTime, user_id)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO events (name, description, location, dateTime, user_id, capacity, waitlist_enabled, rrule, timezone)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	stmt, err := db.DB.Prepare(query)
+	stmt, err := tx.PrepareContext(ctx, query)
 
 	if err != nil {
 		return err
@@ -30,7 +141,8 @@ func (e *Event) Save() error {
 
 	defer stmt.Close()
 
-	result, err := stmt.Exec(e.Name, e.Description, e.Location, e.DateTime, e.UserID)
+	result, err := stmt.ExecContext(ctx, e.Name, e.Description, e.Location, e.DateTime.UTC(), e.UserID, e.Capacity, e.WaitlistEnabled, e.RRule,
+		e.Timezone)
 
 	if err != nil {
 		return err
@@ -38,87 +150,123 @@ func (e *Event) Save() error {
 
 	id, err := result.LastInsertId()
 
+	if err != nil {
+		return err
+	}
+
 	e.ID = id
 
-	return err
+	if err := queueEventWebhooks(ctx, tx, e.ID, e.UserID, WebhookEventCreated, *e); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (e *Event) Update() error {
+func (r sqlEvents) Update(ctx context.Context, e *Event) error {
+	if e.Timezone == "" {
+		e.Timezone = DefaultTimezone
+	}
+
 	query := `
-		UPDATE events SET name = ?, description = ?, location = ?, dateTime = ?
+		UPDATE events SET name = ?, description = ?, location = ?, dateTime = ?, capacity = ?, waitlist_enabled = ?,
+			rrule = ?, timezone = ?
 		WHERE id = ?
 	`
 
-	stmt, err := db.DB.Prepare(query)
+	tx, err := r.conn.BeginTx(ctx, nil)
 
 	if err != nil {
 		return err
 	}
 
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	_, err = stmt.Exec(e.Name, e.Description, e.Location, e.DateTime, e.ID)
+	stmt, err := tx.PrepareContext(ctx, query)
 
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
-
-func (e *Event) Delete() error {
-	query := "DELETE FROM events WHERE id = ?"
+	defer stmt.Close()
 
-	stmt, err := db.DB.Prepare(query)
+	_, err = stmt.ExecContext(ctx, e.Name, e.Description, e.Location, e.DateTime.UTC(), e.Capacity, e.WaitlistEnabled, e.RRule,
+		e.Timezone, e.ID)
 
 	if err != nil {
 		return err
 	}
 
-	defer stmt.Close()
+	if err := queueEventWebhooks(ctx, tx, e.ID, e.UserID, WebhookEventUpdated, *e); err != nil {
+		return err
+	}
 
-	_, err = stmt.Exec(e.ID)
+	updated := *e
+	change := EventChange{Type: EventChangeUpdated, EventID: e.ID, Event: &updated}
 
-	if err != nil {
+	if err := storeEventChange(ctx, tx, &change); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
 		return err
 	}
 
+	eventChanged(change)
+
 	return nil
 }
 
-func GetAllEvents() ([]Event, error) {
-	query := "SELECT * FROM events"
-	rows, err := db.DB.Query(query)
+func (r sqlEvents) Delete(ctx context.Context, e *Event) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var events []Event
+	// Queued first, while the co-organizers are still on record
+	if err := queueEventWebhooks(ctx, tx, e.ID, e.UserID, WebhookEventDeleted, *e); err != nil {
+		return err
+	}
 
-	for rows.Next() {
-		var event Event
+	query := "DELETE FROM events WHERE id = ?"
 
-		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location, &event.DateTime, &event.UserID)
+	stmt, err := tx.PrepareContext(ctx, query)
 
-		if err != nil {
-			return nil, err
-		}
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
 
-		events = append(events, event)
+	_, err = stmt.ExecContext(ctx, e.ID)
+
+	if err != nil {
+		return err
 	}
-	return events, nil
-}
 
-func GetEventById(eventId int64) (*Event, error) {
-	query := "SELECT * FROM events WHERE id = ?"
-	row := db.DB.QueryRow(query, eventId)
+	change := EventChange{Type: EventChangeDeleted, EventID: e.ID}
 
-	var event Event
+	if err := storeEventChange(ctx, tx, &change); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	eventChanged(change)
+
+	return nil
+}
+
+func (r sqlEvents) GetByID(ctx context.Context, eventId int64) (*Event, error) {
+	query := "SELECT " + eventColumns + " FROM events WHERE id = ?"
+	row := r.conn.QueryRowContext(ctx, query, eventId)
 
-	err := row.Scan(&event.ID, &event.Name, &event.Description, &event.Location, &event.DateTime, &event.UserID)
+	event, err := scanEvent(row)
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Event change types, named like the matching webhook event types
+const (
+	EventChangeUpdated              = "event.updated"
+	EventChangeDeleted              = "event.deleted"
+	EventChangeRegistrationCreated  = "registration.created"
+	EventChangeRegistrationCanceled = "registration.canceled"
+	EventChangeOccurrenceUpdated    = "occurrence.updated"
+	EventChangeOccurrenceRestored   = "occurrence.restored"
+)
+
+// AttendeeCounts are the registrations of one occurrence by status
+type AttendeeCounts struct {
+	Registered int64 `json:"registered"`
+	Waitlisted int64 `json:"waitlisted"`
+}
+
+// EventChange describes a stored change to an event. Event is set for
+// updates; Occurrence and Counts for registration changes, with Counts taken
+// in the same transaction as the change; Occurrence and Exception for
+// changes to one occurrence. ID orders the changes of every replica.
+type EventChange struct {
+	ID         int64
+	Type       string
+	EventID    int64
+	Event      *Event
+	Occurrence string
+	Counts     *AttendeeCounts
+	Exception  *EventException
+}
+
+// eventChangeData is how the details of a change are stored
+type eventChangeData struct {
+	Event      *Event          `json:"event,omitempty"`
+	Occurrence string          `json:"occurrence,omitempty"`
+	Counts     *AttendeeCounts `json:"counts,omitempty"`
+	Exception  *EventException `json:"exception,omitempty"`
+}
+
+// eventListeners are called with every change once it is stored
+var eventListeners []func(EventChange)
+
+// OnEventChanged registers a function to call with every event change this
+// process stores, once it is committed. Changes stored by other replicas are
+// read with ChangesAfter. Like notification listeners they are registered at
+// startup and must not block.
+func OnEventChanged(listener func(EventChange)) {
+	eventListeners = append(eventListeners, listener)
+}
+
+func eventChanged(change EventChange) {
+	for _, listener := range eventListeners {
+		listener(change)
+	}
+}
+
+// storeEventChange records the change inside tx, so it is stored if and only
+// if the change is, and sets its ID
+func storeEventChange(ctx context.Context, tx *sql.Tx, change *EventChange) error {
+	data, err := json.Marshal(eventChangeData{
+		Event:      change.Event,
+		Occurrence: change.Occurrence,
+		Counts:     change.Counts,
+		Exception:  change.Exception,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO event_changes (event_id, type, data, created_at) VALUES (?, ?, ?, ?)`
+	result, err := tx.ExecContext(ctx, query, change.EventID, change.Type, string(data), time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	change.ID, err = result.LastInsertId()
+
+	return err
+}
+
+// ChangesAfter returns the changes stored by any replica after the one with
+// afterID, oldest first
+func (r sqlEvents) ChangesAfter(ctx context.Context, afterID int64, limit int) ([]EventChange, error) {
+	query := `SELECT id, event_id, type, data FROM event_changes WHERE id > ? ORDER BY id LIMIT ?`
+	rows, err := r.conn.QueryContext(ctx, query, afterID, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	changes := []EventChange{}
+
+	for rows.Next() {
+		var change EventChange
+		var encoded string
+
+		if err := rows.Scan(&change.ID, &change.EventID, &change.Type, &encoded); err != nil {
+			return nil, err
+		}
+
+		var data eventChangeData
+
+		if err := json.Unmarshal([]byte(encoded), &data); err != nil {
+			return nil, err
+		}
+
+		change.Event, change.Occurrence, change.Counts, change.Exception = data.Event, data.Occurrence, data.Counts, data.Exception
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
+
+// LastChangeID is the id of the latest stored change, 0 if none
+func (r sqlEvents) LastChangeID(ctx context.Context) (int64, error) {
+	var id int64
+	err := r.conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM event_changes`).Scan(&id)
+
+	return id, err
+}
+
+// PruneChanges deletes changes stored before the given time
+func (r sqlEvents) PruneChanges(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.conn.ExecContext(ctx, `DELETE FROM event_changes WHERE created_at < ?`, before.UTC())
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// countAttendees counts the registrations of an occurrence inside tx
+func countAttendees(ctx context.Context, tx *sql.Tx, eventID int64, occurrence string) (*AttendeeCounts, error) {
+	var counts AttendeeCounts
+
+	query := `
+		SELECT
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0)
+		FROM events_registry WHERE event_id = ? AND occurrence = ?
+	`
+	err := tx.QueryRowContext(ctx, query, RegistrationStatusRegistered, RegistrationStatusWaitlisted, eventID, occurrence).
+		Scan(&counts.Registered, &counts.Waitlisted)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &counts, nil
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnEventChanged(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	var changes []EventChange
+	OnEventChanged(func(change EventChange) { changes = append(changes, change) })
+	t.Cleanup(func() { eventListeners = nil })
+
+	users := createTestUsers(t, 3)
+	capacity := int64(1)
+	event := createTestEvent(t, users[0].ID, &capacity, true)
+
+	registrations := make([]EventRegister, 2)
+	for i := range registrations {
+		registrations[i] = EventRegister{EventID: event.ID, UserID: users[i+1].ID}
+		assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registrations[i]))
+	}
+
+	assert.Len(t, changes, 2)
+	assert.Equal(t, EventChangeRegistrationCreated, changes[1].Type)
+	assert.Equal(t, event.ID, changes[1].EventID)
+	assert.Equal(t, AttendeeCounts{Registered: 1, Waitlisted: 1}, *changes[1].Counts)
+
+	// The promotion is already reflected in the counts
+	assert.NoError(t, testRepositories().Registrations.Cancel(context.Background(), &registrations[0]))
+	assert.Equal(t, EventChangeRegistrationCanceled, changes[2].Type)
+	assert.Equal(t, AttendeeCounts{Registered: 1, Waitlisted: 0}, *changes[2].Counts)
+
+	// Failed changes are not announced
+	assert.ErrorIs(t, testRepositories().Registrations.Cancel(context.Background(), &registrations[0]), ErrNotRegistered)
+	assert.Len(t, changes, 3)
+
+	event.Name = "Renamed"
+	assert.NoError(t, testRepositories().Events.Update(context.Background(), &event))
+	assert.Equal(t, EventChangeUpdated, changes[3].Type)
+	assert.Equal(t, "Renamed", changes[3].Event.Name)
+
+	unused := createTestEvent(t, users[0].ID, nil, false)
+	assert.NoError(t, testRepositories().Events.Delete(context.Background(), &unused))
+	assert.Equal(t, EventChange{ID: changes[4].ID, Type: EventChangeDeleted, EventID: unused.ID}, changes[4])
+}
+
+func TestEventChanges_StoredForEveryReplica(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	ctx := context.Background()
+	events := testRepositories().Events
+
+	users := createTestUsers(t, 2)
+	weekly := createWeeklyEvent(t, users[0].ID, nil)
+
+	// The previous owner stays on as a co-organizer, so still hears of changes
+	hook := Webhook{UserID: users[0].ID, URL: "https://owner.example.com/hooks"}
+	assert.NoError(t, testRepositories().Webhooks.Save(ctx, &hook))
+
+	start, err := events.LastChangeID(ctx)
+	assert.NoError(t, err)
+
+	assert.NoError(t, events.TransferOwnership(ctx, &weekly, users[1].ID))
+
+	skip := EventException{EventID: weekly.ID, Occurrence: "2030-01-14T18:00:00Z", Canceled: true}
+	assert.NoError(t, events.SaveException(ctx, &skip))
+	assert.NoError(t, events.DeleteException(ctx, weekly.ID, skip.Occurrence))
+
+	// A failed change stores nothing
+	assert.ErrorIs(t, events.DeleteException(ctx, weekly.ID, skip.Occurrence), ErrExceptionNotFound)
+
+	deliveries, err := testRepositories().Webhooks.Deliveries(ctx, hook.ID, 0)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 3)
+
+	changes, err := events.ChangesAfter(ctx, start, 10)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 3)
+
+	assert.Equal(t, EventChangeUpdated, changes[0].Type)
+	assert.Equal(t, users[1].ID, changes[0].Event.UserID)
+
+	assert.Equal(t, EventChangeOccurrenceUpdated, changes[1].Type)
+	assert.Equal(t, skip.Occurrence, changes[1].Occurrence)
+	assert.True(t, changes[1].Exception.Canceled)
+
+	assert.Equal(t, EventChangeOccurrenceRestored, changes[2].Type)
+	assert.Equal(t, skip.Occurrence, changes[2].Occurrence)
+	assert.Less(t, changes[1].ID, changes[2].ID)
+
+	last, err := events.LastChangeID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, changes[2].ID, last)
+
+	page, err := events.ChangesAfter(ctx, changes[0].ID, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []EventChange{changes[1]}, page)
+
+	pruned, err := events.PruneChanges(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, pruned, int64(3))
+
+	changes, err = events.ChangesAfter(ctx, 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+}
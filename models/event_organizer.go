@@ -0,0 +1,183 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrAlreadyOrganizer = errors.New("user already organizes this event")
+	ErrNotOrganizer     = errors.New("user is not a co-organizer of this event")
+)
+
+// EventAccess is how much of an event a user may manage
+type EventAccess int
+
+const (
+	EventAccessNone EventAccess = iota
+	// EventAccessOrganizer may edit the event and see its attendees
+	EventAccessOrganizer
+	// EventAccessOwner may also delete the event, transfer it and choose its
+	// co-organizers
+	EventAccessOwner
+)
+
+// EventOrganizer is a co-organizer of an event
+type EventOrganizer struct {
+	EventID   int64     `json:"event_id"`
+	UserID    int64     `json:"user_id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Access returns what the user may do with the event. Admins are treated as
+// owners of every event.
+func (r sqlEvents) Access(ctx context.Context, e *Event, userID int64, role Role) (EventAccess, error) {
+	if e.UserID == userID || role.Can(PermissionManageAnyEvent) {
+		return EventAccessOwner, nil
+	}
+
+	var count int64
+	query := `SELECT COUNT(*) FROM event_organizers WHERE event_id = ? AND user_id = ?`
+	err := r.conn.QueryRowContext(ctx, query, e.ID, userID).Scan(&count)
+
+	if err != nil {
+		return EventAccessNone, err
+	}
+
+	if count > 0 {
+		return EventAccessOrganizer, nil
+	}
+
+	return EventAccessNone, nil
+}
+
+// Organizers lists the co-organizers of an event, oldest first
+func (r sqlEvents) Organizers(ctx context.Context, eventID int64) ([]EventOrganizer, error) {
+	query := `
+		SELECT o.event_id, o.user_id, u.email, o.created_at
+		FROM event_organizers o
+		INNER JOIN users u ON u.id = o.user_id
+		WHERE o.event_id = ?
+		ORDER BY o.created_at, o.user_id
+	`
+	rows, err := r.conn.QueryContext(ctx, query, eventID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	organizers := []EventOrganizer{}
+
+	for rows.Next() {
+		var organizer EventOrganizer
+
+		err := rows.Scan(&organizer.EventID, &organizer.UserID, &organizer.Email, &organizer.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		organizers = append(organizers, organizer)
+	}
+
+	return organizers, rows.Err()
+}
+
+// AddOrganizer makes the user a co-organizer of the event
+func (r sqlEvents) AddOrganizer(ctx context.Context, e *Event, userID int64) error {
+	if userID == e.UserID {
+		return ErrAlreadyOrganizer
+	}
+
+	query := `INSERT INTO event_organizers (event_id, user_id, created_at) VALUES (?, ?, ?)`
+	_, err := r.conn.ExecContext(ctx, query, e.ID, userID, time.Now().UTC())
+
+	if r.dialect.IsUniqueViolation(err) {
+		return ErrAlreadyOrganizer
+	}
+
+	return err
+}
+
+// RemoveOrganizer takes the user off the event's co-organizers
+func (r sqlEvents) RemoveOrganizer(ctx context.Context, e *Event, userID int64) error {
+	result, err := r.conn.ExecContext(ctx, `DELETE FROM event_organizers WHERE event_id = ? AND user_id = ?`, e.ID, userID)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrNotOrganizer
+	}
+
+	return nil
+}
+
+// TransferOwnership hands the event to another user. The previous owner stays
+// on as a co-organizer.
+func (r sqlEvents) TransferOwnership(ctx context.Context, e *Event, newOwnerID int64) error {
+	if newOwnerID == e.UserID {
+		return nil
+	}
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE events SET user_id = ? WHERE id = ?`, newOwnerID, e.ID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM event_organizers WHERE event_id = ? AND user_id IN (?, ?)`, e.ID, newOwnerID, e.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO event_organizers (event_id, user_id, created_at) VALUES (?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, e.ID, e.UserID, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	transferred := *e
+	transferred.UserID = newOwnerID
+
+	// Queued once the previous owner is a co-organizer, so both are told
+	if err := queueEventWebhooks(ctx, tx, e.ID, newOwnerID, WebhookEventUpdated, transferred); err != nil {
+		return err
+	}
+
+	change := EventChange{Type: EventChangeUpdated, EventID: e.ID, Event: &transferred}
+
+	if err := storeEventChange(ctx, tx, &change); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	e.UserID = newOwnerID
+	eventChanged(change)
+
+	return nil
+}
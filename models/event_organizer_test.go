@@ -0,0 +1,75 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvent_Organizers(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 3)
+	owner, coOrganizer, stranger := users[0], users[1], users[2]
+	event := createTestEvent(t, owner.ID, nil, false)
+
+	access, err := testRepositories().Events.Access(context.Background(), &event, coOrganizer.ID, RoleOrganizer)
+	assert.NoError(t, err)
+	assert.Equal(t, EventAccessNone, access)
+
+	assert.NoError(t, testRepositories().Events.AddOrganizer(context.Background(), &event, coOrganizer.ID))
+	assert.ErrorIs(t, testRepositories().Events.AddOrganizer(context.Background(), &event, coOrganizer.ID), ErrAlreadyOrganizer)
+	assert.ErrorIs(t, testRepositories().Events.AddOrganizer(context.Background(), &event, owner.ID), ErrAlreadyOrganizer)
+
+	access, err = testRepositories().Events.Access(context.Background(), &event, coOrganizer.ID, RoleOrganizer)
+	assert.NoError(t, err)
+	assert.Equal(t, EventAccessOrganizer, access)
+
+	access, err = testRepositories().Events.Access(context.Background(), &event, owner.ID, RoleAttendee)
+	assert.NoError(t, err)
+	assert.Equal(t, EventAccessOwner, access)
+
+	access, err = testRepositories().Events.Access(context.Background(), &event, stranger.ID, RoleAdmin)
+	assert.NoError(t, err)
+	assert.Equal(t, EventAccessOwner, access)
+
+	organizers, err := testRepositories().Events.Organizers(context.Background(), event.ID)
+	assert.NoError(t, err)
+	assert.Len(t, organizers, 1)
+	assert.Equal(t, coOrganizer.Email, organizers[0].Email)
+
+	assert.NoError(t, testRepositories().Events.RemoveOrganizer(context.Background(), &event, coOrganizer.ID))
+	assert.ErrorIs(t, testRepositories().Events.RemoveOrganizer(context.Background(), &event, coOrganizer.ID), ErrNotOrganizer)
+}
+
+func TestEvent_TransferOwnership(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 2)
+	owner, coOrganizer := users[0], users[1]
+	event := createTestEvent(t, owner.ID, nil, false)
+	assert.NoError(t, testRepositories().Events.AddOrganizer(context.Background(), &event, coOrganizer.ID))
+
+	assert.NoError(t, testRepositories().Events.TransferOwnership(context.Background(), &event, coOrganizer.ID))
+	assert.Equal(t, coOrganizer.ID, event.UserID)
+
+	stored, err := testRepositories().Events.GetByID(context.Background(), event.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, coOrganizer.ID, stored.UserID)
+
+	// The old owner stays on as a co-organizer, the new one is no longer listed
+	organizers, err := testRepositories().Events.Organizers(context.Background(), event.ID)
+	assert.NoError(t, err)
+	assert.Len(t, organizers, 1)
+	assert.Equal(t, owner.ID, organizers[0].UserID)
+
+	access, err := testRepositories().Events.Access(context.Background(), stored, owner.ID, RoleOrganizer)
+	assert.NoError(t, err)
+	assert.Equal(t, EventAccessOrganizer, access)
+}
@@ -0,0 +1,249 @@
+package models
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"example.com/rest-api/db"
+)
+
+const (
+	EventSortDateAsc  = "date_asc"
+	EventSortDateDesc = "date_desc"
+	EventSortCreated  = "created" // newest first
+
+	DefaultEventPageSize = 20
+	MaxEventPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EventQuery describes which events to list and in what order. Zero values
+// mean "no filter".
+type EventQuery struct {
+	From     *time.Time
+	To       *time.Time
+	Location string // case-insensitive substring
+	Name     string // case-insensitive substring
+	UserID   int64  // owner
+	Sort     string
+	Cursor   string
+	Limit    int
+	Expand   bool // list occurrences of recurring events; needs From and To
+}
+
+// EventPage is one page of results. NextCursor is empty on the last page.
+type EventPage struct {
+	Events     []Event `json:"events"`
+	NextCursor string  `json:"next_cursor"`
+	Total      int64   `json:"total"`
+}
+
+// eventCursor is the position after the last event of a page
+type eventCursor struct {
+	Sort     string    `json:"s"`
+	DateTime time.Time `json:"d,omitempty"`
+	ID       int64     `json:"i"`
+}
+
+// Normalize fills in defaults and rejects unknown sort orders
+func (q *EventQuery) Normalize() error {
+	switch q.Sort {
+	case "":
+		q.Sort = EventSortDateAsc
+	case EventSortDateAsc, EventSortDateDesc, EventSortCreated:
+	default:
+		return errors.New("sort must be one of date_asc, date_desc or created")
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultEventPageSize
+	}
+
+	if q.Limit > MaxEventPageSize {
+		q.Limit = MaxEventPageSize
+	}
+
+	if q.Expand {
+		if q.From == nil || q.To == nil || !q.To.After(*q.From) {
+			return errors.New("expand requires from and to, with to after from")
+		}
+
+		if q.To.Sub(*q.From) > MaxOccurrenceWindow {
+			return errors.New("expand can cover at most 366 days")
+		}
+
+		if q.Sort == EventSortCreated {
+			return errors.New("expand cannot be combined with sort=created")
+		}
+	}
+
+	return nil
+}
+
+// filters returns the WHERE conditions shared by the page and the total count
+func (q *EventQuery) filters(dialect db.SQLDialect) ([]string, []any) {
+	var conditions []string
+	var args []any
+
+	dateTime := dialect.Timestamp("dateTime")
+	param := dialect.Timestamp("?")
+
+	if q.From != nil {
+		conditions = append(conditions, dateTime+" >= "+param)
+		args = append(args, q.From.UTC())
+	}
+
+	if q.To != nil {
+		conditions = append(conditions, dateTime+" <= "+param)
+		args = append(args, q.To.UTC())
+	}
+
+	if q.Location != "" {
+		conditions = append(conditions, "location LIKE ? ESCAPE '!'")
+		args = append(args, likePattern(q.Location))
+	}
+
+	if q.Name != "" {
+		conditions = append(conditions, "name LIKE ? ESCAPE '!'")
+		args = append(args, likePattern(q.Name))
+	}
+
+	if q.UserID != 0 {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, q.UserID)
+	}
+
+	return conditions, args
+}
+
+// List returns one page of events matching the query
+func (r sqlEvents) List(ctx context.Context, q EventQuery) (*EventPage, error) {
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	conditions, args := q.filters(r.dialect)
+
+	var total int64
+
+	countQuery := "SELECT COUNT(*) FROM events" + where(conditions)
+	err := r.conn.QueryRowContext(ctx, countQuery, args...).Scan(&total)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dateTime := r.dialect.Timestamp("dateTime")
+	param := r.dialect.Timestamp("?")
+
+	var orderBy string
+	switch q.Sort {
+	case EventSortDateAsc:
+		orderBy = dateTime + " ASC, id ASC"
+	case EventSortDateDesc:
+		orderBy = dateTime + " DESC, id DESC"
+	case EventSortCreated:
+		orderBy = "id DESC"
+	}
+
+	if q.Cursor != "" {
+		cursor, err := decodeEventCursor(q.Cursor, q.Sort)
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch q.Sort {
+		case EventSortDateAsc:
+			conditions = append(conditions, "("+dateTime+" > "+param+" OR ("+dateTime+" = "+param+" AND id > ?))")
+			args = append(args, cursor.DateTime, cursor.DateTime, cursor.ID)
+		case EventSortDateDesc:
+			conditions = append(conditions, "("+dateTime+" < "+param+" OR ("+dateTime+" = "+param+" AND id < ?))")
+			args = append(args, cursor.DateTime, cursor.DateTime, cursor.ID)
+		case EventSortCreated:
+			conditions = append(conditions, "id < ?")
+			args = append(args, cursor.ID)
+		}
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	query := "SELECT " + eventColumns + " FROM events" + where(conditions) + " ORDER BY " + orderBy + " LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	page := EventPage{Events: []Event{}, Total: total}
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		page.Events = append(page.Events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Events) > q.Limit {
+		page.Events = page.Events[:q.Limit]
+		last := page.Events[len(page.Events)-1]
+		page.NextCursor = encodeEventCursor(eventCursor{Sort: q.Sort, DateTime: last.DateTime.UTC(), ID: last.ID})
+	}
+
+	return &page, nil
+}
+
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// likePattern matches value anywhere, treating LIKE wildcards in it literally
+func likePattern(value string) string {
+	escaper := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	return "%" + escaper.Replace(value) + "%"
+}
+
+func encodeEventCursor(cursor eventCursor) string {
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeEventCursor(value, sort string) (*eventCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor eventCursor
+
+	if err := json.Unmarshal(decoded, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// A cursor only makes sense for the sort order that produced it
+	if cursor.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func seedEvents(t *testing.T) ([]User, []Event) {
+	users := createTestUsers(t, 2)
+	base := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	specs := []struct {
+		name     string
+		location string
+		offset   time.Duration
+		owner    int
+	}{
+		{"Go Meetup", "Dhaka", 3 * 24 * time.Hour, 0},
+		{"Rust Meetup", "Chittagong", 1 * 24 * time.Hour, 0},
+		{"Go Workshop", "Dhaka Hall", 2 * 24 * time.Hour, 1},
+		{"Design Sprint", "Sylhet", 5 * 24 * time.Hour, 1},
+		{"100% Go", "Online", 4 * 24 * time.Hour, 0},
+	}
+
+	var events []Event
+	for _, spec := range specs {
+		event := Event{
+			Name:        spec.name,
+			Description: "Description",
+			Location:    spec.location,
+			DateTime:    base.Add(spec.offset),
+			UserID:      users[spec.owner].ID,
+		}
+		assert.NoError(t, testRepositories().Events.Save(context.Background(), &event))
+		events = append(events, event)
+	}
+
+	return users, events
+}
+
+func eventNames(events []Event) []string {
+	names := make([]string, len(events))
+	for i, event := range events {
+		names[i] = event.Name
+	}
+	return names
+}
+
+func TestListEvents_PaginatesByDate(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	seedEvents(t)
+
+	var names []string
+	query := EventQuery{Limit: 2}
+
+	for pages := 0; pages < 5; pages++ {
+		page, err := testRepositories().Events.List(context.Background(), query)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), page.Total)
+		names = append(names, eventNames(page.Events)...)
+
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	assert.Equal(t, []string{"Rust Meetup", "Go Workshop", "Go Meetup", "100% Go", "Design Sprint"}, names)
+}
+
+func TestListEvents_Sorts(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	seedEvents(t)
+
+	page, err := testRepositories().Events.List(context.Background(), EventQuery{Sort: EventSortDateDesc, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Design Sprint", "100% Go"}, eventNames(page.Events))
+
+	page, err = testRepositories().Events.List(context.Background(), EventQuery{Sort: EventSortDateDesc, Limit: 2, Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Go Meetup", "Go Workshop"}, eventNames(page.Events))
+
+	page, err = testRepositories().Events.List(context.Background(), EventQuery{Sort: EventSortCreated, Limit: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"100% Go", "Design Sprint", "Go Workshop"}, eventNames(page.Events))
+}
+
+func TestListEvents_Filters(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users, events := seedEvents(t)
+
+	from := events[2].DateTime
+	to := events[4].DateTime
+
+	tests := []struct {
+		name      string
+		query     EventQuery
+		wantNames []string
+	}{
+		{
+			name:      "Location substring is case-insensitive",
+			query:     EventQuery{Location: "dhaka"},
+			wantNames: []string{"Go Workshop", "Go Meetup"},
+		},
+		{
+			name:      "Name match",
+			query:     EventQuery{Name: "meetup"},
+			wantNames: []string{"Rust Meetup", "Go Meetup"},
+		},
+		{
+			name:      "Wildcards are literal",
+			query:     EventQuery{Name: "100%"},
+			wantNames: []string{"100% Go"},
+		},
+		{
+			name:      "Owner",
+			query:     EventQuery{UserID: users[1].ID},
+			wantNames: []string{"Go Workshop", "Design Sprint"},
+		},
+		{
+			name:      "Date range is inclusive",
+			query:     EventQuery{From: &from, To: &to},
+			wantNames: []string{"Go Workshop", "Go Meetup", "100% Go"},
+		},
+		{
+			name:      "Combined filters",
+			query:     EventQuery{Name: "go", Location: "dhaka", UserID: users[0].ID},
+			wantNames: []string{"Go Meetup"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := testRepositories().Events.List(context.Background(), tt.query)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantNames, eventNames(page.Events))
+			assert.Equal(t, int64(len(tt.wantNames)), page.Total)
+			assert.Empty(t, page.NextCursor)
+		})
+	}
+}
+
+func TestListEvents_InvalidInput(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	seedEvents(t)
+
+	_, err = testRepositories().Events.List(context.Background(), EventQuery{Sort: "popularity"})
+	assert.Error(t, err)
+
+	_, err = testRepositories().Events.List(context.Background(), EventQuery{Cursor: "not-a-cursor!"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	// A cursor from one sort order cannot be replayed with another
+	page, err := testRepositories().Events.List(context.Background(), EventQuery{Sort: EventSortCreated, Limit: 1})
+	assert.NoError(t, err)
+	_, err = testRepositories().Events.List(context.Background(), EventQuery{Sort: EventSortDateAsc, Cursor: page.NextCursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestEventQuery_Normalize(t *testing.T) {
+	query := EventQuery{Limit: 1000}
+	assert.NoError(t, query.Normalize())
+	assert.Equal(t, EventSortDateAsc, query.Sort)
+	assert.Equal(t, MaxEventPageSize, query.Limit)
+
+	query = EventQuery{}
+	assert.NoError(t, query.Normalize())
+	assert.Equal(t, DefaultEventPageSize, query.Limit)
+}
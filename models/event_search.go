@@ -0,0 +1,226 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxSearchTerms = 10
+	snippetRadius  = 60 // runes of context either side of the first match
+)
+
+var ErrEmptySearch = errors.New("q must contain at least one word")
+
+// EventSearchResult is an event matching a search with its relevance and
+// highlighted text. Matches are wrapped in <mark> and the rest is HTML-escaped.
+type EventSearchResult struct {
+	Event   Event   `json:"event"`
+	Score   float64 `json:"score"`
+	Name    string  `json:"name_highlighted"`
+	Snippet string  `json:"snippet"`
+}
+
+// EventSearchPage is one page of search results, best match first.
+// NextOffset is zero on the last page.
+type EventSearchPage struct {
+	Query      string              `json:"query"`
+	Results    []EventSearchResult `json:"results"`
+	NextOffset int                 `json:"next_offset,omitempty"`
+	Total      int64               `json:"total"`
+}
+
+// Search finds events whose name or description match text, narrowed by
+// the date, location and owner filters of filters. Sort and Cursor are ignored;
+// results are ordered by relevance and paged with offset.
+func (r sqlEvents) Search(ctx context.Context, text string, filters EventQuery, offset int) (*EventSearchPage, error) {
+	terms := searchTerms(text)
+
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+
+	if err := filters.Normalize(); err != nil {
+		return nil, err
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	score, match := r.dialect.Search(text, terms, "name", "description")
+
+	conditions, filterArgs := filters.filters(r.dialect)
+	conditions = append(conditions, match.SQL)
+
+	args := append(filterArgs, match.Args...)
+
+	var total int64
+
+	countQuery := "SELECT COUNT(*) FROM events" + where(conditions)
+	err := r.conn.QueryRowContext(ctx, countQuery, args...).Scan(&total)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + eventColumns + ", " + score.SQL + " AS score FROM events" + where(conditions) +
+		" ORDER BY score DESC, " + r.dialect.Timestamp("dateTime") + " ASC, id ASC LIMIT ? OFFSET ?"
+
+	queryArgs := append([]any{}, score.Args...)
+	queryArgs = append(queryArgs, args...)
+	queryArgs = append(queryArgs, filters.Limit, offset)
+
+	rows, err := r.conn.QueryContext(ctx, query, queryArgs...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	page := EventSearchPage{Query: text, Results: []EventSearchResult{}, Total: total}
+
+	for rows.Next() {
+		var result EventSearchResult
+		event, err := scanEvent(rows, &result.Score)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result.Event = event
+		result.Name = highlight(event.Name, terms)
+		result.Snippet = snippet(event.Description, terms)
+		page.Results = append(page.Results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if next := offset + len(page.Results); int64(next) < total {
+		page.NextOffset = next
+	}
+
+	return &page, nil
+}
+
+// searchTerms splits text into distinct lower-case words
+func searchTerms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var terms []string
+	seen := map[string]bool{}
+
+	for _, word := range words {
+		if seen[word] {
+			continue
+		}
+
+		seen[word] = true
+		terms = append(terms, word)
+
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+
+	return terms
+}
+
+// snippet cuts text down to the region around the first matching term
+func snippet(text string, terms []string) string {
+	runes := []rune(text)
+	start := 0
+
+	if first := firstMatch(runes, terms); first > snippetRadius {
+		start = first - snippetRadius
+	}
+
+	end := start + 2*snippetRadius
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	result := highlight(string(runes[start:end]), terms)
+
+	if start > 0 {
+		result = "…" + result
+	}
+
+	if end < len(runes) {
+		result += "…"
+	}
+
+	return result
+}
+
+// highlight HTML-escapes text and wraps every occurrence of a term in <mark>
+func highlight(text string, terms []string) string {
+	runes := []rune(text)
+	var builder strings.Builder
+
+	plainStart := 0
+
+	for i := 0; i < len(runes); {
+		length := matchAt(runes, i, terms)
+
+		if length == 0 {
+			i++
+			continue
+		}
+
+		builder.WriteString(html.EscapeString(string(runes[plainStart:i])))
+		builder.WriteString("<mark>" + html.EscapeString(string(runes[i:i+length])) + "</mark>")
+		i += length
+		plainStart = i
+	}
+
+	builder.WriteString(html.EscapeString(string(runes[plainStart:])))
+
+	return builder.String()
+}
+
+func firstMatch(runes []rune, terms []string) int {
+	for i := range runes {
+		if matchAt(runes, i, terms) > 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// matchAt returns the length in runes of the longest term found at position i,
+// compared case-insensitively, or zero
+func matchAt(runes []rune, i int, terms []string) int {
+	longest := 0
+
+	for _, term := range terms {
+		termRunes := []rune(term)
+
+		if len(termRunes) <= longest || i+len(termRunes) > len(runes) {
+			continue
+		}
+
+		matched := true
+		for j, r := range termRunes {
+			if unicode.ToLower(runes[i+j]) != r {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			longest = len(termRunes)
+		}
+	}
+
+	return longest
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/rest-api/test"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func searchNames(page *EventSearchPage) []string {
+	names := make([]string, len(page.Results))
+	for i, result := range page.Results {
+		names[i] = result.Event.Name
+	}
+	return names
+}
+
+func TestSearchEvents_RanksNameAboveDescription(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	base := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	for i, spec := range []struct{ name, description, location string }{
+		{"Cooking Class", "Learn golang while you cook", "Dhaka"},
+		{"Golang Meetup", "Talks about golang and gophers", "Dhaka"},
+		{"Golang Night", "Lightning talks", "Sylhet"},
+		{"Design Sprint", "Nothing to see here", "Dhaka"},
+	} {
+		event := Event{Name: spec.name, Description: spec.description, Location: spec.location,
+			DateTime: base.Add(time.Duration(i) * time.Hour), UserID: users[0].ID}
+		assert.NoError(t, testRepositories().Events.Save(context.Background(), &event))
+	}
+
+	page, err := testRepositories().Events.Search(context.Background(), "golang", EventQuery{}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), page.Total)
+	assert.Equal(t, []string{"Golang Meetup", "Golang Night", "Cooking Class"}, searchNames(page))
+	assert.Equal(t, "<mark>Golang</mark> Meetup", page.Results[0].Name)
+	assert.Equal(t, "Talks about <mark>golang</mark> and gophers", page.Results[0].Snippet)
+	assert.Greater(t, page.Results[0].Score, page.Results[2].Score)
+
+	// Filters from the listing narrow the matches
+	page, err = testRepositories().Events.Search(context.Background(), "golang", EventQuery{Location: "dhaka"}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Golang Meetup", "Cooking Class"}, searchNames(page))
+
+	from := base.Add(90 * time.Minute)
+	page, err = testRepositories().Events.Search(context.Background(), "golang", EventQuery{From: &from}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Golang Night"}, searchNames(page))
+
+	// Offset paging
+	page, err = testRepositories().Events.Search(context.Background(), "golang", EventQuery{Limit: 2}, 0)
+	assert.NoError(t, err)
+	assert.Len(t, page.Results, 2)
+	assert.Equal(t, 2, page.NextOffset)
+
+	page, err = testRepositories().Events.Search(context.Background(), "golang", EventQuery{Limit: 2}, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Cooking Class"}, searchNames(page))
+	assert.Zero(t, page.NextOffset)
+}
+
+func TestSearchEvents_EmptyQuery(t *testing.T) {
+	_, err := testRepositories().Events.Search(context.Background(), "  ?! ", EventQuery{}, 0)
+	assert.ErrorIs(t, err, ErrEmptySearch)
+}
+
+func TestSearchEvents_MySQLUsesFullText(t *testing.T) {
+	mock, cleanup, err := test.SetupMockDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	match := `MATCH\(name, description\) AGAINST \(\? IN NATURAL LANGUAGE MODE\)`
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM events WHERE location LIKE \? ESCAPE '!' AND `+match).
+		WithArgs("%Dhaka%", "go meetup").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT id, name, description, location, dateTime, user_id, capacity, waitlist_enabled, rrule, timezone, `+match+` AS score FROM events WHERE .+ ORDER BY score DESC, dateTime ASC, id ASC LIMIT \? OFFSET \?`).
+		WithArgs("go meetup", "%Dhaka%", "go meetup", DefaultEventPageSize, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "location", "dateTime", "user_id", "capacity", "waitlist_enabled", "rrule", "timezone", "score"}).
+			AddRow(1, "Go Meetup", "Monthly meetup", "Dhaka", time.Now(), 1, nil, false, "", "UTC", 1.5))
+
+	page, err := testRepositories().Events.Search(context.Background(), "go meetup", EventQuery{Location: "Dhaka"}, 0)
+	assert.NoError(t, err)
+	assert.Len(t, page.Results, 1)
+	assert.Equal(t, 1.5, page.Results[0].Score)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHighlight(t *testing.T) {
+	terms := searchTerms("GO, <b>meetup</b> go")
+	assert.Equal(t, []string{"go", "b", "meetup"}, terms)
+
+	assert.Equal(t, "<mark>Go</mark> &amp; <mark>Meetup</mark>s", highlight("Go & Meetups", []string{"go", "meetup"}))
+	assert.Equal(t, "no match", highlight("no match", []string{"go"}))
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("a ", 100) + "golang " + strings.Repeat("b ", 100)
+
+	result := snippet(text, []string{"golang"})
+	assert.True(t, strings.HasPrefix(result, "…"))
+	assert.True(t, strings.HasSuffix(result, "…"))
+	assert.Contains(t, result, "<mark>golang</mark>")
+
+	assert.Equal(t, "short", snippet("short", []string{"golang"}))
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"testing"
@@ -10,6 +11,18 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// expectNoWebhooks expects the event's webhook recipients to be looked up
+// and found to have no webhooks
+func expectNoWebhooks(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT user_id FROM event_organizers WHERE event_id = \?`).WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectQuery(`FROM webhooks WHERE active = \? AND user_id IN`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+// expectEventChange expects the change to be stored for other replicas
+func expectEventChange(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`INSERT INTO event_changes`).WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
 func TestEvent_Save(t *testing.T) {
 	mock, cleanup, err := test.SetupMockDB()
 	assert.NoError(t, err)
@@ -35,8 +48,11 @@ func TestEvent_Save(t *testing.T) {
 			name:  "Successful save",
 			event: event,
 			mockFn: func() {
-				query := `INSERT INTO events \(name, description, location, dateTime, user_id\) VALUES \(\?, \?, \?, \?, \?\)`
+				query := `INSERT INTO events \(name, description, location, dateTime, user_id, capacity, waitlist_enabled, rrule, timezone\) VALUES \(\?, \?, \?, \?, \?, \?, \?, \?, \?\)`
+				mock.ExpectBegin()
 				mock.ExpectPrepare(query).ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+				expectNoWebhooks(mock)
+				mock.ExpectCommit()
 			},
 			wantErr: false,
 			wantID:  1,
@@ -45,8 +61,10 @@ func TestEvent_Save(t *testing.T) {
 			name:  "Prepare error",
 			event: event,
 			mockFn: func() {
-				query := `INSERT INTO events \(name, description, location, dateTime, user_id\) VALUES \(\?, \?, \?, \?, \?\)`
+				query := `INSERT INTO events \(name, description, location, dateTime, user_id, capacity, waitlist_enabled, rrule, timezone\) VALUES \(\?, \?, \?, \?, \?, \?, \?, \?, \?\)`
+				mock.ExpectBegin()
 				mock.ExpectPrepare(query).WillReturnError(errors.New("prepare error"))
+				mock.ExpectRollback()
 			},
 			wantErr: true,
 			wantID:  0,
@@ -55,8 +73,10 @@ func TestEvent_Save(t *testing.T) {
 			name:  "Exec error",
 			event: event,
 			mockFn: func() {
-				query := `INSERT INTO events \(name, description, location, dateTime, user_id\) VALUES \(\?, \?, \?, \?, \?\)`
+				query := `INSERT INTO events \(name, description, location, dateTime, user_id, capacity, waitlist_enabled, rrule, timezone\) VALUES \(\?, \?, \?, \?, \?, \?, \?, \?, \?\)`
+				mock.ExpectBegin()
 				mock.ExpectPrepare(query).ExpectExec().WillReturnError(errors.New("exec error"))
+				mock.ExpectRollback()
 			},
 			wantErr: true,
 			wantID:  0,
@@ -65,9 +85,11 @@ func TestEvent_Save(t *testing.T) {
 			name:  "LastInsertId error",
 			event: event,
 			mockFn: func() {
-				query := `INSERT INTO events \(name, description, location, dateTime, user_id\) VALUES \(\?, \?, \?, \?, \?\)`
+				query := `INSERT INTO events \(name, description, location, dateTime, user_id, capacity, waitlist_enabled, rrule, timezone\) VALUES \(\?, \?, \?, \?, \?, \?, \?, \?, \?\)`
 				result := sqlmock.NewErrorResult(errors.New("last insert id error"))
+				mock.ExpectBegin()
 				mock.ExpectPrepare(query).ExpectExec().WillReturnResult(result)
+				mock.ExpectRollback()
 			},
 			wantErr: true,
 			wantID:  0,
@@ -78,7 +100,7 @@ func TestEvent_Save(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockFn()
 
-			err := tt.event.Save()
+			err := testRepositories().Events.Save(context.Background(), &tt.event)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -118,8 +140,12 @@ func TestEvent_Update(t *testing.T) {
 			name:  "Successful update",
 			event: event,
 			mockFn: func() {
-				query := `UPDATE events SET name = \?, description = \?, location = \?, dateTime = \? WHERE id = \?`
+				query := `UPDATE events SET name = \?, description = \?, location = \?, dateTime = \?, capacity = \?, waitlist_enabled = \?, rrule = \?, timezone = \? WHERE id = \?`
+				mock.ExpectBegin()
 				mock.ExpectPrepare(query).ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+				expectNoWebhooks(mock)
+				expectEventChange(mock)
+				mock.ExpectCommit()
 			},
 			wantErr: false,
 		},
@@ -127,8 +153,10 @@ func TestEvent_Update(t *testing.T) {
 			name:  "Prepare error",
 			event: event,
 			mockFn: func() {
-				query := `UPDATE events SET name = \?, description = \?, location = \?, dateTime = \? WHERE id = \?`
+				query := `UPDATE events SET name = \?, description = \?, location = \?, dateTime = \?, capacity = \?, waitlist_enabled = \?, rrule = \?, timezone = \? WHERE id = \?`
+				mock.ExpectBegin()
 				mock.ExpectPrepare(query).WillReturnError(errors.New("prepare error"))
+				mock.ExpectRollback()
 			},
 			wantErr: true,
 		},
@@ -136,8 +164,10 @@ func TestEvent_Update(t *testing.T) {
 			name:  "Exec error",
 			event: event,
 			mockFn: func() {
-				query := `UPDATE events SET name = \?, description = \?, location = \?, dateTime = \? WHERE id = \?`
+				query := `UPDATE events SET name = \?, description = \?, location = \?, dateTime = \?, capacity = \?, waitlist_enabled = \?, rrule = \?, timezone = \? WHERE id = \?`
+				mock.ExpectBegin()
 				mock.ExpectPrepare(query).ExpectExec().WillReturnError(errors.New("exec error"))
+				mock.ExpectRollback()
 			},
 			wantErr: true,
 		},
@@ -147,7 +177,7 @@ func TestEvent_Update(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockFn()
 
-			err := tt.event.Update()
+			err := testRepositories().Events.Update(context.Background(), &tt.event)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -181,7 +211,11 @@ func TestEvent_Delete(t *testing.T) {
 			event: event,
 			mockFn: func() {
 				query := `DELETE FROM events WHERE id = \?`
+				mock.ExpectBegin()
+				expectNoWebhooks(mock)
 				mock.ExpectPrepare(query).ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+				expectEventChange(mock)
+				mock.ExpectCommit()
 			},
 			wantErr: false,
 		},
@@ -190,7 +224,10 @@ func TestEvent_Delete(t *testing.T) {
 			event: event,
 			mockFn: func() {
 				query := `DELETE FROM events WHERE id = \?`
+				mock.ExpectBegin()
+				expectNoWebhooks(mock)
 				mock.ExpectPrepare(query).WillReturnError(errors.New("prepare error"))
+				mock.ExpectRollback()
 			},
 			wantErr: true,
 		},
@@ -199,7 +236,10 @@ func TestEvent_Delete(t *testing.T) {
 			event: event,
 			mockFn: func() {
 				query := `DELETE FROM events WHERE id = \?`
+				mock.ExpectBegin()
+				expectNoWebhooks(mock)
 				mock.ExpectPrepare(query).ExpectExec().WillReturnError(errors.New("exec error"))
+				mock.ExpectRollback()
 			},
 			wantErr: true,
 		},
@@ -209,107 +249,12 @@ func TestEvent_Delete(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockFn()
 
-			err := tt.event.Delete()
-
-			if tt.wantErr {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-			}
-
-			assert.NoError(t, mock.ExpectationsWereMet())
-		})
-	}
-}
-
-func TestGetAllEvents(t *testing.T) {
-	mock, cleanup, err := test.SetupMockDB()
-	assert.NoError(t, err)
-	defer cleanup()
-
-	testEvent := test.GetTestEvent()
-
-	tests := []struct {
-		name       string
-		mockFn     func()
-		wantErr    bool
-		wantCount  int
-		wantEvents []Event
-	}{
-		{
-			name: "Successful query with results",
-			mockFn: func() {
-				columns := []string{"id", "name", "description", "location", "dateTime", "user_id"}
-				rows := sqlmock.NewRows(columns).
-					AddRow(testEvent.ID, testEvent.Name, testEvent.Description,
-						testEvent.Location, testEvent.DateTime, testEvent.UserID)
-				mock.ExpectQuery(`SELECT \* FROM events`).WillReturnRows(rows)
-			},
-			wantErr:   false,
-			wantCount: 1,
-			wantEvents: []Event{
-				{
-					ID:          testEvent.ID,
-					Name:        testEvent.Name,
-					Description: testEvent.Description,
-					Location:    testEvent.Location,
-					DateTime:    testEvent.DateTime,
-					UserID:      testEvent.UserID,
-				},
-			},
-		},
-		{
-			name: "Successful query with no results",
-			mockFn: func() {
-				columns := []string{"id", "name", "description", "location", "dateTime", "user_id"}
-				rows := sqlmock.NewRows(columns)
-				mock.ExpectQuery(`SELECT \* FROM events`).WillReturnRows(rows)
-			},
-			wantErr:    false,
-			wantCount:  0,
-			wantEvents: []Event{},
-		},
-		{
-			name: "Query error",
-			mockFn: func() {
-				mock.ExpectQuery(`SELECT \* FROM events`).WillReturnError(errors.New("query error"))
-			},
-			wantErr:    true,
-			wantCount:  0,
-			wantEvents: nil,
-		},
-		{
-			name: "Scan error",
-			mockFn: func() {
-				columns := []string{"id", "name", "description", "location", "dateTime", "user_id"}
-				rows := sqlmock.NewRows(columns).
-					AddRow("invalid_id", testEvent.Name, testEvent.Description,
-						testEvent.Location, testEvent.DateTime, testEvent.UserID)
-				mock.ExpectQuery(`SELECT \* FROM events`).WillReturnRows(rows)
-			},
-			wantErr:    true,
-			wantCount:  0,
-			wantEvents: nil,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tt.mockFn()
-
-			events, err := GetAllEvents()
+			err := testRepositories().Events.Delete(context.Background(), &tt.event)
 
 			if tt.wantErr {
 				assert.Error(t, err)
-				assert.Nil(t, events)
 			} else {
 				assert.NoError(t, err)
-				assert.Len(t, events, tt.wantCount)
-				if tt.wantCount > 0 {
-					assert.Equal(t, tt.wantEvents[0].ID, events[0].ID)
-					assert.Equal(t, tt.wantEvents[0].Name, events[0].Name)
-					assert.Equal(t, tt.wantEvents[0].Description, events[0].Description)
-				}
 			}
 
 			assert.NoError(t, mock.ExpectationsWereMet())
@@ -335,11 +280,11 @@ func TestGetEventById(t *testing.T) {
 			name:    "Successful query",
 			eventID: testEvent.ID,
 			mockFn: func() {
-				columns := []string{"id", "name", "description", "location", "dateTime", "user_id"}
+				columns := []string{"id", "name", "description", "location", "dateTime", "user_id", "capacity", "waitlist_enabled", "rrule", "timezone"}
 				rows := sqlmock.NewRows(columns).
 					AddRow(testEvent.ID, testEvent.Name, testEvent.Description,
-						testEvent.Location, testEvent.DateTime, testEvent.UserID)
-				mock.ExpectQuery(`SELECT \* FROM events WHERE id = \?`).
+						testEvent.Location, testEvent.DateTime, testEvent.UserID, nil, false, "", "UTC")
+				mock.ExpectQuery(`SELECT id, name, description, location, dateTime, user_id, capacity, waitlist_enabled, rrule, timezone FROM events WHERE id = \?`).
 					WithArgs(testEvent.ID).WillReturnRows(rows)
 			},
 			wantErr: false,
@@ -356,7 +301,7 @@ func TestGetEventById(t *testing.T) {
 			name:    "Event not found",
 			eventID: 999,
 			mockFn: func() {
-				mock.ExpectQuery(`SELECT \* FROM events WHERE id = \?`).
+				mock.ExpectQuery(`SELECT id, name, description, location, dateTime, user_id, capacity, waitlist_enabled, rrule, timezone FROM events WHERE id = \?`).
 					WithArgs(int64(999)).WillReturnError(sql.ErrNoRows)
 			},
 			wantErr:   true,
@@ -366,7 +311,7 @@ func TestGetEventById(t *testing.T) {
 			name:    "Query error",
 			eventID: testEvent.ID,
 			mockFn: func() {
-				mock.ExpectQuery(`SELECT \* FROM events WHERE id = \?`).
+				mock.ExpectQuery(`SELECT id, name, description, location, dateTime, user_id, capacity, waitlist_enabled, rrule, timezone FROM events WHERE id = \?`).
 					WithArgs(testEvent.ID).WillReturnError(errors.New("query error"))
 			},
 			wantErr:   true,
@@ -376,11 +321,11 @@ func TestGetEventById(t *testing.T) {
 			name:    "Scan error",
 			eventID: testEvent.ID,
 			mockFn: func() {
-				columns := []string{"id", "name", "description", "location", "dateTime", "user_id"}
+				columns := []string{"id", "name", "description", "location", "dateTime", "user_id", "capacity", "waitlist_enabled", "rrule", "timezone"}
 				rows := sqlmock.NewRows(columns).
 					AddRow("invalid_id", testEvent.Name, testEvent.Description,
-						testEvent.Location, testEvent.DateTime, testEvent.UserID)
-				mock.ExpectQuery(`SELECT \* FROM events WHERE id = \?`).
+						testEvent.Location, testEvent.DateTime, testEvent.UserID, nil, false, "", "UTC")
+				mock.ExpectQuery(`SELECT id, name, description, location, dateTime, user_id, capacity, waitlist_enabled, rrule, timezone FROM events WHERE id = \?`).
 					WithArgs(testEvent.ID).WillReturnRows(rows)
 			},
 			wantErr:   true,
@@ -392,7 +337,7 @@ func TestGetEventById(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockFn()
 
-			event, err := GetEventById(tt.eventID)
+			event, err := testRepositories().Events.GetByID(context.Background(), tt.eventID)
 
 			if tt.wantErr {
 				assert.Error(t, err)
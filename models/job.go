@@ -0,0 +1,438 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	JobStatusQueued  = "queued"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusDead    = "dead" // out of attempts; only retried by hand
+)
+
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobLeaseLost = errors.New("job lease expired and the job was claimed again")
+)
+
+// Job is a unit of background work, run by whichever worker claims it
+type Job struct {
+	ID          int64      `json:"id"`
+	Type        string     `json:"type"`
+	Payload     string     `json:"payload"` // JSON
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	RunAt       time.Time  `json:"run_at"`
+	LockedBy    string     `json:"locked_by,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobSchedule enqueues a job of its type each time its spec comes due
+type JobSchedule struct {
+	Name      string
+	Type      string
+	Spec      string
+	NextRunAt time.Time
+}
+
+const jobColumns = "id, type, payload, status, attempts, run_at, locked_by, locked_until, last_error, created_at, finished_at"
+
+func scanJob(row rowScanner) (Job, error) {
+	var job Job
+	var lockedBy, lastError sql.NullString
+	var lockedUntil, finishedAt sql.NullTime
+
+	err := row.Scan(&job.ID, &job.Type, &job.Payload, &job.Status, &job.Attempts, &job.RunAt,
+		&lockedBy, &lockedUntil, &lastError, &job.CreatedAt, &finishedAt)
+
+	job.LockedBy, job.LastError = lockedBy.String, lastError.String
+
+	if lockedUntil.Valid {
+		job.LockedUntil = &lockedUntil.Time
+	}
+
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+
+	return job, err
+}
+
+// Enqueue queues a job of the type to run at runAt, with payload encoded
+// as JSON
+func (r sqlJobs) Enqueue(ctx context.Context, jobType string, payload any, runAt time.Time) (*Job, error) {
+	return enqueueJob(ctx, r.conn, jobType, payload, runAt)
+}
+
+func enqueueJob(ctx context.Context, p preparer, jobType string, payload any, runAt time.Time) (*Job, error) {
+	if payload == nil {
+		payload = struct{}{}
+	}
+
+	encoded, err := json.Marshal(payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	job := Job{
+		Type:      jobType,
+		Payload:   string(encoded),
+		Status:    JobStatusQueued,
+		RunAt:     runAt.UTC(),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	stmt, err := p.PrepareContext(ctx, `INSERT INTO jobs (type, payload, status, attempts, run_at, created_at) VALUES (?, ?, ?, ?, ?, ?)`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, job.Type, job.Payload, job.Status, 0, job.RunAt, job.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	job.ID, err = result.LastInsertId()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// claimable matches jobs that are due and jobs whose worker's lease ran out
+func (r sqlJobs) claimable() string {
+	return `(status = ? AND ` + r.dialect.Timestamp("run_at") + ` <= ` + r.dialect.Timestamp("?") + `)
+		OR (status = ? AND ` + r.dialect.Timestamp("locked_until") + ` <= ` + r.dialect.Timestamp("?") + `)`
+}
+
+// Claim leases up to limit claimable jobs of the types to the worker
+// until now+lease, counting an attempt for each. Each job is claimed with a
+// conditional UPDATE, so when several workers race for a job only one gets
+// it. The leases all start at now, so a worker running jobs one after another
+// should claim each just before running it.
+func (r sqlJobs) Claim(ctx context.Context, workerID string, types []string, limit int, lease time.Duration, now time.Time) ([]Job, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	now = now.UTC()
+	args := []any{}
+
+	for _, jobType := range types {
+		args = append(args, jobType)
+	}
+
+	args = append(args, JobStatusQueued, now, JobStatusRunning, now, limit)
+	query := `SELECT id FROM jobs WHERE type IN (` + placeholders(len(types)) + `) AND (` + r.claimable() + `) ORDER BY run_at, id LIMIT ?`
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []int64
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		candidates = append(candidates, id)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	jobs := []Job{}
+	until := now.Add(lease)
+
+	for _, id := range candidates {
+		query := `UPDATE jobs SET status = ?, attempts = attempts + 1, locked_by = ?, locked_until = ? WHERE id = ? AND (` + r.claimable() + `)`
+		result, err := r.conn.ExecContext(ctx, query, JobStatusRunning, workerID, until, id, JobStatusQueued, now, JobStatusRunning, now)
+
+		if err != nil {
+			return nil, err
+		}
+
+		affected, err := result.RowsAffected()
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Another worker got there first
+		if affected == 0 {
+			continue
+		}
+
+		job, err := scanJob(r.conn.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// finish updates the job if the worker still holds its lease
+func (r sqlJobs) finish(ctx context.Context, j *Job, set string, args ...any) error {
+	query := `UPDATE jobs SET ` + set + `, locked_by = NULL, locked_until = NULL WHERE id = ? AND status = ? AND locked_by = ?`
+	result, err := r.conn.ExecContext(ctx, query, append(args, j.ID, JobStatusRunning, j.LockedBy)...)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrJobLeaseLost
+	}
+
+	j.LockedBy, j.LockedUntil = "", nil
+
+	return nil
+}
+
+// Complete records that the job ran successfully
+func (r sqlJobs) Complete(ctx context.Context, j *Job) error {
+	now := time.Now().UTC()
+
+	if err := r.finish(ctx, j, `status = ?, finished_at = ?`, JobStatusDone, now); err != nil {
+		return err
+	}
+
+	j.Status, j.FinishedAt = JobStatusDone, &now
+
+	return nil
+}
+
+// Fail records a failed attempt. The job is retried with exponential backoff
+// until it has made maxAttempts, then marked dead.
+func (r sqlJobs) Fail(ctx context.Context, j *Job, runErr error, maxAttempts int) error {
+	status := JobStatusQueued
+	runAt := time.Now().UTC().Add(deliveryBackoff(j.Attempts))
+	var finishedAt *time.Time
+
+	if j.Attempts >= maxAttempts {
+		now := time.Now().UTC()
+		status, runAt, finishedAt = JobStatusDead, j.RunAt, &now
+	}
+
+	err := r.finish(ctx, j, `status = ?, run_at = ?, last_error = ?, finished_at = ?`, status, runAt, runErr.Error(), finishedAt)
+
+	if err != nil {
+		return err
+	}
+
+	j.Status, j.RunAt, j.LastError, j.FinishedAt = status, runAt, runErr.Error(), finishedAt
+
+	return nil
+}
+
+// defaultJobLimit is how many jobs List lists when no limit is given
+const defaultJobLimit = 50
+
+// Release hands the job back to the queue without counting the attempt, for
+// a worker that stopped before running it to the end
+func (r sqlJobs) Release(ctx context.Context, j *Job) error {
+	now := time.Now().UTC()
+
+	if err := r.finish(ctx, j, `status = ?, attempts = attempts - 1, run_at = ?`, JobStatusQueued, now); err != nil {
+		return err
+	}
+
+	j.Status, j.Attempts, j.RunAt = JobStatusQueued, j.Attempts-1, now
+
+	return nil
+}
+
+// List lists up to limit jobs with the status, most recent first. A limit
+// of zero uses the default of 50.
+func (r sqlJobs) List(ctx context.Context, status string, limit int) ([]Job, error) {
+	if limit <= 0 {
+		limit = defaultJobLimit
+	}
+
+	rows, err := r.conn.QueryContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE status = ? ORDER BY id DESC LIMIT ?`, status, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	jobs := []Job{}
+
+	for rows.Next() {
+		job, err := scanJob(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// Retry queues a dead job again with fresh attempts, or returns
+// ErrJobNotFound if there is no dead job with the id
+func (r sqlJobs) Retry(ctx context.Context, id int64) error {
+	query := `UPDATE jobs SET status = ?, attempts = 0, run_at = ?, finished_at = NULL WHERE id = ? AND status = ?`
+	result, err := r.conn.ExecContext(ctx, query, JobStatusQueued, time.Now().UTC(), id, JobStatusDead)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// Prune deletes jobs that finished successfully before the given time.
+// Dead jobs are kept for inspection.
+func (r sqlJobs) Prune(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM jobs WHERE status = ? AND ` + r.dialect.Timestamp("finished_at") + ` < ` + r.dialect.Timestamp("?")
+	result, err := r.conn.ExecContext(ctx, query, JobStatusDone, before.UTC())
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// SaveSchedule creates the schedule, first due at next, or updates its
+// type and spec if they changed. Schedule times are whole seconds so that
+// they compare equal however the backend stores them.
+func (r sqlJobs) SaveSchedule(ctx context.Context, name, jobType, spec string, next time.Time) error {
+	next = next.UTC().Truncate(time.Second)
+
+	query := `UPDATE job_schedules SET type = ?, spec = ?, next_run_at = ? WHERE name = ? AND (type <> ? OR spec <> ?)`
+	_, err := r.conn.ExecContext(ctx, query, jobType, spec, next, name, jobType, spec)
+
+	if err != nil {
+		return err
+	}
+
+	query = `INSERT INTO job_schedules (name, type, spec, next_run_at) VALUES (?, ?, ?, ?)`
+	_, err = r.conn.ExecContext(ctx, query, name, jobType, spec, next)
+
+	// Already there, from an earlier start or another replica
+	if r.dialect.IsUniqueViolation(err) {
+		return nil
+	}
+
+	return err
+}
+
+// DueSchedules returns the schedules whose next run has come
+func (r sqlJobs) DueSchedules(ctx context.Context, now time.Time) ([]JobSchedule, error) {
+	query := `SELECT name, type, spec, next_run_at FROM job_schedules WHERE ` + r.dialect.Timestamp("next_run_at") + ` <= ` + r.dialect.Timestamp("?") + ` ORDER BY name`
+	rows, err := r.conn.QueryContext(ctx, query, now.UTC())
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var schedules []JobSchedule
+
+	for rows.Next() {
+		var schedule JobSchedule
+
+		if err := rows.Scan(&schedule.Name, &schedule.Type, &schedule.Spec, &schedule.NextRunAt); err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, rows.Err()
+}
+
+// AdvanceSchedule moves the schedule on to next and enqueues the run that was due.
+// If another replica advanced it first, nothing is enqueued and the job is
+// nil.
+func (r sqlJobs) AdvanceSchedule(ctx context.Context, s *JobSchedule, next time.Time) (*Job, error) {
+	next = next.UTC().Truncate(time.Second)
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	query := `UPDATE job_schedules SET next_run_at = ? WHERE name = ? AND ` + r.dialect.Timestamp("next_run_at") + ` = ` + r.dialect.Timestamp("?")
+	result, err := tx.ExecContext(ctx, query, next, s.Name, s.NextRunAt.UTC())
+
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, nil
+	}
+
+	job, err := enqueueJob(ctx, tx, s.Type, nil, s.NextRunAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.NextRunAt = next
+
+	return job, nil
+}
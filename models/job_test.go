@@ -0,0 +1,203 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimJobs_OnlyOnce(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	now := time.Now()
+
+	queued, err := testRepositories().Jobs.Enqueue(context.Background(), "greet", map[string]string{"name": "Ada"}, now.Add(-time.Minute))
+	assert.NoError(t, err)
+	_, err = testRepositories().Jobs.Enqueue(context.Background(), "greet", nil, now.Add(time.Hour))
+	assert.NoError(t, err)
+	_, err = testRepositories().Jobs.Enqueue(context.Background(), "other", nil, now)
+	assert.NoError(t, err)
+
+	claimed, err := testRepositories().Jobs.Claim(context.Background(), "worker-a", []string{"greet"}, 10, time.Minute, now)
+	assert.NoError(t, err)
+	assert.Len(t, claimed, 1)
+	assert.Equal(t, queued.ID, claimed[0].ID)
+	assert.Equal(t, `{"name":"Ada"}`, claimed[0].Payload)
+	assert.Equal(t, JobStatusRunning, claimed[0].Status)
+	assert.Equal(t, 1, claimed[0].Attempts)
+	assert.Equal(t, "worker-a", claimed[0].LockedBy)
+
+	// Leased jobs are not claimed again while the lease holds
+	again, err := testRepositories().Jobs.Claim(context.Background(), "worker-b", []string{"greet"}, 10, time.Minute, now)
+	assert.NoError(t, err)
+	assert.Empty(t, again)
+
+	assert.NoError(t, testRepositories().Jobs.Complete(context.Background(), &claimed[0]))
+	assert.Equal(t, JobStatusDone, claimed[0].Status)
+}
+
+func TestClaimJobs_LeaseExpiry(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	now := time.Now()
+
+	_, err = testRepositories().Jobs.Enqueue(context.Background(), "greet", nil, now)
+	assert.NoError(t, err)
+
+	first, err := testRepositories().Jobs.Claim(context.Background(), "worker-a", []string{"greet"}, 10, time.Minute, now)
+	assert.NoError(t, err)
+	assert.Len(t, first, 1)
+
+	// worker-a stalls past its lease, so worker-b takes the job over
+	second, err := testRepositories().Jobs.Claim(context.Background(), "worker-b", []string{"greet"}, 10, time.Minute, now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, second, 1)
+	assert.Equal(t, 2, second[0].Attempts)
+
+	assert.ErrorIs(t, testRepositories().Jobs.Complete(context.Background(), &first[0]), ErrJobLeaseLost)
+	assert.NoError(t, testRepositories().Jobs.Complete(context.Background(), &second[0]))
+}
+
+func TestJob_FailRetriesThenDies(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	now := time.Now()
+
+	job, err := testRepositories().Jobs.Enqueue(context.Background(), "greet", nil, now)
+	assert.NoError(t, err)
+
+	claimed, err := testRepositories().Jobs.Claim(context.Background(), "worker-a", []string{"greet"}, 10, time.Minute, now)
+	assert.NoError(t, err)
+	assert.NoError(t, testRepositories().Jobs.Fail(context.Background(), &claimed[0], errors.New("smtp down"), 2))
+	assert.Equal(t, JobStatusQueued, claimed[0].Status)
+	assert.True(t, claimed[0].RunAt.After(now), "retried with backoff")
+
+	// Not due again until the backoff has passed
+	claimed, err = testRepositories().Jobs.Claim(context.Background(), "worker-a", []string{"greet"}, 10, time.Minute, now)
+	assert.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	claimed, err = testRepositories().Jobs.Claim(context.Background(), "worker-a", []string{"greet"}, 10, time.Minute, now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, claimed, 1)
+	assert.NoError(t, testRepositories().Jobs.Fail(context.Background(), &claimed[0], errors.New("smtp still down"), 2))
+	assert.Equal(t, JobStatusDead, claimed[0].Status)
+
+	dead, err := testRepositories().Jobs.List(context.Background(), JobStatusDead, 10)
+	assert.NoError(t, err)
+	assert.Len(t, dead, 1)
+	assert.Equal(t, "smtp still down", dead[0].LastError)
+	assert.Equal(t, 2, dead[0].Attempts)
+
+	assert.NoError(t, testRepositories().Jobs.Retry(context.Background(), job.ID))
+	assert.ErrorIs(t, testRepositories().Jobs.Retry(context.Background(), job.ID), ErrJobNotFound)
+
+	claimed, err = testRepositories().Jobs.Claim(context.Background(), "worker-a", []string{"greet"}, 10, time.Minute, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, claimed, 1)
+	assert.Equal(t, 1, claimed[0].Attempts)
+}
+
+func TestPruneJobs(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		_, err = testRepositories().Jobs.Enqueue(context.Background(), "greet", nil, now)
+		assert.NoError(t, err)
+	}
+
+	claimed, err := testRepositories().Jobs.Claim(context.Background(), "worker-a", []string{"greet"}, 10, time.Minute, now)
+	assert.NoError(t, err)
+	assert.Len(t, claimed, 2)
+	assert.NoError(t, testRepositories().Jobs.Complete(context.Background(), &claimed[0]))
+	assert.NoError(t, testRepositories().Jobs.Fail(context.Background(), &claimed[1], errors.New("boom"), 1))
+
+	pruned, err := testRepositories().Jobs.Prune(context.Background(), now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+
+	dead, err := testRepositories().Jobs.List(context.Background(), JobStatusDead, 10)
+	assert.NoError(t, err)
+	assert.Len(t, dead, 1)
+}
+
+func TestJobSchedule_AdvanceOnce(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	now := time.Now()
+
+	assert.NoError(t, testRepositories().Jobs.SaveSchedule(context.Background(), "nightly", "greet", "@daily", now))
+	// Saving again from another replica keeps the schedule as it is
+	assert.NoError(t, testRepositories().Jobs.SaveSchedule(context.Background(), "nightly", "greet", "@daily", now.Add(time.Hour)))
+
+	// Two replicas see the same due schedule
+	first, err := testRepositories().Jobs.DueSchedules(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Len(t, first, 1)
+	second, err := testRepositories().Jobs.DueSchedules(context.Background(), now)
+	assert.NoError(t, err)
+
+	next := now.Add(24 * time.Hour)
+
+	job, err := testRepositories().Jobs.AdvanceSchedule(context.Background(), &first[0], next)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+	assert.Equal(t, "greet", job.Type)
+
+	job, err = testRepositories().Jobs.AdvanceSchedule(context.Background(), &second[0], next)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+
+	due, err := testRepositories().Jobs.DueSchedules(context.Background(), now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, due)
+
+	queued, err := testRepositories().Jobs.List(context.Background(), JobStatusQueued, 10)
+	assert.NoError(t, err)
+	assert.Len(t, queued, 1)
+
+	// A changed spec takes effect from the given time
+	assert.NoError(t, testRepositories().Jobs.SaveSchedule(context.Background(), "nightly", "greet", "@hourly", now))
+	due, err = testRepositories().Jobs.DueSchedules(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+	assert.Equal(t, "@hourly", due[0].Spec)
+}
+
+func TestJob_Release(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	now := time.Now()
+
+	_, err = testRepositories().Jobs.Enqueue(context.Background(), "greet", nil, now)
+	assert.NoError(t, err)
+
+	claimed, err := testRepositories().Jobs.Claim(context.Background(), "worker-a", []string{"greet"}, 10, time.Hour, now)
+	assert.NoError(t, err)
+	assert.NoError(t, testRepositories().Jobs.Release(context.Background(), &claimed[0]))
+	assert.Equal(t, 0, claimed[0].Attempts)
+
+	// Claimable at once by another worker, lease or not
+	claimed, err = testRepositories().Jobs.Claim(context.Background(), "worker-b", []string{"greet"}, 10, time.Hour, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, claimed, 1)
+	assert.Equal(t, 1, claimed[0].Attempts)
+}
@@ -1,9 +1,17 @@
 package models
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
 	"time"
 
-	"example.com/rest-api/db"
+	"example.com/rest-api/i18n"
+)
+
+const (
+	NotificationTypeUpcomingEvent    = "upcoming_event"
+	NotificationTypeWaitlistPromoted = "waitlist_promoted"
 )
 
 type Notification struct {
@@ -11,24 +19,125 @@ type Notification struct {
 	UserID    int64     `json:"user_id"`
 	EventID   int64     `json:"event_id"`
 	Message   string    `json:"message"`
-	Type      string    `json:"type"` // "upcoming_event", "event_reminder", etc.
+	Type      string    `json:"type"` // one of the NotificationType constants
 	IsRead    bool      `json:"is_read"`
 	CreatedAt time.Time `json:"created_at"`
+	InApp     bool      `json:"-"` // false if the user only wants it on other channels
+
+	// Localized is the catalog message behind Message, if any, so each reader
+	// gets it in their own locale
+	Localized *LocalizedMessage `json:"-"`
+}
+
+// LocalizedMessage names a message in the i18n catalog and what fills it in
+type LocalizedMessage struct {
+	Key    string        `json:"key"`
+	Params MessageParams `json:"params"`
+}
+
+// MessageParams are the values catalog messages refer to
+type MessageParams struct {
+	Event string    `json:"event"`
+	Start time.Time `json:"start"`
+	Count int       `json:"count,omitempty"` // also picks the plural form
+}
+
+// Render renders the message for the locale, showing times in zone
+func (m LocalizedMessage) Render(locale string, zone *time.Location) string {
+	params := m.Params
+	params.Start = params.Start.In(zone)
+
+	return i18n.RenderPlural(locale, m.Key, params.Count, params)
+}
+
+// Localize renders Message in the reader's locale and timezone. Notifications
+// stored before the catalog keep their text.
+func (n *Notification) Localize(settings *UserSettings) {
+	if n.Localized == nil {
+		return
+	}
+
+	n.Message = n.Localized.Render(settings.Locale, settings.Location())
 }
 
-func (n *Notification) Save() error {
+// preparer is satisfied by both *sql.DB and *sql.Tx
+type preparer interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// notificationListeners are called with every notification once it is stored
+var notificationListeners []func(Notification)
+
+// OnNotificationCreated registers a function to call with every notification
+// once it is stored, e.g. to push it to connected clients. Listeners are
+// registered at startup and must not block.
+func OnNotificationCreated(listener func(Notification)) {
+	notificationListeners = append(notificationListeners, listener)
+}
+
+// notificationCreated announces a stored notification, unless the user turned
+// it off in the app
+func notificationCreated(n Notification) {
+	if n.ID == 0 || !n.InApp {
+		return
+	}
+
+	for _, listener := range notificationListeners {
+		listener(n)
+	}
+}
+
+// Save stores the notification and queues it on the channels the user wants
+// it on. If the user turned its type off everywhere nothing is stored and ID
+// stays 0.
+func (r sqlNotifications) Save(ctx context.Context, n *Notification) error {
+	if err := n.saveWith(ctx, r.conn); err != nil {
+		return err
+	}
+
+	notificationCreated(*n)
+	return nil
+}
+
+// saveWith inserts the notification through db or an open transaction.
+// Callers passing a transaction announce the notification after committing.
+func (n *Notification) saveWith(ctx context.Context, p preparer) error {
+	inApp, email, err := notificationChannels(ctx, p, n.UserID, n.Type)
+	if err != nil {
+		return err
+	}
+
+	if !inApp && !email {
+		return nil
+	}
+
+	n.InApp = inApp
+
+	// Message keeps the default rendering for readers without a locale
+	var key string
+	var params any
+	if n.Localized != nil {
+		n.Message = n.Localized.Render(i18n.DefaultLocale, n.Localized.Params.Start.Location())
+
+		encoded, err := json.Marshal(n.Localized.Params)
+		if err != nil {
+			return err
+		}
+		key, params = n.Localized.Key, string(encoded)
+	}
+
 	query := `
-		INSERT INTO notifications (user_id, event_id, message, type, is_read, created_at)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO notifications (user_id, event_id, message, type, is_read, created_at, in_app, message_key, message_params)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	stmt, err := db.DB.Prepare(query)
+	stmt, err := p.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	result, err := stmt.Exec(n.UserID, n.EventID, n.Message, n.Type, n.IsRead, n.CreatedAt)
+	result, err := stmt.ExecContext(ctx, n.UserID, n.EventID, n.Message, n.Type, n.IsRead, n.CreatedAt, n.InApp, key, params)
 	if err != nil {
 		return err
 	}
@@ -39,14 +148,27 @@ func (n *Notification) Save() error {
 	}
 
 	n.ID = id
-	return nil
+
+	if !email {
+		return nil
+	}
+
+	// The notification is in the inbox at once; its email waits for the
+	// user's quiet hours to end
+	notBefore, err := quietHoursEnd(ctx, p, n.UserID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	// The job sends pending deliveries
+	return queueDelivery(ctx, p, n.ID, DeliveryChannelEmail, notBefore)
 }
 
-func GetNotificationsByUserID(userID int64) ([]Notification, error) {
-	query := `SELECT id, user_id, event_id, message, type, is_read, created_at 
-			  FROM notifications WHERE user_id = ? ORDER BY created_at DESC`
+func (r sqlNotifications) GetByUserID(ctx context.Context, userID int64) ([]Notification, error) {
+	query := `SELECT id, user_id, event_id, message, type, is_read, created_at, message_key, message_params
+			  FROM notifications WHERE user_id = ? AND in_app = ? ORDER BY created_at DESC`
 
-	rows, err := db.DB.Query(query, userID)
+	rows, err := r.conn.QueryContext(ctx, query, userID, true)
 	if err != nil {
 		return nil, err
 	}
@@ -54,9 +176,7 @@ func GetNotificationsByUserID(userID int64) ([]Notification, error) {
 
 	var notifications []Notification
 	for rows.Next() {
-		var notification Notification
-		err := rows.Scan(&notification.ID, &notification.UserID, &notification.EventID,
-			&notification.Message, &notification.Type, &notification.IsRead, &notification.CreatedAt)
+		notification, err := scanNotification(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -66,68 +186,79 @@ func GetNotificationsByUserID(userID int64) ([]Notification, error) {
 	return notifications, nil
 }
 
-func MarkNotificationAsRead(notificationID int64) error {
-	query := `UPDATE notifications SET is_read = true WHERE id = ?`
-	stmt, err := db.DB.Prepare(query)
+// GetAfter returns up to limit of the user's notifications with
+// an id above afterID, oldest first. Streaming clients use it to catch up.
+func (r sqlNotifications) GetAfter(ctx context.Context, userID, afterID int64, limit int) ([]Notification, error) {
+	query := `SELECT id, user_id, event_id, message, type, is_read, created_at, message_key, message_params
+			  FROM notifications WHERE user_id = ? AND in_app = ? AND id > ? ORDER BY id LIMIT ?`
+
+	rows, err := r.conn.QueryContext(ctx, query, userID, true, afterID, limit)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer stmt.Close()
+	defer rows.Close()
 
-	_, err = stmt.Exec(notificationID)
-	return err
+	notifications := []Notification{}
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, rows.Err()
 }
 
-// GetUpcomingEventsForNotification finds events that are within the next 24 hours
-// and returns registered users for those events
-func GetUpcomingEventsForNotification() ([]struct {
-	EventID   int64
-	EventName string
-	DateTime  time.Time
-	UserID    int64
-}, error) {
-	query := `
-		SELECT e.id, e.name, e.dateTime, er.user_id
-		FROM events e
-		INNER JOIN events_registry er ON e.id = er.event_id
-		WHERE e.dateTime BETWEEN NOW() AND DATE_ADD(NOW(), INTERVAL 24 HOUR)
-		AND NOT EXISTS (
-			SELECT 1 FROM notifications n 
-			WHERE n.event_id = e.id 
-			AND n.user_id = er.user_id 
-			AND n.type = 'upcoming_event'
-			AND DATE(n.created_at) = CURDATE()
-		)
-	`
+func (r sqlNotifications) LastID(ctx context.Context, userID int64) (int64, error) {
+	query := `SELECT COALESCE(MAX(id), 0) FROM notifications WHERE user_id = ? AND in_app = ?`
+
+	var id int64
+	err := r.conn.QueryRowContext(ctx, query, userID, true).Scan(&id)
+
+	return id, err
+}
+
+// scanNotification reads a row of the listing queries' columns
+func scanNotification(rows *sql.Rows) (Notification, error) {
+	var notification Notification
+	var key string
+	var params sql.NullString
 
-	rows, err := db.DB.Query(query)
+	err := rows.Scan(&notification.ID, &notification.UserID, &notification.EventID,
+		&notification.Message, &notification.Type, &notification.IsRead, &notification.CreatedAt, &key, &params)
 	if err != nil {
-		return nil, err
+		return notification, err
 	}
-	defer rows.Close()
 
-	var results []struct {
-		EventID   int64
-		EventName string
-		DateTime  time.Time
-		UserID    int64
-	}
+	notification.Localized, err = decodeLocalizedMessage(key, params)
+	return notification, err
+}
 
-	for rows.Next() {
-		var result struct {
-			EventID   int64
-			EventName string
-			DateTime  time.Time
-			UserID    int64
-		}
+// decodeLocalizedMessage reads the message_key and message_params columns
+func decodeLocalizedMessage(key string, params sql.NullString) (*LocalizedMessage, error) {
+	if key == "" {
+		return nil, nil
+	}
 
-		err := rows.Scan(&result.EventID, &result.EventName, &result.DateTime, &result.UserID)
-		if err != nil {
+	message := &LocalizedMessage{Key: key}
+	if params.Valid {
+		if err := json.Unmarshal([]byte(params.String), &message.Params); err != nil {
 			return nil, err
 		}
+	}
+
+	return message, nil
+}
 
-		results = append(results, result)
+func (r sqlNotifications) MarkAsRead(ctx context.Context, notificationID int64) error {
+	query := `UPDATE notifications SET is_read = true WHERE id = ?`
+	stmt, err := r.conn.PrepareContext(ctx, query)
+	if err != nil {
+		return err
 	}
+	defer stmt.Close()
 
-	return results, nil
+	_, err = stmt.ExecContext(ctx, notificationID)
+	return err
 }
@@ -0,0 +1,368 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// NotificationChannelInApp is the notification inbox and stream; email is
+// DeliveryChannelEmail
+const NotificationChannelInApp = "in_app"
+
+// maxQuietHours bounds how many quiet windows a user may set
+const maxQuietHours = 5
+
+var (
+	NotificationTypes    = []string{NotificationTypeUpcomingEvent, NotificationTypeWaitlistPromoted}
+	NotificationChannels = []string{NotificationChannelInApp, DeliveryChannelEmail}
+)
+
+var (
+	ErrUnknownNotificationType    = errors.New("unknown notification type")
+	ErrUnknownNotificationChannel = errors.New("unknown notification channel")
+	ErrInvalidQuietHours          = errors.New("quiet hours need different start and end times, at most 5 windows")
+)
+
+// NotificationPreferences are a user's channel toggles per notification type
+// and the quiet hours during which emails wait
+type NotificationPreferences struct {
+	UserID     int64                      `json:"-"`
+	Types      map[string]map[string]bool `json:"types"`
+	QuietHours []QuietHours               `json:"quiet_hours"`
+}
+
+// QuietHours is a daily window in the user's settings timezone. It crosses
+// midnight when Start is after End.
+type QuietHours struct {
+	Start ClockTime `json:"start"`
+	End   ClockTime `json:"end"`
+}
+
+// ClockTime is a time of day in minutes after midnight, written as "HH:MM"
+type ClockTime int
+
+func (c ClockTime) String() string {
+	return fmt.Sprintf("%02d:%02d", c/60, c%60)
+}
+
+func (c ClockTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *ClockTime) UnmarshalJSON(data []byte) error {
+	var value string
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	parsed, err := time.Parse("15:04", value)
+
+	if err != nil {
+		return fmt.Errorf("times must be written as HH:MM: %w", err)
+	}
+
+	*c = ClockTime(parsed.Hour()*60 + parsed.Minute())
+
+	return nil
+}
+
+func (c ClockTime) valid() bool {
+	return c >= 0 && c < 24*60
+}
+
+// DefaultNotificationPreferences enable every type on every channel
+func DefaultNotificationPreferences(userID int64) *NotificationPreferences {
+	preferences := &NotificationPreferences{
+		UserID:     userID,
+		Types:      map[string]map[string]bool{},
+		QuietHours: []QuietHours{},
+	}
+
+	for _, notificationType := range NotificationTypes {
+		preferences.Types[notificationType] = map[string]bool{}
+
+		for _, channel := range NotificationChannels {
+			preferences.Types[notificationType][channel] = true
+		}
+	}
+
+	return preferences
+}
+
+// Preferences returns the user's preferences, with defaults
+// for everything the user has not set
+func (r sqlNotifications) Preferences(ctx context.Context, userID int64) (*NotificationPreferences, error) {
+	preferences := DefaultNotificationPreferences(userID)
+
+	rows, err := r.conn.QueryContext(ctx, `SELECT type, channel, enabled FROM notification_preferences WHERE user_id = ?`, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var notificationType, channel string
+		var enabled bool
+
+		if err := rows.Scan(&notificationType, &channel, &enabled); err != nil {
+			return nil, err
+		}
+
+		// Types that no longer exist are ignored
+		if channels, ok := preferences.Types[notificationType]; ok {
+			channels[channel] = enabled
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT start_minute, end_minute FROM quiet_hours WHERE user_id = ? ORDER BY id`
+	windows, err := r.conn.QueryContext(ctx, query, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer windows.Close()
+
+	for windows.Next() {
+		var window QuietHours
+
+		if err := windows.Scan(&window.Start, &window.End); err != nil {
+			return nil, err
+		}
+
+		preferences.QuietHours = append(preferences.QuietHours, window)
+	}
+
+	return preferences, windows.Err()
+}
+
+// Merge applies the toggles in changes over p and replaces p's quiet hours
+// with those in changes
+func (p *NotificationPreferences) Merge(changes NotificationPreferences) error {
+	for notificationType, channels := range changes.Types {
+		current, ok := p.Types[notificationType]
+
+		if !ok {
+			return fmt.Errorf("%w %q", ErrUnknownNotificationType, notificationType)
+		}
+
+		for channel, enabled := range channels {
+			if _, ok := current[channel]; !ok {
+				return fmt.Errorf("%w %q", ErrUnknownNotificationChannel, channel)
+			}
+
+			current[channel] = enabled
+		}
+	}
+
+	if len(changes.QuietHours) > maxQuietHours {
+		return ErrInvalidQuietHours
+	}
+
+	quietHours := []QuietHours{}
+
+	for _, window := range changes.QuietHours {
+		if window.Start == window.End || !window.Start.valid() || !window.End.valid() {
+			return ErrInvalidQuietHours
+		}
+
+		quietHours = append(quietHours, window)
+	}
+
+	p.QuietHours = quietHours
+
+	return nil
+}
+
+// validTimezone accepts IANA zone names, but not the server's Local zone
+func validTimezone(name string) bool {
+	_, err := time.LoadLocation(name)
+
+	return err == nil && name != "Local"
+}
+
+// SavePreferences replaces the user's stored preferences
+func (r sqlNotifications) SavePreferences(ctx context.Context, p *NotificationPreferences) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, table := range []string{"notification_preferences", "quiet_hours"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = ?`, p.UserID); err != nil {
+			return err
+		}
+	}
+
+	for notificationType, channels := range p.Types {
+		for channel, enabled := range channels {
+			query := `INSERT INTO notification_preferences (user_id, type, channel, enabled) VALUES (?, ?, ?, ?)`
+
+			if _, err := tx.ExecContext(ctx, query, p.UserID, notificationType, channel, enabled); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, window := range p.QuietHours {
+		query := `INSERT INTO quiet_hours (user_id, start_minute, end_minute) VALUES (?, ?, ?)`
+
+		if _, err := tx.ExecContext(ctx, query, p.UserID, window.Start, window.End); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Enabled reports whether the user wants notifications of the type over the
+// channel
+func (p *NotificationPreferences) Enabled(notificationType, channel string) bool {
+	enabled, ok := p.Types[notificationType][channel]
+
+	return enabled || !ok
+}
+
+// QuietUntil reports whether now falls in the user's quiet hours, read in
+// the user's timezone, and if so when they end. Overlapping or adjacent
+// windows are treated as one.
+func (p *NotificationPreferences) QuietUntil(now time.Time, location *time.Location) (time.Time, bool) {
+	return quietUntil(p.QuietHours, now, location)
+}
+
+func quietUntil(windows []QuietHours, now time.Time, location *time.Location) (time.Time, bool) {
+	until := now
+
+	// Each pass can only move past one more window
+	for range len(windows) {
+		moved := false
+
+		for _, window := range windows {
+			if end, ok := window.endAfter(until, location); ok {
+				until, moved = end, true
+			}
+		}
+
+		if !moved {
+			break
+		}
+	}
+
+	return until, until.After(now)
+}
+
+// endAfter returns the end of the window if t falls inside it
+func (w QuietHours) endAfter(t time.Time, location *time.Location) (time.Time, bool) {
+	local := t.In(location)
+	minute := ClockTime(local.Hour()*60 + local.Minute())
+
+	var inside bool
+	if w.Start < w.End {
+		inside = minute >= w.Start && minute < w.End
+	} else {
+		inside = minute >= w.Start || minute < w.End
+	}
+
+	if !inside {
+		return time.Time{}, false
+	}
+
+	end := time.Date(local.Year(), local.Month(), local.Day(), int(w.End/60), int(w.End%60), 0, 0, location)
+
+	if !end.After(t) {
+		end = time.Date(local.Year(), local.Month(), local.Day()+1, int(w.End/60), int(w.End%60), 0, 0, location)
+	}
+
+	return end.UTC(), true
+}
+
+// quietHoursEnd returns when the user's quiet hours end if now falls in them,
+// and now otherwise, read through db or an open transaction
+func quietHoursEnd(ctx context.Context, p preparer, userID int64, now time.Time) (time.Time, error) {
+	query := `
+		SELECT q.start_minute, q.end_minute, u.timezone
+		FROM quiet_hours q INNER JOIN users u ON u.id = q.user_id
+		WHERE q.user_id = ? ORDER BY q.id
+	`
+	stmt, err := p.PrepareContext(ctx, query)
+	if err != nil {
+		return now, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return now, err
+	}
+	defer rows.Close()
+
+	var windows []QuietHours
+	var timezone string
+
+	for rows.Next() {
+		var window QuietHours
+
+		if err := rows.Scan(&window.Start, &window.End, &timezone); err != nil {
+			return now, err
+		}
+
+		windows = append(windows, window)
+	}
+
+	if err := rows.Err(); err != nil {
+		return now, err
+	}
+
+	until, _ := quietUntil(windows, now, loadZone(timezone))
+
+	return until, nil
+}
+
+// notificationChannels reports which channels the user wants the type on,
+// read through db or an open transaction
+func notificationChannels(ctx context.Context, p preparer, userID int64, notificationType string) (inApp bool, email bool, err error) {
+	stmt, err := p.PrepareContext(ctx, `SELECT channel, enabled FROM notification_preferences WHERE user_id = ? AND type = ?`)
+	if err != nil {
+		return false, false, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID, notificationType)
+	if err != nil {
+		return false, false, err
+	}
+	defer rows.Close()
+
+	inApp, email = true, true
+
+	for rows.Next() {
+		var channel string
+		var enabled bool
+
+		if err := rows.Scan(&channel, &enabled); err != nil {
+			return false, false, err
+		}
+
+		switch channel {
+		case NotificationChannelInApp:
+			inApp = enabled
+		case DeliveryChannelEmail:
+			email = enabled
+		}
+	}
+
+	return inApp, email, rows.Err()
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationPreferences_MergeAndSave(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+
+	preferences, err := testRepositories().Notifications.Preferences(context.Background(), users[0].ID)
+	assert.NoError(t, err)
+	assert.True(t, preferences.Enabled(NotificationTypeUpcomingEvent, DeliveryChannelEmail))
+	assert.Empty(t, preferences.QuietHours)
+
+	var changes NotificationPreferences
+	body := `{"types": {"upcoming_event": {"email": false}}, "quiet_hours": [{"start": "22:00", "end": "07:30"}]}`
+	assert.NoError(t, json.Unmarshal([]byte(body), &changes))
+	assert.NoError(t, preferences.Merge(changes))
+	assert.NoError(t, testRepositories().Notifications.SavePreferences(context.Background(), preferences))
+
+	preferences, err = testRepositories().Notifications.Preferences(context.Background(), users[0].ID)
+	assert.NoError(t, err)
+	assert.False(t, preferences.Enabled(NotificationTypeUpcomingEvent, DeliveryChannelEmail))
+	assert.True(t, preferences.Enabled(NotificationTypeUpcomingEvent, NotificationChannelInApp))
+	assert.True(t, preferences.Enabled(NotificationTypeWaitlistPromoted, DeliveryChannelEmail))
+	assert.Equal(t, []QuietHours{{Start: 22 * 60, End: 7*60 + 30}}, preferences.QuietHours)
+
+	encoded, err := json.Marshal(preferences.QuietHours)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"start": "22:00", "end": "07:30"}]`, string(encoded))
+
+	invalid := []NotificationPreferences{
+		{Types: map[string]map[string]bool{"party": {"email": false}}},
+		{Types: map[string]map[string]bool{NotificationTypeUpcomingEvent: {"sms": false}}},
+		{QuietHours: []QuietHours{{Start: 60, End: 60}}},
+		{QuietHours: []QuietHours{{Start: 60, End: 24 * 60}}},
+	}
+
+	for _, changes := range invalid {
+		assert.Error(t, preferences.Merge(changes))
+	}
+}
+
+func TestNotificationPreferences_QuietUntil(t *testing.T) {
+	preferences := DefaultNotificationPreferences(1)
+	preferences.QuietHours = []QuietHours{
+		{Start: 22 * 60, End: 7 * 60},
+		{Start: 6 * 60, End: 8 * 60},
+	}
+
+	// Windows are read in the user's timezone, UTC+6
+	dhaka, err := time.LoadLocation("Asia/Dhaka")
+	assert.NoError(t, err)
+
+	// Before midnight the window ends the next morning, extended by the
+	// overlapping one
+	until, quiet := preferences.QuietUntil(time.Date(2030, 1, 7, 23, 0, 0, 0, dhaka), dhaka)
+	assert.True(t, quiet)
+	assert.Equal(t, time.Date(2030, 1, 8, 2, 0, 0, 0, time.UTC), until)
+
+	until, quiet = preferences.QuietUntil(time.Date(2030, 1, 8, 6, 30, 0, 0, dhaka), dhaka)
+	assert.True(t, quiet)
+	assert.Equal(t, time.Date(2030, 1, 8, 2, 0, 0, 0, time.UTC), until)
+
+	_, quiet = preferences.QuietUntil(time.Date(2030, 1, 8, 12, 0, 0, 0, dhaka), dhaka)
+	assert.False(t, quiet)
+
+	// The same instant is outside them for a user in UTC
+	_, quiet = preferences.QuietUntil(time.Date(2030, 1, 7, 23, 0, 0, 0, dhaka), time.UTC)
+	assert.False(t, quiet)
+}
+
+func TestNotification_SaveDuringQuietHours(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	event := createTestEvent(t, users[0].ID, nil, false)
+
+	// Quiet from an hour ago until an hour from now in the user's own
+	// timezone, which is not the server's
+	dhaka, err := time.LoadLocation("Asia/Dhaka")
+	assert.NoError(t, err)
+	assert.NoError(t, testRepositories().Users.UpdateSettings(context.Background(), users[0].ID,
+		UserSettings{Timezone: "Asia/Dhaka", Locale: "en"}))
+
+	now := time.Now().In(dhaka)
+	clock := func(t time.Time) ClockTime { return ClockTime(t.Hour()*60 + t.Minute()) }
+	preferences := DefaultNotificationPreferences(users[0].ID)
+	quiet := []QuietHours{{Start: clock(now.Add(-time.Hour)), End: clock(now.Add(time.Hour))}}
+	assert.NoError(t, preferences.Merge(NotificationPreferences{QuietHours: quiet}))
+	assert.NoError(t, testRepositories().Notifications.SavePreferences(context.Background(), preferences))
+
+	// In the inbox at once, emailed once the quiet hours end
+	reminder := Notification{UserID: users[0].ID, EventID: event.ID, Message: "Soon", Type: NotificationTypeUpcomingEvent, CreatedAt: time.Now()}
+	assert.NoError(t, testRepositories().Notifications.Save(context.Background(), &reminder))
+
+	notifications, err := testRepositories().Notifications.GetByUserID(context.Background(), users[0].ID)
+	assert.NoError(t, err)
+	assert.Len(t, notifications, 1)
+
+	deliveries, err := testRepositories().Deliveries.ForNotification(context.Background(), reminder.ID)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.WithinDuration(t, now.Add(time.Hour), deliveries[0].NextAttemptAt, time.Minute)
+}
+
+func TestNotification_SaveHonorsChannels(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	var announced []Notification
+	OnNotificationCreated(func(n Notification) { announced = append(announced, n) })
+	t.Cleanup(func() { notificationListeners = nil })
+
+	users := createTestUsers(t, 1)
+	event := createTestEvent(t, users[0].ID, nil, false)
+
+	preferences := DefaultNotificationPreferences(users[0].ID)
+	assert.NoError(t, preferences.Merge(NotificationPreferences{Types: map[string]map[string]bool{
+		NotificationTypeUpcomingEvent:    {NotificationChannelInApp: false},
+		NotificationTypeWaitlistPromoted: {NotificationChannelInApp: false, DeliveryChannelEmail: false},
+	}}))
+	assert.NoError(t, testRepositories().Notifications.SavePreferences(context.Background(), preferences))
+
+	// Email only: stored for delivery but kept out of the inbox
+	reminder := Notification{UserID: users[0].ID, EventID: event.ID, Message: "Soon", Type: NotificationTypeUpcomingEvent, CreatedAt: time.Now()}
+	assert.NoError(t, testRepositories().Notifications.Save(context.Background(), &reminder))
+	assert.NotZero(t, reminder.ID)
+	assert.Empty(t, announced)
+
+	deliveries, err := testRepositories().Deliveries.ForNotification(context.Background(), reminder.ID)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+
+	notifications, err := testRepositories().Notifications.GetByUserID(context.Background(), users[0].ID)
+	assert.NoError(t, err)
+	assert.Empty(t, notifications)
+
+	// Off everywhere: not stored at all
+	promotion := Notification{UserID: users[0].ID, EventID: event.ID, Message: "In", Type: NotificationTypeWaitlistPromoted, CreatedAt: time.Now()}
+	assert.NoError(t, testRepositories().Notifications.Save(context.Background(), &promotion))
+	assert.Zero(t, promotion.ID)
+	assert.Empty(t, announced)
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/rest-api/i18n"
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnNotificationCreated(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	var created []Notification
+	OnNotificationCreated(func(n Notification) { created = append(created, n) })
+	t.Cleanup(func() { notificationListeners = nil })
+
+	users := createTestUsers(t, 2)
+	capacity := int64(1)
+	event := createTestEvent(t, users[0].ID, &capacity, true)
+
+	notification := Notification{UserID: users[0].ID, EventID: event.ID, Message: "Hello", Type: NotificationTypeUpcomingEvent, CreatedAt: time.Now()}
+	assert.NoError(t, testRepositories().Notifications.Save(context.Background(), &notification))
+	assert.Len(t, created, 1)
+	assert.Equal(t, notification.ID, created[0].ID)
+
+	// A promotion is announced once its transaction has committed
+	registrations := []EventRegister{{EventID: event.ID, UserID: users[0].ID}, {EventID: event.ID, UserID: users[1].ID}}
+	for i := range registrations {
+		assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registrations[i]))
+	}
+	assert.NoError(t, testRepositories().Registrations.Cancel(context.Background(), &registrations[0]))
+
+	assert.Len(t, created, 2)
+	assert.Equal(t, users[1].ID, created[1].UserID)
+	assert.Equal(t, NotificationTypeWaitlistPromoted, created[1].Type)
+	assert.NotZero(t, created[1].ID)
+}
+
+func TestGetNotificationsAfter(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 2)
+	event := createTestEvent(t, users[0].ID, nil, false)
+
+	var ids []int64
+	for i, user := range []User{users[0], users[1], users[0], users[0]} {
+		notification := Notification{UserID: user.ID, EventID: event.ID, Message: "Message", Type: NotificationTypeUpcomingEvent, CreatedAt: time.Now().Add(time.Duration(i) * time.Minute)}
+		assert.NoError(t, testRepositories().Notifications.Save(context.Background(), &notification))
+		ids = append(ids, notification.ID)
+	}
+
+	missed, err := testRepositories().Notifications.GetAfter(context.Background(), users[0].ID, ids[0], 10)
+	assert.NoError(t, err)
+	assert.Len(t, missed, 2)
+	assert.Equal(t, ids[2], missed[0].ID)
+	assert.Equal(t, ids[3], missed[1].ID)
+
+	missed, err = testRepositories().Notifications.GetAfter(context.Background(), users[0].ID, 0, 1)
+	assert.NoError(t, err)
+	assert.Len(t, missed, 1)
+	assert.Equal(t, ids[0], missed[0].ID)
+
+	missed, err = testRepositories().Notifications.GetAfter(context.Background(), users[0].ID, ids[3], 10)
+	assert.NoError(t, err)
+	assert.Empty(t, missed)
+}
+
+func TestNotification_LocalizeAtReadTime(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	event := createTestEvent(t, users[0].ID, nil, false)
+	start := time.Date(2030, 1, 7, 12, 0, 0, 0, time.UTC)
+
+	notification := Notification{UserID: users[0].ID, EventID: event.ID, Type: NotificationTypeUpcomingEvent, CreatedAt: time.Now(),
+		Localized: &LocalizedMessage{Key: "upcoming_event.later", Params: MessageParams{Event: "Standup", Start: start}}}
+	assert.NoError(t, testRepositories().Notifications.Save(context.Background(), &notification))
+	assert.Equal(t, "Reminder: You have an upcoming event 'Standup' on January 7, 2030 at 12:00 PM UTC", notification.Message)
+
+	assert.ErrorIs(t, testRepositories().Users.UpdateSettings(context.Background(), users[0].ID, UserSettings{Timezone: "Asia/Dhaka", Locale: "xx"}), i18n.ErrUnsupportedLocale)
+	assert.NoError(t, testRepositories().Users.UpdateSettings(context.Background(), users[0].ID, UserSettings{Timezone: "Asia/Dhaka", Locale: "bn-BD"}))
+
+	settings, err := testRepositories().Users.GetSettings(context.Background(), users[0].ID)
+	assert.NoError(t, err)
+
+	notifications, err := testRepositories().Notifications.GetByUserID(context.Background(), users[0].ID)
+	assert.NoError(t, err)
+	assert.Len(t, notifications, 1)
+
+	notifications[0].Localize(settings)
+	assert.Equal(t, "অনুস্মারক: ৭ জানুয়ারি ২০৩০, ১৮:০০ +০৬-এ আপনার ইভেন্ট 'Standup' আছে", notifications[0].Message)
+
+	// Notifications stored before the catalog keep their text
+	legacy := Notification{Message: "Hello"}
+	legacy.Localize(settings)
+	assert.Equal(t, "Hello", legacy.Message)
+}
@@ -0,0 +1,427 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxOccurrenceWindow bounds how far recurring events are expanded in one request
+const MaxOccurrenceWindow = 366 * 24 * time.Hour
+
+var (
+	ErrInvalidOccurrence = errors.New("not an occurrence of this event")
+	ErrExceptionNotFound = errors.New("occurrence has no exception")
+)
+
+// Occurrence is a single date of an event. For a recurring event DateTime is
+// when this occurrence actually happens and Occurrence is the key of its
+// originally scheduled start, which stays the same if the date is moved.
+// One-off events have an empty key.
+type Occurrence struct {
+	Event
+	Occurrence string
+	Moved      bool
+}
+
+// OccurrencePage is one page of expanded occurrences
+type OccurrencePage struct {
+	Events     []Occurrence `json:"events"`
+	NextCursor string       `json:"next_cursor"`
+	Total      int64        `json:"total"`
+}
+
+// EventException skips or moves one occurrence of a recurring event
+type EventException struct {
+	ID         int64
+	EventID    int64
+	Occurrence string
+	Canceled   bool
+	NewStart   *time.Time
+}
+
+// OccurrenceKey identifies an occurrence by its scheduled start in UTC
+func OccurrenceKey(start time.Time) string {
+	return start.UTC().Format(time.RFC3339)
+}
+
+func parseOccurrenceKey(key string) (time.Time, error) {
+	start, err := time.Parse(time.RFC3339, key)
+
+	if err != nil || OccurrenceKey(start) != key {
+		return time.Time{}, ErrInvalidOccurrence
+	}
+
+	return start, nil
+}
+
+// ValidOccurrence reports whether key names a scheduled occurrence of the
+// event, ignoring exceptions. One-off events only accept the empty key.
+func (e *Event) ValidOccurrence(key string) bool {
+	rule, err := e.Recurrence()
+
+	if err != nil || rule == nil {
+		return err == nil && key == ""
+	}
+
+	start, err := parseOccurrenceKey(key)
+
+	return err == nil && rule.Includes(e.DateTime.In(e.Zone()), start)
+}
+
+// Occurrences expands the event within [from, to], applying its exceptions
+func (r sqlEvents) Occurrences(ctx context.Context, e *Event, from, to time.Time) ([]Occurrence, error) {
+	exceptions, err := r.getEventExceptions(ctx, []int64{e.ID})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return expandEvent(*e, exceptions[e.ID], from, to)
+}
+
+// expandEvent lists the occurrences of event within [from, to] in order.
+// Canceled occurrences are left out and moved ones appear at their new time.
+func expandEvent(event Event, exceptions map[string]EventException, from, to time.Time) ([]Occurrence, error) {
+	rule, err := event.Recurrence()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rule == nil {
+		if event.DateTime.Before(from) || event.DateTime.After(to) {
+			return nil, nil
+		}
+
+		return []Occurrence{{Event: event}}, nil
+	}
+
+	// Expanding in the event's timezone keeps the local time across daylight
+	// saving changes
+	var occurrences []Occurrence
+	zone := event.Zone()
+	seriesStart := event.DateTime.In(zone)
+
+	for _, start := range rule.Between(seriesStart, from, to) {
+		key := OccurrenceKey(start)
+
+		if _, changed := exceptions[key]; changed {
+			continue
+		}
+
+		occurrence := Occurrence{Event: event, Occurrence: key}
+		occurrence.DateTime = start
+		occurrences = append(occurrences, occurrence)
+	}
+
+	// Moved occurrences belong wherever they now fall, even if their original
+	// date is outside the window
+	for key, exception := range exceptions {
+		if exception.Canceled || exception.NewStart == nil {
+			continue
+		}
+
+		if exception.NewStart.Before(from) || exception.NewStart.After(to) {
+			continue
+		}
+
+		if !event.ValidOccurrence(key) {
+			continue
+		}
+
+		occurrence := Occurrence{Event: event, Occurrence: key, Moved: true}
+		occurrence.DateTime = exception.NewStart.In(zone)
+		occurrences = append(occurrences, occurrence)
+	}
+
+	sort.Slice(occurrences, func(i, j int) bool {
+		return occurrences[i].DateTime.Before(occurrences[j].DateTime)
+	})
+
+	return occurrences, nil
+}
+
+// ListOccurrences is List with recurring events expanded into their
+// occurrences between q.From and q.To, which are both required
+func (r sqlEvents) ListOccurrences(ctx context.Context, q EventQuery) (*OccurrencePage, error) {
+	q.Expand = true
+
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	var cursor *eventCursor
+
+	if q.Cursor != "" {
+		var err error
+		cursor, err = decodeEventCursor(q.Cursor, q.Sort)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	from, to := q.From.UTC(), q.To.UTC()
+
+	// Every other filter applies to the series as a whole
+	seriesFilters := q
+	seriesFilters.From, seriesFilters.To = nil, nil
+	conditions, args := seriesFilters.filters(r.dialect)
+
+	dateTime := r.dialect.Timestamp("dateTime")
+	param := r.dialect.Timestamp("?")
+
+	conditions = append(conditions, "((rrule = '' AND "+dateTime+" >= "+param+" AND "+dateTime+" <= "+param+")"+
+		" OR (rrule <> '' AND "+dateTime+" <= "+param+")"+
+		" OR id IN (SELECT event_id FROM event_exceptions WHERE "+r.dialect.Timestamp("new_start")+" BETWEEN "+param+" AND "+param+"))")
+	args = append(args, from, to, to, from, to)
+
+	events, err := r.queryEvents(ctx, "SELECT "+eventColumns+" FROM events"+where(conditions), args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+	for _, event := range events {
+		if event.RRule != "" {
+			ids = append(ids, event.ID)
+		}
+	}
+
+	exceptions, err := r.getEventExceptions(ctx, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var occurrences []Occurrence
+
+	for _, event := range events {
+		expanded, err := expandEvent(event, exceptions[event.ID], from, to)
+
+		if err != nil {
+			return nil, err
+		}
+
+		occurrences = append(occurrences, expanded...)
+	}
+
+	descending := q.Sort == EventSortDateDesc
+
+	sort.Slice(occurrences, func(i, j int) bool {
+		return occurrenceBefore(occurrences[i], occurrences[j], descending)
+	})
+
+	page := OccurrencePage{Events: []Occurrence{}, Total: int64(len(occurrences))}
+
+	for _, occurrence := range occurrences {
+		if cursor != nil && !occurrenceBefore(Occurrence{Event: Event{ID: cursor.ID, DateTime: cursor.DateTime}}, occurrence, descending) {
+			continue
+		}
+
+		if len(page.Events) == q.Limit {
+			last := page.Events[len(page.Events)-1]
+			page.NextCursor = encodeEventCursor(eventCursor{Sort: q.Sort, DateTime: last.DateTime.UTC(), ID: last.ID})
+			break
+		}
+
+		page.Events = append(page.Events, occurrence)
+	}
+
+	return &page, nil
+}
+
+// occurrenceBefore orders occurrences by start time, then event id
+func occurrenceBefore(a, b Occurrence, descending bool) bool {
+	return keyBefore(a.DateTime, a.ID, b.DateTime, b.ID, descending)
+}
+
+// keyBefore orders (time, id) pairs the way keyset cursors expect
+func keyBefore(aTime time.Time, aID int64, bTime time.Time, bID int64, descending bool) bool {
+	if !aTime.Equal(bTime) {
+		return aTime.Before(bTime) != descending
+	}
+
+	if aID == bID {
+		return false
+	}
+
+	return (aID < bID) != descending
+}
+
+func (s sqlStore) queryEvents(ctx context.Context, query string, args ...any) ([]Event, error) {
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var events []Event
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// getEventExceptions loads the exceptions of the given events, by event id
+// and occurrence key
+func (s sqlStore) getEventExceptions(ctx context.Context, eventIDs []int64) (map[int64]map[string]EventException, error) {
+	exceptions := map[int64]map[string]EventException{}
+
+	if len(eventIDs) == 0 {
+		return exceptions, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(eventIDs)), ", ")
+	args := make([]any, len(eventIDs))
+
+	for i, id := range eventIDs {
+		args[i] = id
+	}
+
+	query := `SELECT id, event_id, occurrence, canceled, new_start FROM event_exceptions WHERE event_id IN (` + placeholders + `)`
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var exception EventException
+
+		err := rows.Scan(&exception.ID, &exception.EventID, &exception.Occurrence, &exception.Canceled, &exception.NewStart)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if exceptions[exception.EventID] == nil {
+			exceptions[exception.EventID] = map[string]EventException{}
+		}
+
+		exceptions[exception.EventID][exception.Occurrence] = exception
+	}
+
+	return exceptions, rows.Err()
+}
+
+// SaveException replaces any existing exception for the same occurrence
+func (r sqlEvents) SaveException(ctx context.Context, ex *EventException) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM event_exceptions WHERE event_id = ? AND occurrence = ?`, ex.EventID, ex.Occurrence)
+
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO event_exceptions (event_id, occurrence, canceled, new_start) VALUES (?, ?, ?, ?)`
+	result, err := tx.ExecContext(ctx, query, ex.EventID, ex.Occurrence, ex.Canceled, ex.NewStart)
+
+	if err != nil {
+		return err
+	}
+
+	ex.ID, err = result.LastInsertId()
+
+	if err != nil {
+		return err
+	}
+
+	saved := *ex
+	change := EventChange{Type: EventChangeOccurrenceUpdated, EventID: ex.EventID, Occurrence: ex.Occurrence, Exception: &saved}
+
+	if err := storeOccurrenceChange(ctx, tx, &change, WebhookOccurrenceUpdated, saved); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	eventChanged(change)
+
+	return nil
+}
+
+// DeleteException restores an occurrence to its scheduled date
+func (r sqlEvents) DeleteException(ctx context.Context, eventID int64, occurrence string) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM event_exceptions WHERE event_id = ? AND occurrence = ?`, eventID, occurrence)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrExceptionNotFound
+	}
+
+	change := EventChange{Type: EventChangeOccurrenceRestored, EventID: eventID, Occurrence: occurrence}
+	restored := EventException{EventID: eventID, Occurrence: occurrence}
+
+	if err := storeOccurrenceChange(ctx, tx, &change, WebhookOccurrenceRestored, restored); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	eventChanged(change)
+
+	return nil
+}
+
+// storeOccurrenceChange queues the webhooks for a change to one occurrence
+// and stores the change inside tx
+func storeOccurrenceChange(ctx context.Context, tx *sql.Tx, change *EventChange, webhookType string, data EventException) error {
+	var ownerID int64
+	err := tx.QueryRowContext(ctx, `SELECT user_id FROM events WHERE id = ?`, change.EventID).Scan(&ownerID)
+
+	if err != nil {
+		return err
+	}
+
+	if err := queueEventWebhooks(ctx, tx, change.EventID, ownerID, webhookType, data); err != nil {
+		return err
+	}
+
+	return storeEventChange(ctx, tx, change)
+}
@@ -0,0 +1,214 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func occurrenceDates(occurrences []Occurrence) []string {
+	dates := make([]string, len(occurrences))
+	for i, occurrence := range occurrences {
+		dates[i] = occurrence.Name + " " + occurrence.DateTime.UTC().Format("01-02 15:04")
+	}
+	return dates
+}
+
+func createWeeklyEvent(t *testing.T, ownerID int64, capacity *int64) Event {
+	event := Event{
+		Name:        "Weekly Meetup",
+		Description: "Every Monday",
+		Location:    "Dhaka",
+		DateTime:    time.Date(2030, 1, 7, 18, 0, 0, 0, time.UTC), // a Monday
+		UserID:      ownerID,
+		Capacity:    capacity,
+		RRule:       "FREQ=WEEKLY;COUNT=4",
+	}
+	assert.NoError(t, testRepositories().Events.Save(context.Background(), &event))
+
+	return event
+}
+
+func TestListOccurrences_ExpandsAndAppliesExceptions(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	weekly := createWeeklyEvent(t, users[0].ID, nil)
+
+	oneOff := Event{Name: "Launch", Description: "Once", Location: "Dhaka",
+		DateTime: time.Date(2030, 1, 10, 9, 0, 0, 0, time.UTC), UserID: users[0].ID}
+	assert.NoError(t, testRepositories().Events.Save(context.Background(), &oneOff))
+
+	from := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+	query := EventQuery{From: &from, To: &to}
+
+	page, err := testRepositories().Events.ListOccurrences(context.Background(), query)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Weekly Meetup 01-07 18:00", "Launch 01-10 09:00", "Weekly Meetup 01-14 18:00",
+		"Weekly Meetup 01-21 18:00", "Weekly Meetup 01-28 18:00"}, occurrenceDates(page.Events))
+	assert.Equal(t, "2030-01-14T18:00:00Z", page.Events[2].Occurrence)
+	assert.Empty(t, page.Events[1].Occurrence)
+
+	skip := EventException{EventID: weekly.ID, Occurrence: "2030-01-14T18:00:00Z", Canceled: true}
+	assert.NoError(t, testRepositories().Events.SaveException(context.Background(), &skip))
+
+	// The last occurrence moves into February, out of the window
+	moved := time.Date(2030, 2, 1, 18, 0, 0, 0, time.UTC)
+	move := EventException{EventID: weekly.ID, Occurrence: "2030-01-28T18:00:00Z", NewStart: &moved}
+	assert.NoError(t, testRepositories().Events.SaveException(context.Background(), &move))
+
+	page, err = testRepositories().Events.ListOccurrences(context.Background(), query)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Weekly Meetup 01-07 18:00", "Launch 01-10 09:00", "Weekly Meetup 01-21 18:00"},
+		occurrenceDates(page.Events))
+
+	february := time.Date(2030, 2, 28, 0, 0, 0, 0, time.UTC)
+	occurrences, err := testRepositories().Events.Occurrences(context.Background(), &weekly, to, february)
+	assert.NoError(t, err)
+	assert.Len(t, occurrences, 1)
+	assert.True(t, occurrences[0].Moved)
+	assert.Equal(t, "2030-01-28T18:00:00Z", occurrences[0].Occurrence)
+	assert.Equal(t, moved, occurrences[0].DateTime)
+
+	assert.NoError(t, testRepositories().Events.DeleteException(context.Background(), weekly.ID, "2030-01-14T18:00:00Z"))
+	assert.ErrorIs(t, testRepositories().Events.DeleteException(context.Background(), weekly.ID, "2030-01-14T18:00:00Z"), ErrExceptionNotFound)
+
+	page, err = testRepositories().Events.ListOccurrences(context.Background(), query)
+	assert.NoError(t, err)
+	assert.Len(t, page.Events, 4)
+}
+
+func TestListOccurrences_Paginates(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	createWeeklyEvent(t, users[0].ID, nil)
+
+	from := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2030, 12, 31, 0, 0, 0, 0, time.UTC)
+	query := EventQuery{From: &from, To: &to, Sort: EventSortDateDesc, Limit: 3}
+
+	page, err := testRepositories().Events.ListOccurrences(context.Background(), query)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), page.Total)
+	assert.Equal(t, []string{"Weekly Meetup 01-28 18:00", "Weekly Meetup 01-21 18:00", "Weekly Meetup 01-14 18:00"},
+		occurrenceDates(page.Events))
+	assert.NotEmpty(t, page.NextCursor)
+
+	query.Cursor = page.NextCursor
+	page, err = testRepositories().Events.ListOccurrences(context.Background(), query)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Weekly Meetup 01-07 18:00"}, occurrenceDates(page.Events))
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestListOccurrences_InvalidWindow(t *testing.T) {
+	from := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	tooLate := from.Add(MaxOccurrenceWindow + time.Hour)
+
+	for _, query := range []EventQuery{
+		{},
+		{From: &from, To: &from},
+		{From: &from, To: &tooLate},
+	} {
+		_, err := testRepositories().Events.ListOccurrences(context.Background(), query)
+		assert.Error(t, err)
+	}
+}
+
+func TestEventRegister_PerOccurrence(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 2)
+	capacity := int64(1)
+	weekly := createWeeklyEvent(t, users[0].ID, &capacity)
+
+	first := EventRegister{EventID: weekly.ID, UserID: users[0].ID, Occurrence: "2030-01-07T18:00:00Z"}
+	assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &first))
+
+	// Capacity is counted per occurrence
+	other := EventRegister{EventID: weekly.ID, UserID: users[1].ID, Occurrence: "2030-01-14T18:00:00Z"}
+	assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &other))
+
+	full := EventRegister{EventID: weekly.ID, UserID: users[1].ID, Occurrence: "2030-01-07T18:00:00Z"}
+	assert.ErrorIs(t, testRepositories().Registrations.Register(context.Background(), &full), ErrEventFull)
+
+	for _, occurrence := range []string{"", "2030-01-08T18:00:00Z", "2030-02-04T18:00:00Z", "2030-01-14T18:00:00+00:00"} {
+		invalid := EventRegister{EventID: weekly.ID, UserID: users[1].ID, Occurrence: occurrence}
+		assert.ErrorIs(t, testRepositories().Registrations.Register(context.Background(), &invalid), ErrInvalidOccurrence, occurrence)
+	}
+
+	skip := EventException{EventID: weekly.ID, Occurrence: "2030-01-21T18:00:00Z", Canceled: true}
+	assert.NoError(t, testRepositories().Events.SaveException(context.Background(), &skip))
+
+	canceled := EventRegister{EventID: weekly.ID, UserID: users[1].ID, Occurrence: "2030-01-21T18:00:00Z"}
+	assert.ErrorIs(t, testRepositories().Registrations.Register(context.Background(), &canceled), ErrOccurrenceCanceled)
+
+	registration, err := testRepositories().Registrations.Get(context.Background(), weekly.ID, users[1].ID, "2030-01-14T18:00:00Z")
+	assert.NoError(t, err)
+	assert.Equal(t, "2030-01-14T18:00:00Z", registration.Occurrence)
+
+	cancel := EventRegister{EventID: weekly.ID, UserID: users[1].ID, Occurrence: "2030-01-14T18:00:00Z"}
+	assert.NoError(t, testRepositories().Registrations.Cancel(context.Background(), &cancel))
+	assert.ErrorIs(t, testRepositories().Registrations.Cancel(context.Background(), &cancel), ErrNotRegistered)
+
+	// A one-off event does not take an occurrence
+	oneOff := createTestEvent(t, users[0].ID, nil, false)
+	keyed := EventRegister{EventID: oneOff.ID, UserID: users[1].ID, Occurrence: "2030-01-07T18:00:00Z"}
+	assert.ErrorIs(t, testRepositories().Registrations.Register(context.Background(), &keyed), ErrInvalidOccurrence)
+}
+
+func TestEvent_NormalizeRRule(t *testing.T) {
+	event := Event{RRule: "RRULE:freq=weekly;byday=mo,th"}
+	assert.NoError(t, event.NormalizeRRule())
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO,TH", event.RRule)
+
+	event = Event{RRule: "FREQ=HOURLY"}
+	assert.Error(t, event.NormalizeRRule())
+
+	event = Event{}
+	assert.NoError(t, event.NormalizeRRule())
+	assert.Empty(t, event.RRule)
+}
+
+func TestEvent_TimezoneKeepsLocalTimeAcrossDST(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	users := createTestUsers(t, 1)
+
+	// Clocks go forward on March 10, 2030
+	event := Event{Name: "Standup", Description: "Weekly", Location: "Office",
+		DateTime: time.Date(2030, 3, 4, 9, 0, 0, 0, newYork), UserID: users[0].ID,
+		RRule: "FREQ=WEEKLY;COUNT=2", Timezone: "America/New_York"}
+	assert.NoError(t, event.NormalizeTimezone())
+	assert.NoError(t, testRepositories().Events.Save(context.Background(), &event))
+
+	stored, err := testRepositories().Events.GetByID(context.Background(), event.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "America/New_York", stored.Timezone)
+	assert.Equal(t, "2030-03-04T09:00:00-05:00", stored.DateTime.Format(time.RFC3339))
+
+	occurrences, err := testRepositories().Events.Occurrences(context.Background(), stored, stored.DateTime, stored.DateTime.AddDate(0, 1, 0))
+	assert.NoError(t, err)
+	assert.Len(t, occurrences, 2)
+	assert.Equal(t, "2030-03-11T09:00:00-04:00", occurrences[1].DateTime.Format(time.RFC3339))
+	assert.True(t, stored.ValidOccurrence(occurrences[1].Occurrence))
+
+	invalid := Event{Timezone: "Mars/Olympus"}
+	assert.ErrorIs(t, invalid.NormalizeTimezone(), ErrInvalidTimezone)
+}
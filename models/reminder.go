@@ -0,0 +1,573 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MinReminderOffset  = 5 * time.Minute
+	MaxReminderOffset  = 30 * 24 * time.Hour
+	MaxReminderOffsets = 5
+)
+
+// Where the schedule that applies to a user and event was chosen, most
+// specific first
+const (
+	ReminderSourceUserEvent = "user_event"
+	ReminderSourceUser      = "user"
+	ReminderSourceEvent     = "event"
+	ReminderSourceDefault   = "default"
+)
+
+var (
+	ErrInvalidReminderOffsets   = errors.New("offsets must be whole minutes between 5m and 720h, at most 5 of them")
+	ErrReminderScheduleNotFound = errors.New("no reminder schedule set")
+	ErrReminderAlreadyDelivered = errors.New("reminder already delivered")
+)
+
+// DefaultReminderSchedule applies when neither the user nor the organizer
+// chose one: a single reminder a day before
+var DefaultReminderSchedule = ReminderSchedule{24 * time.Hour}
+
+// ReminderSchedule is how long before an occurrence starts its reminders are
+// sent, longest first. An empty schedule sends none.
+type ReminderSchedule []time.Duration
+
+// ParseReminderSchedule reads offsets written as durations like "168h" or
+// "30m", dropping duplicates
+func ParseReminderSchedule(values []string) (ReminderSchedule, error) {
+	seen := map[time.Duration]bool{}
+	schedule := ReminderSchedule{}
+
+	for _, value := range values {
+		offset, err := time.ParseDuration(strings.TrimSpace(value))
+
+		if err != nil || offset < MinReminderOffset || offset > MaxReminderOffset || offset%time.Minute != 0 {
+			return nil, ErrInvalidReminderOffsets
+		}
+
+		if !seen[offset] {
+			seen[offset] = true
+			schedule = append(schedule, offset)
+		}
+	}
+
+	if len(schedule) > MaxReminderOffsets {
+		return nil, ErrInvalidReminderOffsets
+	}
+
+	sort.Slice(schedule, func(i, j int) bool { return schedule[i] > schedule[j] })
+
+	return schedule, nil
+}
+
+// Strings formats the offsets the way ParseReminderSchedule reads them
+func (s ReminderSchedule) Strings() []string {
+	values := make([]string, len(s))
+
+	for i, offset := range s {
+		values[i] = formatReminderOffset(offset)
+	}
+
+	return values
+}
+
+func (s ReminderSchedule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Strings())
+}
+
+// formatReminderOffset drops the zero units time.Duration prints, e.g.
+// "24h0m0s" becomes "24h"
+func formatReminderOffset(offset time.Duration) string {
+	value := strings.TrimSuffix(offset.String(), "0s")
+
+	if strings.HasSuffix(value, "h0m") {
+		value = strings.TrimSuffix(value, "0m")
+	}
+
+	return value
+}
+
+// encode stores the schedule as comma separated minutes
+func (s ReminderSchedule) encode() string {
+	minutes := make([]string, len(s))
+
+	for i, offset := range s {
+		minutes[i] = strconv.FormatInt(int64(offset/time.Minute), 10)
+	}
+
+	return strings.Join(minutes, ",")
+}
+
+func decodeReminderSchedule(value string) (ReminderSchedule, error) {
+	schedule := ReminderSchedule{}
+
+	if value == "" {
+		return schedule, nil
+	}
+
+	for _, minutes := range strings.Split(value, ",") {
+		n, err := strconv.ParseInt(minutes, 10, 64)
+
+		if err != nil {
+			return nil, err
+		}
+
+		schedule = append(schedule, time.Duration(n)*time.Minute)
+	}
+
+	return schedule, nil
+}
+
+// ReminderSettings is the schedule that applies and where it was chosen
+type ReminderSettings struct {
+	Offsets ReminderSchedule `json:"offsets"`
+	Source  string           `json:"source"` // one of the ReminderSource constants
+}
+
+// reminderSchedules holds stored schedules by owner for resolving them
+type reminderSchedules struct {
+	events     map[int64]ReminderSchedule
+	users      map[int64]ReminderSchedule
+	userEvents map[[2]int64]ReminderSchedule
+}
+
+// resolve picks the user's override for the event, then the user's default,
+// then the organizer's schedule for the event. A zero id skips its levels.
+func (s *reminderSchedules) resolve(userID, eventID int64) ReminderSettings {
+	if schedule, ok := s.userEvents[[2]int64{userID, eventID}]; ok && userID != 0 && eventID != 0 {
+		return ReminderSettings{Offsets: schedule, Source: ReminderSourceUserEvent}
+	}
+
+	if schedule, ok := s.users[userID]; ok && userID != 0 {
+		return ReminderSettings{Offsets: schedule, Source: ReminderSourceUser}
+	}
+
+	if schedule, ok := s.events[eventID]; ok && eventID != 0 {
+		return ReminderSettings{Offsets: schedule, Source: ReminderSourceEvent}
+	}
+
+	return ReminderSettings{Offsets: DefaultReminderSchedule, Source: ReminderSourceDefault}
+}
+
+func (r sqlReminders) loadSchedules(ctx context.Context, condition string, args ...any) (*reminderSchedules, error) {
+	schedules := &reminderSchedules{
+		events:     map[int64]ReminderSchedule{},
+		users:      map[int64]ReminderSchedule{},
+		userEvents: map[[2]int64]ReminderSchedule{},
+	}
+
+	rows, err := r.conn.QueryContext(ctx, `SELECT user_id, event_id, offsets FROM reminder_schedules WHERE `+condition, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, eventID sql.NullInt64
+		var offsets string
+
+		if err := rows.Scan(&userID, &eventID, &offsets); err != nil {
+			return nil, err
+		}
+
+		schedule, err := decodeReminderSchedule(offsets)
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case userID.Valid && eventID.Valid:
+			schedules.userEvents[[2]int64{userID.Int64, eventID.Int64}] = schedule
+		case userID.Valid:
+			schedules.users[userID.Int64] = schedule
+		case eventID.Valid:
+			schedules.events[eventID.Int64] = schedule
+		}
+	}
+
+	return schedules, rows.Err()
+}
+
+// Settings returns the schedule that applies to the user for the
+// event. Pass eventID 0 for the user's default, or userID 0 for the schedule
+// the organizer chose for the event.
+func (r sqlReminders) Settings(ctx context.Context, userID, eventID int64) (*ReminderSettings, error) {
+	var conditions []string
+	var args []any
+
+	if eventID != 0 {
+		conditions = append(conditions, "(user_id IS NULL AND event_id = ?)")
+		args = append(args, eventID)
+	}
+
+	if userID != 0 {
+		conditions = append(conditions, "(user_id = ? AND event_id IS NULL)")
+		args = append(args, userID)
+	}
+
+	if userID != 0 && eventID != 0 {
+		conditions = append(conditions, "(user_id = ? AND event_id = ?)")
+		args = append(args, userID, eventID)
+	}
+
+	if len(conditions) == 0 {
+		return &ReminderSettings{Offsets: DefaultReminderSchedule, Source: ReminderSourceDefault}, nil
+	}
+
+	schedules, err := r.loadSchedules(ctx, strings.Join(conditions, " OR "), args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	settings := schedules.resolve(userID, eventID)
+
+	return &settings, nil
+}
+
+// scheduleOwner matches the reminder_schedules row of a user and event, where
+// a zero id is stored as NULL
+func scheduleOwner(userID, eventID int64) (string, []any) {
+	var conditions []string
+	var args []any
+
+	for _, owner := range []struct {
+		column string
+		id     int64
+	}{{"user_id", userID}, {"event_id", eventID}} {
+		if owner.id == 0 {
+			conditions = append(conditions, owner.column+" IS NULL")
+			continue
+		}
+
+		conditions = append(conditions, owner.column+" = ?")
+		args = append(args, owner.id)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+func nullableID(id int64) any {
+	if id == 0 {
+		return nil
+	}
+
+	return id
+}
+
+// SetSchedule replaces the schedule of the user, the event or the
+// user for the event, with zero ids as in Settings
+func (r sqlReminders) SetSchedule(ctx context.Context, userID, eventID int64, schedule ReminderSchedule) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	condition, args := scheduleOwner(userID, eventID)
+	_, err = tx.ExecContext(ctx, `DELETE FROM reminder_schedules WHERE `+condition, args...)
+
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO reminder_schedules (user_id, event_id, offsets) VALUES (?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, nullableID(userID), nullableID(eventID), schedule.encode())
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteSchedule removes a schedule set with SetSchedule so the next less
+// specific one applies again
+func (r sqlReminders) DeleteSchedule(ctx context.Context, userID, eventID int64) error {
+	condition, args := scheduleOwner(userID, eventID)
+	result, err := r.conn.ExecContext(ctx, `DELETE FROM reminder_schedules WHERE `+condition, args...)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrReminderScheduleNotFound
+	}
+
+	return nil
+}
+
+// DueReminder is a reminder to send now to a registered user for one
+// occurrence. Missed are longer offsets that came due at the same time, e.g.
+// because the user registered late; they are recorded without a notification
+// so the user only hears the most recent one.
+type DueReminder struct {
+	UserID     int64
+	EventID    int64
+	EventName  string
+	Occurrence string
+	Start      time.Time // in the user's timezone
+	Offset     time.Duration
+	Missed     []time.Duration
+}
+
+// reminderKey identifies one reminder in reminder_deliveries
+type reminderKey struct {
+	userID     int64
+	eventID    int64
+	occurrence string
+	minutes    int64
+}
+
+// Due finds the reminders whose time has come for occurrences
+// starting after now and not yet handled
+func (r sqlReminders) Due(ctx context.Context, now time.Time) ([]DueReminder, error) {
+	now = now.UTC()
+
+	// Keys sort by time, so this also catches occurrences moved up to the
+	// longest offset later than scheduled
+	oldestKey := OccurrenceKey(now.Add(-MaxReminderOffset))
+	dateTime := r.dialect.Timestamp("e.dateTime")
+	param := r.dialect.Timestamp("?")
+
+	query := `
+		SELECT ` + qualifiedEventColumns("e") + `, er.user_id, er.occurrence, u.timezone
+		FROM events_registry er
+		INNER JOIN events e ON e.id = er.event_id
+		INNER JOIN users u ON u.id = er.user_id
+		WHERE er.status = ? AND (
+			(e.rrule = '' AND ` + dateTime + ` > ` + param + ` AND ` + dateTime + ` <= ` + param + `)
+			OR (e.rrule <> '' AND er.occurrence >= ?)
+		)
+	`
+	rows, err := r.conn.QueryContext(ctx, query, RegistrationStatusRegistered, now, now.Add(MaxReminderOffset), oldestKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []DueReminder
+	var zones []*time.Location
+	var recurring []int64
+	userIDs := map[int64]bool{}
+	eventIDs := map[int64]bool{}
+
+	for rows.Next() {
+		var reminder DueReminder
+		var timezone string
+
+		event, err := scanEvent(rows, &reminder.UserID, &reminder.Occurrence, &timezone)
+
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		reminder.EventID = event.ID
+		reminder.EventName = event.Name
+		reminder.Start = event.DateTime
+
+		if event.RRule != "" && !eventIDs[event.ID] {
+			recurring = append(recurring, event.ID)
+		}
+
+		userIDs[reminder.UserID] = true
+		eventIDs[event.ID] = true
+		candidates = append(candidates, reminder)
+		zones = append(zones, loadZone(timezone))
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+
+	exceptions, err := r.getEventExceptions(ctx, recurring)
+
+	if err != nil {
+		return nil, err
+	}
+
+	schedules, err := r.loadSchedules(ctx, `event_id IN (`+placeholders(len(eventIDs))+`) OR user_id IN (`+placeholders(len(userIDs))+`)`,
+		append(idArgs(eventIDs), idArgs(userIDs)...)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	delivered, err := r.deliveries(ctx, eventIDs, oldestKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var due []DueReminder
+
+	for i, reminder := range candidates {
+		if reminder.Occurrence != "" {
+			start, err := parseOccurrenceKey(reminder.Occurrence)
+
+			if err != nil {
+				continue
+			}
+
+			exception, changed := exceptions[reminder.EventID][reminder.Occurrence]
+
+			if changed && (exception.Canceled || exception.NewStart == nil) {
+				continue
+			}
+
+			if changed {
+				start = *exception.NewStart
+			}
+
+			reminder.Start = start
+		}
+
+		if !reminder.Start.After(now) {
+			continue
+		}
+
+		var offsets []time.Duration
+
+		for _, offset := range schedules.resolve(reminder.UserID, reminder.EventID).Offsets {
+			key := reminderKey{reminder.UserID, reminder.EventID, reminder.Occurrence, int64(offset / time.Minute)}
+
+			if !now.Before(reminder.Start.Add(-offset)) && !delivered[key] {
+				offsets = append(offsets, offset)
+			}
+		}
+
+		if len(offsets) == 0 {
+			continue
+		}
+
+		// Offsets are longest first, so the last one is closest to the start
+		reminder.Start = reminder.Start.In(zones[i])
+		reminder.Offset = offsets[len(offsets)-1]
+		reminder.Missed = offsets[:len(offsets)-1]
+		due = append(due, reminder)
+	}
+
+	return due, nil
+}
+
+func (r sqlReminders) deliveries(ctx context.Context, eventIDs map[int64]bool, oldestKey string) (map[reminderKey]bool, error) {
+	query := `
+		SELECT user_id, event_id, occurrence, offset_minutes FROM reminder_deliveries
+		WHERE event_id IN (` + placeholders(len(eventIDs)) + `) AND (occurrence = '' OR occurrence >= ?)
+	`
+	rows, err := r.conn.QueryContext(ctx, query, append(idArgs(eventIDs), oldestKey)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	delivered := map[reminderKey]bool{}
+
+	for rows.Next() {
+		var key reminderKey
+
+		if err := rows.Scan(&key.userID, &key.eventID, &key.occurrence, &key.minutes); err != nil {
+			return nil, err
+		}
+
+		delivered[key] = true
+	}
+
+	return delivered, rows.Err()
+}
+
+// Deliver stores the reminder as a notification with the given message and
+// records its offsets as handled. Each reminder is delivered at most once;
+// if another run got there first it returns ErrReminderAlreadyDelivered.
+func (r sqlReminders) Deliver(ctx context.Context, reminder *DueReminder, message LocalizedMessage, now time.Time) (*Notification, error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	notification := Notification{
+		UserID:    reminder.UserID,
+		EventID:   reminder.EventID,
+		Type:      NotificationTypeUpcomingEvent,
+		CreatedAt: now,
+		Localized: &message,
+	}
+
+	if err := notification.saveWith(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO reminder_deliveries (user_id, event_id, occurrence, offset_minutes, notification_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	offsets := append([]time.Duration{reminder.Offset}, reminder.Missed...)
+
+	for i, offset := range offsets {
+		var notificationID any
+
+		if i == 0 {
+			notificationID = nullableID(notification.ID)
+		}
+
+		_, err := tx.ExecContext(ctx, query, reminder.UserID, reminder.EventID, reminder.Occurrence, int64(offset/time.Minute), notificationID, now)
+
+		if r.dialect.IsUniqueViolation(err) {
+			return nil, ErrReminderAlreadyDelivered
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	notificationCreated(notification)
+
+	return &notification, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func idArgs(ids map[int64]bool) []any {
+	args := make([]any, 0, len(ids))
+
+	for id := range ids {
+		args = append(args, id)
+	}
+
+	return args
+}
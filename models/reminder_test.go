@@ -0,0 +1,204 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReminderSchedule(t *testing.T) {
+	schedule, err := ParseReminderSchedule([]string{"1h", "168h", "24h", "1h"})
+	assert.NoError(t, err)
+	assert.Equal(t, ReminderSchedule{168 * time.Hour, 24 * time.Hour, time.Hour}, schedule)
+	assert.Equal(t, []string{"168h", "24h", "1h"}, schedule.Strings())
+
+	schedule, err = ParseReminderSchedule([]string{"90m"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1h30m"}, schedule.Strings())
+
+	schedule, err = ParseReminderSchedule(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, schedule)
+
+	for _, values := range [][]string{
+		{"soon"},
+		{"1m"},
+		{"721h"},
+		{"90s"},
+		{"1h", "2h", "3h", "4h", "5h", "6h"},
+	} {
+		_, err := ParseReminderSchedule(values)
+		assert.ErrorIs(t, err, ErrInvalidReminderOffsets, "%v", values)
+	}
+}
+
+func TestGetReminderSettings_Precedence(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	event := createTestEvent(t, users[0].ID, nil, false)
+	userID := users[0].ID
+
+	settings, err := testRepositories().Reminders.Settings(context.Background(), userID, event.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ReminderSourceDefault, settings.Source)
+	assert.Equal(t, DefaultReminderSchedule, settings.Offsets)
+
+	steps := []struct {
+		userID, eventID int64
+		schedule        ReminderSchedule
+		source          string
+	}{
+		{0, event.ID, ReminderSchedule{168 * time.Hour}, ReminderSourceEvent},
+		{userID, 0, ReminderSchedule{2 * time.Hour}, ReminderSourceUser},
+		{userID, event.ID, ReminderSchedule{}, ReminderSourceUserEvent},
+	}
+
+	for _, step := range steps {
+		assert.NoError(t, testRepositories().Reminders.SetSchedule(context.Background(), step.userID, step.eventID, step.schedule))
+
+		settings, err := testRepositories().Reminders.Settings(context.Background(), userID, event.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, step.source, settings.Source)
+		assert.Equal(t, step.schedule, settings.Offsets)
+	}
+
+	// Each level still reads on its own
+	settings, err = testRepositories().Reminders.Settings(context.Background(), 0, event.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ReminderSourceEvent, settings.Source)
+
+	settings, err = testRepositories().Reminders.Settings(context.Background(), userID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, ReminderSchedule{2 * time.Hour}, settings.Offsets)
+
+	// Setting again replaces
+	assert.NoError(t, testRepositories().Reminders.SetSchedule(context.Background(), userID, 0, ReminderSchedule{3 * time.Hour}))
+	settings, err = testRepositories().Reminders.Settings(context.Background(), userID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, ReminderSchedule{3 * time.Hour}, settings.Offsets)
+
+	assert.NoError(t, testRepositories().Reminders.DeleteSchedule(context.Background(), userID, event.ID))
+	assert.ErrorIs(t, testRepositories().Reminders.DeleteSchedule(context.Background(), userID, event.ID), ErrReminderScheduleNotFound)
+
+	settings, err = testRepositories().Reminders.Settings(context.Background(), userID, event.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ReminderSourceUser, settings.Source)
+}
+
+func TestGetDueReminders_SendsEachOffsetOnce(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 2)
+	event := createTestEvent(t, users[0].ID, nil, false) // starts in 48 hours
+	assert.NoError(t, testRepositories().Reminders.SetSchedule(context.Background(), 0, event.ID, ReminderSchedule{168 * time.Hour, 72 * time.Hour, time.Hour}))
+
+	for _, user := range users {
+		registration := EventRegister{EventID: event.ID, UserID: user.ID}
+		assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registration))
+	}
+
+	// The second user wants no reminders for this event
+	assert.NoError(t, testRepositories().Reminders.SetSchedule(context.Background(), users[1].ID, event.ID, ReminderSchedule{}))
+
+	now := time.Now()
+
+	due, err := testRepositories().Reminders.Due(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+	assert.Equal(t, users[0].ID, due[0].UserID)
+	assert.Equal(t, 72*time.Hour, due[0].Offset)
+	assert.Equal(t, []time.Duration{168 * time.Hour}, due[0].Missed)
+
+	notification, err := testRepositories().Reminders.Deliver(context.Background(), &due[0], LocalizedMessage{Key: "upcoming_event.soon"}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, NotificationTypeUpcomingEvent, notification.Type)
+
+	// A second run racing the first does not notify again
+	_, err = testRepositories().Reminders.Deliver(context.Background(), &due[0], LocalizedMessage{Key: "upcoming_event.soon"}, now)
+	assert.ErrorIs(t, err, ErrReminderAlreadyDelivered)
+
+	due, err = testRepositories().Reminders.Due(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Empty(t, due)
+
+	// The hour-before reminder comes due later
+	due, err = testRepositories().Reminders.Due(context.Background(), event.DateTime.Add(-30*time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+	assert.Equal(t, time.Hour, due[0].Offset)
+	assert.Empty(t, due[0].Missed)
+
+	notifications, err := testRepositories().Notifications.GetByUserID(context.Background(), users[0].ID)
+	assert.NoError(t, err)
+	assert.Len(t, notifications, 1)
+}
+
+func TestGetDueReminders_RecurringOccurrences(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	weekly := createWeeklyEvent(t, users[0].ID, nil)
+	first := weekly.DateTime
+	second := first.Add(7 * 24 * time.Hour)
+
+	for _, start := range []time.Time{first, second} {
+		registration := EventRegister{EventID: weekly.ID, UserID: users[0].ID, Occurrence: OccurrenceKey(start)}
+		assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registration))
+	}
+
+	// The second occurrence moves a day later
+	moved := second.Add(24 * time.Hour)
+	exception := EventException{EventID: weekly.ID, Occurrence: OccurrenceKey(second), NewStart: &moved}
+	assert.NoError(t, testRepositories().Events.SaveException(context.Background(), &exception))
+
+	due, err := testRepositories().Reminders.Due(context.Background(), first.Add(-2*time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+	assert.Equal(t, OccurrenceKey(first), due[0].Occurrence)
+	assert.True(t, first.Equal(due[0].Start))
+
+	// Reminders follow the occurrence to its new date
+	due, err = testRepositories().Reminders.Due(context.Background(), second.Add(-2*time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, due)
+
+	due, err = testRepositories().Reminders.Due(context.Background(), moved.Add(-2*time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+	assert.True(t, moved.Equal(due[0].Start))
+}
+
+func TestGetDueReminders_UserTimezone(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	event := createTestEvent(t, users[0].ID, nil, false)
+
+	registration := EventRegister{EventID: event.ID, UserID: users[0].ID}
+	assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registration))
+
+	assert.ErrorIs(t, testRepositories().Users.UpdateSettings(context.Background(), users[0].ID, UserSettings{Timezone: "Nowhere/Else", Locale: "en"}), ErrInvalidTimezone)
+	assert.NoError(t, testRepositories().Users.UpdateSettings(context.Background(), users[0].ID, UserSettings{Timezone: "Asia/Dhaka", Locale: "en"}))
+
+	settings, err := testRepositories().Users.GetSettings(context.Background(), users[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Asia/Dhaka", settings.Timezone)
+
+	due, err := testRepositories().Reminders.Due(context.Background(), event.DateTime.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+	assert.Equal(t, "Asia/Dhaka", due[0].Start.Location().String())
+	assert.True(t, event.DateTime.Equal(due[0].Start))
+}
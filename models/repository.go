@@ -0,0 +1,186 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/ical"
+	"example.com/rest-api/utils"
+)
+
+// EventRepository stores events, their occurrences and who organizes them
+type EventRepository interface {
+	Save(ctx context.Context, e *Event) error
+	Update(ctx context.Context, e *Event) error
+	Delete(ctx context.Context, e *Event) error
+	GetByID(ctx context.Context, id int64) (*Event, error)
+	List(ctx context.Context, q EventQuery) (*EventPage, error)
+	Search(ctx context.Context, text string, filters EventQuery, offset int) (*EventSearchPage, error)
+	ListOccurrences(ctx context.Context, q EventQuery) (*OccurrencePage, error)
+	Occurrences(ctx context.Context, e *Event, from, to time.Time) ([]Occurrence, error)
+	SaveException(ctx context.Context, ex *EventException) error
+	DeleteException(ctx context.Context, eventID int64, occurrence string) error
+	Access(ctx context.Context, e *Event, userID int64, role Role) (EventAccess, error)
+	Organizers(ctx context.Context, eventID int64) ([]EventOrganizer, error)
+	AddOrganizer(ctx context.Context, e *Event, userID int64) error
+	RemoveOrganizer(ctx context.Context, e *Event, userID int64) error
+	TransferOwnership(ctx context.Context, e *Event, newOwnerID int64) error
+	Calendar(ctx context.Context, e *Event) (*ical.Calendar, error)
+	ChangesAfter(ctx context.Context, afterID int64, limit int) ([]EventChange, error)
+	LastChangeID(ctx context.Context) (int64, error)
+	PruneChanges(ctx context.Context, before time.Time) (int64, error)
+}
+
+// UserRepository stores users, their credentials and their settings
+type UserRepository interface {
+	Save(ctx context.Context, u *User) error
+	// ValidateCredentials fills in the user with the email if the password matches
+	ValidateCredentials(ctx context.Context, u *User) error
+	GetByID(ctx context.Context, id int64) (*User, error)
+	GetSettings(ctx context.Context, userID int64) (*UserSettings, error)
+	UpdateSettings(ctx context.Context, userID int64, settings UserSettings) error
+	ChangePassword(ctx context.Context, u *User, current, next string) error
+	SetRole(ctx context.Context, userID int64, role Role) error
+	ListEvents(ctx context.Context, q UserListQuery) (*OccurrencePage, error)
+	ListRegistrations(ctx context.Context, q UserListQuery) (*RegistrationPage, error)
+	Calendar(ctx context.Context, u *User) (*ical.Calendar, error)
+}
+
+// TokenRepository issues, rotates and revokes the tokens users sign in with
+type TokenRepository interface {
+	Issue(ctx context.Context, user *User) (*TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (*TokenPair, error)
+	Logout(ctx context.Context, claims *utils.Claims, refreshToken string) error
+	CheckAccess(ctx context.Context, claims *utils.Claims) error
+	NewCalendarToken(ctx context.Context, userID int64) (string, error)
+	// UserByCalendarToken returns sql.ErrNoRows if no user has the token
+	UserByCalendarToken(ctx context.Context, token string) (*User, error)
+}
+
+// RegistrationRepository stores users' places at events
+type RegistrationRepository interface {
+	Register(ctx context.Context, r *EventRegister) error
+	Cancel(ctx context.Context, r *EventRegister) error
+	// Get returns sql.ErrNoRows if the user is not registered
+	Get(ctx context.Context, eventID, userID int64, occurrence string) (*EventRegister, error)
+}
+
+// AttendeeRepository lists who registered for an event
+type AttendeeRepository interface {
+	List(ctx context.Context, q AttendeeQuery) (*AttendeePage, error)
+	All(ctx context.Context, q AttendeeQuery) ([]Attendee, error)
+}
+
+// NotificationRepository stores users' notifications and how they want them
+type NotificationRepository interface {
+	Save(ctx context.Context, n *Notification) error
+	GetByUserID(ctx context.Context, userID int64) ([]Notification, error)
+	GetAfter(ctx context.Context, userID, afterID int64, limit int) ([]Notification, error)
+	// LastID is the id of the user's latest notification in the app, 0 if none
+	LastID(ctx context.Context, userID int64) (int64, error)
+	MarkAsRead(ctx context.Context, notificationID int64) error
+	Preferences(ctx context.Context, userID int64) (*NotificationPreferences, error)
+	SavePreferences(ctx context.Context, p *NotificationPreferences) error
+}
+
+// DeliveryRepository tracks sending notifications by email
+type DeliveryRepository interface {
+	Pending(ctx context.Context, channel string, limit int) ([]PendingDelivery, error)
+	Claim(ctx context.Context, d *Delivery) (bool, error)
+	MarkSent(ctx context.Context, d *Delivery) error
+	MarkFailed(ctx context.Context, d *Delivery, sendErr error, maxAttempts int) error
+	Defer(ctx context.Context, d *Delivery, until time.Time) error
+	ForNotification(ctx context.Context, notificationID int64) ([]Delivery, error)
+}
+
+// ReminderRepository stores reminder schedules and which reminders were sent
+type ReminderRepository interface {
+	Settings(ctx context.Context, userID, eventID int64) (*ReminderSettings, error)
+	SetSchedule(ctx context.Context, userID, eventID int64, schedule ReminderSchedule) error
+	DeleteSchedule(ctx context.Context, userID, eventID int64) error
+	Due(ctx context.Context, now time.Time) ([]DueReminder, error)
+	Deliver(ctx context.Context, reminder *DueReminder, message LocalizedMessage, now time.Time) (*Notification, error)
+}
+
+// JobRepository is the background job queue and its schedules
+type JobRepository interface {
+	Enqueue(ctx context.Context, jobType string, payload any, runAt time.Time) (*Job, error)
+	Claim(ctx context.Context, workerID string, types []string, limit int, lease time.Duration, now time.Time) ([]Job, error)
+	Complete(ctx context.Context, j *Job) error
+	Fail(ctx context.Context, j *Job, runErr error, maxAttempts int) error
+	Release(ctx context.Context, j *Job) error
+	List(ctx context.Context, status string, limit int) ([]Job, error)
+	Retry(ctx context.Context, id int64) error
+	Prune(ctx context.Context, before time.Time) (int64, error)
+	SaveSchedule(ctx context.Context, name, jobType, spec string, next time.Time) error
+	DueSchedules(ctx context.Context, now time.Time) ([]JobSchedule, error)
+	AdvanceSchedule(ctx context.Context, s *JobSchedule, next time.Time) (*Job, error)
+}
+
+// WebhookRepository stores users' webhooks and their outbox
+type WebhookRepository interface {
+	Save(ctx context.Context, w *Webhook) error
+	List(ctx context.Context, userID int64) ([]Webhook, error)
+	Get(ctx context.Context, id, userID int64) (*Webhook, error)
+	Delete(ctx context.Context, id, userID int64) error
+	Queue(ctx context.Context, userIDs []int64, eventType string, data any) error
+	Pending(ctx context.Context, limit int) ([]PendingWebhookDelivery, error)
+	Claim(ctx context.Context, d *WebhookDelivery) (bool, error)
+	MarkDelivered(ctx context.Context, d *WebhookDelivery, responseCode int) error
+	MarkFailed(ctx context.Context, d *WebhookDelivery, responseCode int, sendErr error, maxAttempts int) error
+	Deliveries(ctx context.Context, webhookID int64, limit int) ([]WebhookDelivery, error)
+}
+
+// Repositories are the stores of the aggregates the API is built on
+type Repositories struct {
+	Events        EventRepository
+	Users         UserRepository
+	Tokens        TokenRepository
+	Registrations RegistrationRepository
+	Attendees     AttendeeRepository
+	Notifications NotificationRepository
+	Deliveries    DeliveryRepository
+	Reminders     ReminderRepository
+	Jobs          JobRepository
+	Webhooks      WebhookRepository
+}
+
+// NewSQLRepositories returns repositories that store everything through conn,
+// in the SQL of the dialect it was opened with
+func NewSQLRepositories(conn *sql.DB, dialect db.SQLDialect) Repositories {
+	store := sqlStore{conn: conn, dialect: dialect}
+
+	return Repositories{
+		Events:        sqlEvents{store},
+		Users:         sqlUsers{store},
+		Tokens:        sqlTokens{store},
+		Registrations: sqlRegistrations{store},
+		Attendees:     sqlAttendees{store},
+		Notifications: sqlNotifications{store},
+		Deliveries:    sqlDeliveries{store},
+		Reminders:     sqlReminders{store},
+		Jobs:          sqlJobs{store},
+		Webhooks:      sqlWebhooks{store},
+	}
+}
+
+// sqlStore is the connection and dialect shared by the SQL repositories
+type sqlStore struct {
+	conn    *sql.DB
+	dialect db.SQLDialect
+}
+
+type (
+	sqlEvents        struct{ sqlStore }
+	sqlUsers         struct{ sqlStore }
+	sqlTokens        struct{ sqlStore }
+	sqlRegistrations struct{ sqlStore }
+	sqlAttendees     struct{ sqlStore }
+	sqlNotifications struct{ sqlStore }
+	sqlDeliveries    struct{ sqlStore }
+	sqlReminders     struct{ sqlStore }
+	sqlJobs          struct{ sqlStore }
+	sqlWebhooks      struct{ sqlStore }
+)
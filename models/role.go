@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Role decides what a user may do beyond managing their own events and
+// registrations
+type Role string
+
+const (
+	RoleAdmin     Role = "admin"
+	RoleOrganizer Role = "organizer"
+	RoleAttendee  Role = "attendee"
+)
+
+// Permission is an action that is granted per role rather than by ownership
+type Permission string
+
+const (
+	PermissionCreateEvents         Permission = "events:create"
+	PermissionManageAnyEvent       Permission = "events:manage_any"
+	PermissionTriggerNotifications Permission = "notifications:trigger"
+	PermissionManageUsers          Permission = "users:manage"
+	PermissionManageJobs           Permission = "jobs:manage"
+)
+
+var ErrInvalidRole = errors.New("role must be admin, organizer or attendee")
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermissionCreateEvents,
+		PermissionManageAnyEvent,
+		PermissionTriggerNotifications,
+		PermissionManageUsers,
+		PermissionManageJobs,
+	},
+	RoleOrganizer: {
+		PermissionCreateEvents,
+	},
+	RoleAttendee: {},
+}
+
+// Valid reports whether r is one of the known roles
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role grants permission p
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetRole changes a user's role. Access tokens carry the role, so the
+// user's current ones stop working and the next refresh picks up the new role.
+func (r sqlUsers) SetRole(ctx context.Context, userID int64, role Role) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+
+	query := `UPDATE users SET role = ?, tokens_valid_after = ? WHERE id = ?`
+	_, err := r.conn.ExecContext(ctx, query, role, time.Now().UTC().Truncate(time.Second), userID)
+
+	return err
+}
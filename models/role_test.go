@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/rest-api/test"
+	"example.com/rest-api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRole_Can(t *testing.T) {
+	assert.True(t, RoleAdmin.Can(PermissionManageAnyEvent))
+	assert.True(t, RoleOrganizer.Can(PermissionCreateEvents))
+	assert.False(t, RoleOrganizer.Can(PermissionManageAnyEvent))
+	assert.False(t, RoleAttendee.Can(PermissionCreateEvents))
+	assert.False(t, Role("root").Can(PermissionManageUsers))
+
+	assert.True(t, RoleAttendee.Valid())
+	assert.False(t, Role("").Valid())
+}
+
+func TestSetUserRole(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+
+	user, err := testRepositories().Users.GetByID(context.Background(), users[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, RoleOrganizer, user.Role)
+
+	tokens, err := testRepositories().Tokens.Issue(context.Background(), user)
+	assert.NoError(t, err)
+
+	claims, err := utils.ParseToken(tokens.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "organizer", claims.Role)
+
+	// Pretend the token was issued a while ago so the change invalidates it
+	claims.IssuedAt = claims.IssuedAt.Add(-time.Minute)
+
+	assert.ErrorIs(t, testRepositories().Users.SetRole(context.Background(), user.ID, "root"), ErrInvalidRole)
+	assert.NoError(t, testRepositories().Users.SetRole(context.Background(), user.ID, RoleAdmin))
+
+	assert.ErrorIs(t, testRepositories().Tokens.CheckAccess(context.Background(), claims), ErrTokenRevoked)
+
+	// The refresh token still works and carries the new role
+	refreshed, err := testRepositories().Tokens.Refresh(context.Background(), tokens.RefreshToken)
+	assert.NoError(t, err)
+
+	claims, err = utils.ParseToken(refreshed.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", claims.Role)
+}
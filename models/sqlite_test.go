@@ -0,0 +1,69 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSQLiteBackend runs the models against a real SQLite database to make
+// sure the portable SQL and the SQLite dialect behave like MySQL
+func TestSQLiteBackend(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	user := User{Email: "sqlite@example.com", Password: "hashed"}
+	assert.NoError(t, testRepositories().Users.Save(context.Background(), &user))
+	assert.NotZero(t, user.ID)
+
+	soon := Event{
+		Name:        "Soon",
+		Description: "Starts in a few hours",
+		Location:    "Dhaka",
+		DateTime:    time.Now().Add(3 * time.Hour).UTC().Truncate(time.Second),
+		UserID:      user.ID,
+	}
+	assert.NoError(t, testRepositories().Events.Save(context.Background(), &soon))
+
+	later := Event{
+		Name:        "Later",
+		Description: "Starts next week",
+		Location:    "Chittagong",
+		DateTime:    time.Now().Add(7 * 24 * time.Hour).UTC().Truncate(time.Second),
+		UserID:      user.ID,
+	}
+	assert.NoError(t, testRepositories().Events.Save(context.Background(), &later))
+
+	fetched, err := testRepositories().Events.GetByID(context.Background(), soon.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, soon.Name, fetched.Name)
+	assert.True(t, soon.DateTime.Equal(fetched.DateTime))
+
+	page, err := testRepositories().Events.List(context.Background(), EventQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, page.Events, 2)
+
+	for _, event := range []Event{soon, later} {
+		registration := EventRegister{EventID: event.ID, UserID: user.ID}
+		assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registration))
+	}
+
+	now := time.Now()
+
+	due, err := testRepositories().Reminders.Due(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+	assert.Equal(t, soon.ID, due[0].EventID)
+
+	_, err = testRepositories().Reminders.Deliver(context.Background(), &due[0], LocalizedMessage{Key: "upcoming_event.soon"}, now)
+	assert.NoError(t, err)
+
+	// Already reminded
+	due, err = testRepositories().Reminders.Due(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Empty(t, due)
+}
@@ -0,0 +1,281 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"example.com/rest-api/utils"
+)
+
+var (
+	ErrTokenRevoked        = errors.New("token has been revoked")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrIncorrectPassword   = errors.New("current password is incorrect")
+)
+
+// TokenPair is what a client receives when logging in or refreshing
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Issue creates an access token and a refresh token starting a new
+// rotation family, e.g. on login
+func (r sqlTokens) Issue(ctx context.Context, user *User) (*TokenPair, error) {
+	familyID, err := utils.RandomToken(16)
+
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := insertRefreshToken(ctx, r.conn, user.ID, familyID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := utils.GenerateToken(user.Email, user.ID, string(user.Role))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{Token: token, RefreshToken: refreshToken}, nil
+}
+
+func insertRefreshToken(ctx context.Context, ex execer, userID int64, familyID string) (string, error) {
+	token, expiresAt, err := utils.NewRefreshToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err = ex.ExecContext(ctx, query, userID, utils.HashToken(token), familyID, expiresAt.UTC(), time.Now().UTC())
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Refresh exchanges a refresh token for a new pair. Each refresh token
+// works once: presenting a used one means it was copied, so every token in
+// its family is revoked and the user has to log in again.
+func (r sqlTokens) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	var id, userID int64
+	var familyID, email string
+	var role Role
+	var expiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+
+	query := `
+		SELECT r.id, r.user_id, r.family_id, r.expires_at, r.used_at, r.revoked_at, u.email, u.role
+		FROM refresh_tokens r
+		INNER JOIN users u ON u.id = r.user_id
+		WHERE r.token_hash = ?
+	` + r.dialect.ForUpdate()
+	err = tx.QueryRowContext(ctx, query, utils.HashToken(refreshToken)).Scan(&id, &userID, &familyID, &expiresAt, &usedAt, &revokedAt, &email, &role)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	if revokedAt.Valid || now.After(expiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if usedAt.Valid {
+		query = `UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`
+		_, err = tx.ExecContext(ctx, query, now, familyID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrRefreshTokenReused
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = ? WHERE id = ?`, now, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := insertRefreshToken(ctx, tx, userID, familyID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The role is read again so a changed role takes effect on refresh
+	token, err := utils.GenerateToken(email, userID, string(role))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{Token: token, RefreshToken: next}, nil
+}
+
+// Logout revokes the access token and, when given, the refresh token's
+// whole family
+func (r sqlTokens) Logout(ctx context.Context, claims *utils.Claims, refreshToken string) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	query := `INSERT INTO revoked_tokens (jti, user_id, expires_at) VALUES (?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, claims.ID, claims.UserID, claims.ExpiresAt.UTC())
+
+	if err != nil {
+		return err
+	}
+
+	if refreshToken != "" {
+		var familyID string
+
+		query = `SELECT family_id FROM refresh_tokens WHERE token_hash = ? AND user_id = ?`
+		err = tx.QueryRowContext(ctx, query, utils.HashToken(refreshToken), claims.UserID).Scan(&familyID)
+
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		if err == nil {
+			query = `UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`
+			_, err = tx.ExecContext(ctx, query, now, familyID)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Revoked tokens only need remembering until they would have expired anyway
+	query = `DELETE FROM revoked_tokens WHERE ` + r.dialect.Timestamp("expires_at") + ` < ` + r.dialect.Timestamp("?")
+	_, err = tx.ExecContext(ctx, query, now)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CheckAccess returns ErrTokenRevoked for an access token that was
+// logged out, was issued before the user's last password change, or predates
+// revocation support and so has no jti
+func (r sqlTokens) CheckAccess(ctx context.Context, claims *utils.Claims) error {
+	if claims.ID == "" {
+		return ErrTokenRevoked
+	}
+
+	var revoked int64
+	var validAfter sql.NullTime
+
+	query := `SELECT (SELECT COUNT(*) FROM revoked_tokens WHERE jti = ?), tokens_valid_after FROM users WHERE id = ?`
+	err := r.conn.QueryRowContext(ctx, query, claims.ID, claims.UserID).Scan(&revoked, &validAfter)
+
+	if err == sql.ErrNoRows {
+		return ErrTokenRevoked
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if revoked > 0 || (validAfter.Valid && claims.IssuedAt.Before(validAfter.Time)) {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// ChangePassword replaces the user's password after checking the current
+// one, and invalidates every access and refresh token issued before
+func (r sqlUsers) ChangePassword(ctx context.Context, u *User, current, next string) error {
+	var hashedPassword string
+
+	err := r.conn.QueryRowContext(ctx, `SELECT password FROM users WHERE id = ?`, u.ID).Scan(&hashedPassword)
+
+	if err != nil {
+		return err
+	}
+
+	if !utils.CheckHashPassword(current, hashedPassword) {
+		return ErrIncorrectPassword
+	}
+
+	hashedPassword, err = utils.HashPassword(next)
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	// iat has whole-second precision, so tokens issued from this second on
+	// (such as the ones handed back after the change) stay valid
+	now := time.Now().UTC()
+	validAfter := now.Truncate(time.Second)
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET password = ?, tokens_valid_after = ? WHERE id = ?`, hashedPassword, validAfter, u.ID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`, now, u.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
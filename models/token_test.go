@@ -0,0 +1,117 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/rest-api/test"
+	"example.com/rest-api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshTokens_RotatesAndDetectsReuse(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+
+	first, err := testRepositories().Tokens.Issue(context.Background(), &users[0])
+	assert.NoError(t, err)
+	assert.NotEmpty(t, first.Token)
+
+	second, err := testRepositories().Tokens.Refresh(context.Background(), first.RefreshToken)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	claims, err := utils.ParseToken(second.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, users[0].ID, claims.UserID)
+	assert.Equal(t, users[0].Email, claims.Email)
+
+	// Presenting the rotated token again revokes the whole family, including
+	// the token that replaced it
+	_, err = testRepositories().Tokens.Refresh(context.Background(), first.RefreshToken)
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	_, err = testRepositories().Tokens.Refresh(context.Background(), second.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	_, err = testRepositories().Tokens.Refresh(context.Background(), "unknown")
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestLogout(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+
+	tokens, err := testRepositories().Tokens.Issue(context.Background(), &users[0])
+	assert.NoError(t, err)
+
+	claims, err := utils.ParseToken(tokens.Token)
+	assert.NoError(t, err)
+	assert.NoError(t, testRepositories().Tokens.CheckAccess(context.Background(), claims))
+
+	// Another session of the same user is left alone
+	other, err := testRepositories().Tokens.Issue(context.Background(), &users[0])
+	assert.NoError(t, err)
+
+	assert.NoError(t, testRepositories().Tokens.Logout(context.Background(), claims, tokens.RefreshToken))
+
+	assert.ErrorIs(t, testRepositories().Tokens.CheckAccess(context.Background(), claims), ErrTokenRevoked)
+
+	_, err = testRepositories().Tokens.Refresh(context.Background(), tokens.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	_, err = testRepositories().Tokens.Refresh(context.Background(), other.RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestCheckAccessToken_WithoutID(t *testing.T) {
+	assert.ErrorIs(t, testRepositories().Tokens.CheckAccess(context.Background(), &utils.Claims{UserID: 1}), ErrTokenRevoked)
+}
+
+func TestUser_ChangePassword(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	hashed, err := utils.HashPassword("old-secret")
+	assert.NoError(t, err)
+
+	user := User{Email: "change@example.com", Password: hashed}
+	assert.NoError(t, testRepositories().Users.Save(context.Background(), &user))
+
+	tokens, err := testRepositories().Tokens.Issue(context.Background(), &user)
+	assert.NoError(t, err)
+
+	claims, err := utils.ParseToken(tokens.Token)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, testRepositories().Users.ChangePassword(context.Background(), &user, "wrong", "new-secret"), ErrIncorrectPassword)
+	assert.NoError(t, testRepositories().Tokens.CheckAccess(context.Background(), claims))
+
+	// Pretend the token was issued a while ago so the change invalidates it
+	claims.IssuedAt = claims.IssuedAt.Add(-time.Minute)
+
+	assert.NoError(t, testRepositories().Users.ChangePassword(context.Background(), &user, "old-secret", "new-secret"))
+
+	assert.ErrorIs(t, testRepositories().Tokens.CheckAccess(context.Background(), claims), ErrTokenRevoked)
+
+	_, err = testRepositories().Tokens.Refresh(context.Background(), tokens.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	login := User{Email: user.Email, Password: "new-secret"}
+	assert.NoError(t, testRepositories().Users.ValidateCredentials(context.Background(), &login))
+
+	fresh, err := testRepositories().Tokens.Issue(context.Background(), &user)
+	assert.NoError(t, err)
+
+	freshClaims, err := utils.ParseToken(fresh.Token)
+	assert.NoError(t, err)
+	assert.NoError(t, testRepositories().Tokens.CheckAccess(context.Background(), freshClaims))
+}
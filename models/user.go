@@ -1,9 +1,11 @@
 package models
 
 import (
+	"context"
 	"errors"
+	"time"
 
-	"example.com/rest-api/db"
+	"example.com/rest-api/i18n"
 	"example.com/rest-api/utils"
 )
 
@@ -11,11 +13,12 @@ type User struct {
 	ID       int64
 	Email    string `binding:"required"`
 	Password string `binding:"required"`
+	Role     Role
 }
 
-func (u *User) Save() error {
+func (r sqlUsers) Save(ctx context.Context, u *User) error {
 	query := `INSERT INTO users(email, password) VALUES (?, ?)`
-	stmt, err := db.DB.Prepare(query)
+	stmt, err := r.conn.PrepareContext(ctx, query)
 
 	if err != nil {
 		return err
@@ -23,7 +26,7 @@ func (u *User) Save() error {
 
 	defer stmt.Close()
 
-	result, err := stmt.Exec(u.Email, u.Password)
+	result, err := stmt.ExecContext(ctx, u.Email, u.Password)
 
 	if err != nil {
 		return err
@@ -36,13 +39,13 @@ func (u *User) Save() error {
 	return err
 }
 
-func (u *User) ValidateUser() error {
-	query := "SELECT id, password FROM users WHERE email = ?"
-	row := db.DB.QueryRow(query, u.Email)
+func (r sqlUsers) ValidateCredentials(ctx context.Context, u *User) error {
+	query := "SELECT id, password, role FROM users WHERE email = ?"
+	row := r.conn.QueryRowContext(ctx, query, u.Email)
 
 	var retrievedPassword string
 
-	err := row.Scan(&u.ID, &retrievedPassword)
+	err := row.Scan(&u.ID, &retrievedPassword, &u.Role)
 
 	if err != nil {
 		return err
@@ -58,11 +61,11 @@ func (u *User) ValidateUser() error {
 
 }
 
-func GetUser(userId int64) (*User, error) {
-	query := `SELECT * FROM users WHERE id = ?`
-	row := db.DB.QueryRow(query, userId)
+func (r sqlUsers) GetByID(ctx context.Context, userId int64) (*User, error) {
+	query := `SELECT id, email, password, role FROM users WHERE id = ?`
+	row := r.conn.QueryRowContext(ctx, query, userId)
 	var user User
-	err := row.Scan(&user.ID, &user.Email, &user.Password)
+	err := row.Scan(&user.ID, &user.Email, &user.Password, &user.Role)
 
 	if err != nil {
 		return nil, err
@@ -70,3 +73,43 @@ func GetUser(userId int64) (*User, error) {
 
 	return &user, nil
 }
+
+// UserSettings are a user's display preferences
+type UserSettings struct {
+	Timezone string `json:"timezone"` // IANA zone reminders and quiet hours use
+	Locale   string `json:"locale"`   // language tag notifications are written in
+}
+
+// Location returns the user's timezone, UTC if it cannot be loaded
+func (s *UserSettings) Location() *time.Location {
+	return loadZone(s.Timezone)
+}
+
+func (r sqlUsers) GetSettings(ctx context.Context, userId int64) (*UserSettings, error) {
+	query := `SELECT timezone, locale FROM users WHERE id = ?`
+
+	var settings UserSettings
+	err := r.conn.QueryRowContext(ctx, query, userId).Scan(&settings.Timezone, &settings.Locale)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+// UpdateSettings validates and stores the user's settings
+func (r sqlUsers) UpdateSettings(ctx context.Context, userId int64, settings UserSettings) error {
+	if !validTimezone(settings.Timezone) {
+		return ErrInvalidTimezone
+	}
+
+	if !i18n.Supported(settings.Locale) {
+		return i18n.ErrUnsupportedLocale
+	}
+
+	query := `UPDATE users SET timezone = ?, locale = ? WHERE id = ?`
+	_, err := r.conn.ExecContext(ctx, query, settings.Timezone, settings.Locale, userId)
+
+	return err
+}
@@ -0,0 +1,429 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"example.com/rest-api/db"
+)
+
+const (
+	WhenUpcoming = "upcoming"
+	WhenPast     = "past"
+)
+
+// UserRegistration is an occurrence the user signed up for, with their
+// registration. Canceled is set when the occurrence was skipped.
+type UserRegistration struct {
+	Occurrence
+	RegistrationID int64
+	Status         string
+	RegisteredAt   time.Time
+	Canceled       bool
+}
+
+// UserListQuery pages through a user's own events or registrations. Upcoming
+// ones are listed soonest first, past ones most recent first.
+type UserListQuery struct {
+	UserID int64
+	When   string
+	Status string // registrations only
+	Cursor string
+	Limit  int
+}
+
+// RegistrationPage is one page of a user's registrations
+type RegistrationPage struct {
+	Registrations []UserRegistration `json:"registrations"`
+	NextCursor    string             `json:"next_cursor"`
+	Total         int64              `json:"total"`
+}
+
+// Normalize fills in defaults and rejects unknown filters
+func (q *UserListQuery) Normalize() error {
+	switch q.When {
+	case "":
+		q.When = WhenUpcoming
+	case WhenUpcoming, WhenPast:
+	default:
+		return errors.New("when must be upcoming or past")
+	}
+
+	switch q.Status {
+	case "", RegistrationStatusRegistered, RegistrationStatusWaitlisted:
+	default:
+		return errors.New("status must be registered or waitlisted")
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultEventPageSize
+	}
+
+	if q.Limit > MaxEventPageSize {
+		q.Limit = MaxEventPageSize
+	}
+
+	return nil
+}
+
+// after reports whether an item at (dateTime, id) comes after the cursor
+func (q *UserListQuery) after(cursor *eventCursor, dateTime time.Time, id int64) bool {
+	if cursor == nil {
+		return true
+	}
+
+	return keyBefore(cursor.DateTime, cursor.ID, dateTime, id, q.When == WhenPast)
+}
+
+func (q *UserListQuery) cursor() (*eventCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	return decodeEventCursor(q.Cursor, q.When)
+}
+
+// window keeps rows whose dateColumn is on the listed side of now
+func (q *UserListQuery) window(dialect db.SQLDialect, dateColumn string, now time.Time) ([]string, []any) {
+	operator := " >= "
+	if q.When == WhenPast {
+		operator = " < "
+	}
+
+	return []string{dialect.Timestamp(dateColumn) + operator + dialect.Timestamp("?")}, []any{now}
+}
+
+// seek keeps rows that come after the cursor in the listing's order
+func (q *UserListQuery) seek(dialect db.SQLDialect, dateColumn, idColumn string, cursor *eventCursor) ([]string, []any) {
+	if cursor == nil {
+		return nil, nil
+	}
+
+	dateTime := dialect.Timestamp(dateColumn)
+	param := dialect.Timestamp("?")
+
+	operator := " > "
+	if q.When == WhenPast {
+		operator = " < "
+	}
+
+	condition := "(" + dateTime + operator + param + " OR (" + dateTime + " = " + param + " AND " + idColumn + operator + "?))"
+
+	return []string{condition}, []any{cursor.DateTime, cursor.DateTime, cursor.ID}
+}
+
+func (q *UserListQuery) order(dateColumn, idColumn string) string {
+	if q.When == WhenPast {
+		return " ORDER BY " + dateColumn + " DESC, " + idColumn + " DESC"
+	}
+
+	return " ORDER BY " + dateColumn + " ASC, " + idColumn + " ASC"
+}
+
+// ListEvents returns one page of the events the user owns. A recurring
+// event is listed once, at its next occurrence if it has one within
+// MaxOccurrenceWindow and otherwise at its most recent one.
+func (r sqlUsers) ListEvents(ctx context.Context, q UserListQuery) (*OccurrencePage, error) {
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	cursor, err := q.cursor()
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	// One-off events are filtered and paged by the database
+	conditions, args := q.window(r.dialect, "dateTime", now)
+	conditions = append([]string{"user_id = ?", "rrule = ''"}, conditions...)
+	args = append([]any{q.UserID}, args...)
+
+	var total int64
+	err = r.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM events"+where(conditions), args...).Scan(&total)
+
+	if err != nil {
+		return nil, err
+	}
+
+	seek, seekArgs := q.seek(r.dialect, "dateTime", "id", cursor)
+	query := "SELECT " + eventColumns + " FROM events" + where(append(conditions, seek...)) + q.order("dateTime", "id") + " LIMIT ?"
+	events, err := r.queryEvents(ctx, query, append(append(args, seekArgs...), q.Limit+1)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var occurrences []Occurrence
+	for _, event := range events {
+		occurrences = append(occurrences, Occurrence{Event: event})
+	}
+
+	// Only series need expanding to find where they stand now
+	series, err := r.queryEvents(ctx, "SELECT "+eventColumns+" FROM events WHERE user_id = ? AND rrule <> ''", q.UserID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+	for _, event := range series {
+		ids = append(ids, event.ID)
+	}
+
+	exceptions, err := r.getEventExceptions(ctx, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range series {
+		occurrence, upcoming, err := currentOccurrence(event, exceptions[event.ID], now)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if upcoming != (q.When == WhenUpcoming) {
+			continue
+		}
+
+		total++
+
+		if q.after(cursor, occurrence.DateTime, occurrence.ID) {
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+
+	descending := q.When == WhenPast
+
+	sort.Slice(occurrences, func(i, j int) bool {
+		return occurrenceBefore(occurrences[i], occurrences[j], descending)
+	})
+
+	page := OccurrencePage{Events: []Occurrence{}, Total: total}
+
+	if len(occurrences) > q.Limit {
+		occurrences = occurrences[:q.Limit]
+		last := occurrences[len(occurrences)-1]
+		page.NextCursor = encodeEventCursor(eventCursor{Sort: q.When, DateTime: last.DateTime.UTC(), ID: last.ID})
+	}
+
+	page.Events = append(page.Events, occurrences...)
+
+	return &page, nil
+}
+
+// currentOccurrence picks the occurrence that represents the event now: the
+// next one if the event is upcoming, otherwise the most recent one
+func currentOccurrence(event Event, exceptions map[string]EventException, now time.Time) (Occurrence, bool, error) {
+	if event.RRule == "" {
+		return Occurrence{Event: event}, !event.DateTime.Before(now), nil
+	}
+
+	next, err := expandEvent(event, exceptions, now, now.Add(MaxOccurrenceWindow))
+
+	if err != nil {
+		return Occurrence{}, false, err
+	}
+
+	if len(next) > 0 {
+		return next[0], true, nil
+	}
+
+	previous, err := expandEvent(event, exceptions, now.Add(-MaxOccurrenceWindow), now)
+
+	if err != nil {
+		return Occurrence{}, false, err
+	}
+
+	if len(previous) > 0 {
+		return previous[len(previous)-1], false, nil
+	}
+
+	// Nothing within a year either way: fall back to the start of the series
+	occurrence := Occurrence{Event: event, Occurrence: OccurrenceKey(event.DateTime)}
+
+	return occurrence, !event.DateTime.Before(now), nil
+}
+
+// ListRegistrations returns one page of the occurrences the user is
+// registered or waitlisted for
+func (r sqlUsers) ListRegistrations(ctx context.Context, q UserListQuery) (*RegistrationPage, error) {
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	cursor, err := q.cursor()
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	// Registrations for one-off events are filtered and paged by the database
+	conditions, args := q.window(r.dialect, "e.dateTime", now)
+	conditions = append([]string{"r.user_id = ?", "r.occurrence = ''"}, conditions...)
+	args = append([]any{q.UserID}, args...)
+
+	if q.Status != "" {
+		conditions = append(conditions, "r.status = ?")
+		args = append(args, q.Status)
+	}
+
+	var total int64
+	err = r.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM events_registry r INNER JOIN events e ON e.id = r.event_id"+
+		where(conditions), args...).Scan(&total)
+
+	if err != nil {
+		return nil, err
+	}
+
+	seek, seekArgs := q.seek(r.dialect, "e.dateTime", "r.id", cursor)
+	query := registrationQuery + where(append(conditions, seek...)) + q.order("e.dateTime", "r.id") + " LIMIT ?"
+	matching, err := r.queryRegistrations(ctx, query, append(append(args, seekArgs...), q.Limit+1)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Occurrences of series may have been moved, so their dates are resolved here
+	occurrences, err := r.queryRegistrations(ctx, registrationQuery+" WHERE r.user_id = ? AND r.occurrence <> ''", q.UserID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, registration := range occurrences {
+		if q.Status != "" && registration.Status != q.Status {
+			continue
+		}
+
+		if registration.DateTime.Before(now) == (q.When == WhenUpcoming) {
+			continue
+		}
+
+		total++
+
+		if q.after(cursor, registration.DateTime, registration.RegistrationID) {
+			matching = append(matching, registration)
+		}
+	}
+
+	descending := q.When == WhenPast
+
+	sort.Slice(matching, func(i, j int) bool {
+		a, b := matching[i], matching[j]
+		return keyBefore(a.DateTime, a.RegistrationID, b.DateTime, b.RegistrationID, descending)
+	})
+
+	page := RegistrationPage{Registrations: []UserRegistration{}, Total: total}
+
+	if len(matching) > q.Limit {
+		matching = matching[:q.Limit]
+		last := matching[len(matching)-1]
+		page.NextCursor = encodeEventCursor(eventCursor{Sort: q.When, DateTime: last.DateTime.UTC(), ID: last.RegistrationID})
+	}
+
+	page.Registrations = append(page.Registrations, matching...)
+
+	return &page, nil
+}
+
+// registrationQuery selects registrations with their events, for scanning by
+// queryRegistrations
+var registrationQuery = `
+	SELECT ` + qualifiedEventColumns("e") + `, r.occurrence, r.id, r.status, r.created_at
+	FROM events_registry r
+	INNER JOIN events e ON e.id = r.event_id`
+
+// registrations lists every event occurrence the user is registered or
+// waitlisted for, in date order, with moved occurrences at their new time
+func (r sqlUsers) registrations(ctx context.Context, userID int64) ([]UserRegistration, error) {
+	registrations, err := r.queryRegistrations(ctx, registrationQuery+" WHERE r.user_id = ? ORDER BY e.dateTime, e.id", userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(registrations, func(i, j int) bool {
+		return registrations[i].DateTime.Before(registrations[j].DateTime)
+	})
+
+	return registrations, nil
+}
+
+// queryRegistrations runs a registrationQuery, placing each occurrence of a
+// series at its own start, or its new one if it was moved
+func (r sqlUsers) queryRegistrations(ctx context.Context, query string, args ...any) ([]UserRegistration, error) {
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var registrations []UserRegistration
+	var recurring []int64
+
+	for rows.Next() {
+		var registration UserRegistration
+
+		registration.Event, err = scanEvent(rows, &registration.Occurrence.Occurrence, &registration.RegistrationID,
+			&registration.Status, &registration.RegisteredAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if registration.RRule != "" {
+			recurring = append(recurring, registration.ID)
+		}
+
+		registrations = append(registrations, registration)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	exceptions, err := r.getEventExceptions(ctx, recurring)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range registrations {
+		registration := &registrations[i]
+
+		if registration.Occurrence.Occurrence == "" {
+			continue
+		}
+
+		start, err := parseOccurrenceKey(registration.Occurrence.Occurrence)
+
+		if err != nil {
+			return nil, err
+		}
+
+		registration.DateTime = start.In(registration.Zone())
+
+		exception, changed := exceptions[registration.ID][registration.Occurrence.Occurrence]
+
+		switch {
+		case changed && exception.Canceled:
+			registration.Canceled = true
+		case changed && exception.NewStart != nil:
+			registration.DateTime = exception.NewStart.In(registration.Zone())
+			registration.Moved = true
+		}
+	}
+
+	return registrations, nil
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// createDashboardEvents gives the owner a past event, an upcoming event and a
+// weekly series whose last occurrence was a day ago and next is in six days
+func createDashboardEvents(t *testing.T, ownerID int64) (past, upcoming, series Event) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	past = Event{Name: "Past", Description: "Done", Location: "Dhaka", DateTime: now.Add(-72 * time.Hour), UserID: ownerID}
+	upcoming = Event{Name: "Upcoming", Description: "Soon", Location: "Dhaka", DateTime: now.Add(72 * time.Hour), UserID: ownerID}
+	series = Event{
+		Name:        "Series",
+		Description: "Weekly",
+		Location:    "Dhaka",
+		DateTime:    now.Add(-8 * 24 * time.Hour),
+		UserID:      ownerID,
+		RRule:       "FREQ=WEEKLY;COUNT=10",
+	}
+
+	for _, event := range []*Event{&past, &upcoming, &series} {
+		assert.NoError(t, testRepositories().Events.Save(context.Background(), event))
+	}
+
+	return past, upcoming, series
+}
+
+func TestListUserEvents(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 2)
+	_, _, series := createDashboardEvents(t, users[0].ID)
+	createTestEvent(t, users[1].ID, nil, false)
+
+	upcoming, err := testRepositories().Users.ListEvents(context.Background(), UserListQuery{UserID: users[0].ID, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), upcoming.Total)
+	assert.Len(t, upcoming.Events, 1)
+	assert.Equal(t, "Upcoming", upcoming.Events[0].Name)
+
+	next, err := testRepositories().Users.ListEvents(context.Background(), UserListQuery{UserID: users[0].ID, Limit: 1, Cursor: upcoming.NextCursor})
+	assert.NoError(t, err)
+	assert.Len(t, next.Events, 1)
+	assert.Equal(t, "Series", next.Events[0].Name)
+	assert.Equal(t, OccurrenceKey(series.DateTime.AddDate(0, 0, 14)), next.Events[0].Occurrence)
+	assert.Empty(t, next.NextCursor)
+
+	past, err := testRepositories().Users.ListEvents(context.Background(), UserListQuery{UserID: users[0].ID, When: WhenPast})
+	assert.NoError(t, err)
+	assert.Len(t, past.Events, 1)
+	assert.Equal(t, "Past", past.Events[0].Name)
+
+	// A cursor from another listing is rejected
+	_, err = testRepositories().Users.ListEvents(context.Background(), UserListQuery{UserID: users[0].ID, When: WhenPast, Cursor: upcoming.NextCursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = testRepositories().Users.ListEvents(context.Background(), UserListQuery{UserID: users[0].ID, When: "someday"})
+	assert.Error(t, err)
+}
+
+func TestListUserEvents_PagesOneOffEventsAroundSeries(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	createDashboardEvents(t, users[0].ID)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, event := range []Event{
+		{Name: "Tomorrow", Description: "Soon", Location: "Dhaka", DateTime: now.Add(24 * time.Hour), UserID: users[0].ID},
+		{Name: "Later", Description: "Soon", Location: "Dhaka", DateTime: now.Add(10 * 24 * time.Hour), UserID: users[0].ID},
+	} {
+		assert.NoError(t, testRepositories().Events.Save(context.Background(), &event))
+	}
+
+	var names []string
+	query := UserListQuery{UserID: users[0].ID, Limit: 2}
+
+	for {
+		page, err := testRepositories().Users.ListEvents(context.Background(), query)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), page.Total)
+		assert.LessOrEqual(t, len(page.Events), 2)
+
+		for _, event := range page.Events {
+			names = append(names, event.Name)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		query.Cursor = page.NextCursor
+	}
+
+	assert.Equal(t, []string{"Tomorrow", "Upcoming", "Series", "Later"}, names)
+}
+
+func TestListUserRegistrations(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 2)
+	past, upcoming, series := createDashboardEvents(t, users[0].ID)
+	attendee := users[1].ID
+
+	lastWeek := OccurrenceKey(series.DateTime.AddDate(0, 0, 7))
+	nextWeek := OccurrenceKey(series.DateTime.AddDate(0, 0, 14))
+
+	for _, registration := range []EventRegister{
+		{EventID: past.ID, UserID: attendee},
+		{EventID: upcoming.ID, UserID: attendee},
+		{EventID: series.ID, UserID: attendee, Occurrence: lastWeek},
+		{EventID: series.ID, UserID: attendee, Occurrence: nextWeek},
+	} {
+		assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registration))
+	}
+
+	page, err := testRepositories().Users.ListRegistrations(context.Background(), UserListQuery{UserID: attendee})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+	assert.Equal(t, "Upcoming", page.Registrations[0].Name)
+	assert.Equal(t, "Series", page.Registrations[1].Name)
+	assert.Equal(t, nextWeek, page.Registrations[1].Occurrence.Occurrence)
+	assert.Equal(t, RegistrationStatusRegistered, page.Registrations[1].Status)
+	assert.NotZero(t, page.Registrations[1].RegistrationID)
+
+	// Most recent first
+	history, err := testRepositories().Users.ListRegistrations(context.Background(), UserListQuery{UserID: attendee, When: WhenPast, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), history.Total)
+	assert.Equal(t, lastWeek, history.Registrations[0].Occurrence.Occurrence)
+
+	older, err := testRepositories().Users.ListRegistrations(context.Background(), UserListQuery{UserID: attendee, When: WhenPast, Limit: 1, Cursor: history.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, "Past", older.Registrations[0].Name)
+	assert.Empty(t, older.NextCursor)
+
+	waitlisted, err := testRepositories().Users.ListRegistrations(context.Background(), UserListQuery{UserID: attendee, Status: RegistrationStatusWaitlisted})
+	assert.NoError(t, err)
+	assert.Empty(t, waitlisted.Registrations)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"testing"
@@ -76,7 +77,7 @@ func TestUser_Save(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockFn()
 
-			err := tt.user.Save()
+			err := testRepositories().Users.Save(context.Background(), &tt.user)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -114,9 +115,9 @@ func TestUser_ValidateUser(t *testing.T) {
 				Password: plainPassword,
 			},
 			mockFn: func() {
-				columns := []string{"id", "password"}
-				rows := sqlmock.NewRows(columns).AddRow(testUser.ID, hashedPassword)
-				mock.ExpectQuery(`SELECT id, password FROM users WHERE email = \?`).
+				columns := []string{"id", "password", "role"}
+				rows := sqlmock.NewRows(columns).AddRow(testUser.ID, hashedPassword, "organizer")
+				mock.ExpectQuery(`SELECT id, password, role FROM users WHERE email = \?`).
 					WithArgs(testUser.Email).WillReturnRows(rows)
 			},
 			wantErr: false,
@@ -128,9 +129,9 @@ func TestUser_ValidateUser(t *testing.T) {
 				Password: "wrongpassword",
 			},
 			mockFn: func() {
-				columns := []string{"id", "password"}
-				rows := sqlmock.NewRows(columns).AddRow(testUser.ID, hashedPassword)
-				mock.ExpectQuery(`SELECT id, password FROM users WHERE email = \?`).
+				columns := []string{"id", "password", "role"}
+				rows := sqlmock.NewRows(columns).AddRow(testUser.ID, hashedPassword, "organizer")
+				mock.ExpectQuery(`SELECT id, password, role FROM users WHERE email = \?`).
 					WithArgs(testUser.Email).WillReturnRows(rows)
 			},
 			wantErr: true,
@@ -142,7 +143,7 @@ func TestUser_ValidateUser(t *testing.T) {
 				Password: plainPassword,
 			},
 			mockFn: func() {
-				mock.ExpectQuery(`SELECT id, password FROM users WHERE email = \?`).
+				mock.ExpectQuery(`SELECT id, password, role FROM users WHERE email = \?`).
 					WithArgs("nonexistent@example.com").WillReturnError(sql.ErrNoRows)
 			},
 			wantErr: true,
@@ -154,7 +155,7 @@ func TestUser_ValidateUser(t *testing.T) {
 				Password: plainPassword,
 			},
 			mockFn: func() {
-				mock.ExpectQuery(`SELECT id, password FROM users WHERE email = \?`).
+				mock.ExpectQuery(`SELECT id, password, role FROM users WHERE email = \?`).
 					WithArgs(testUser.Email).WillReturnError(errors.New("query error"))
 			},
 			wantErr: true,
@@ -166,9 +167,9 @@ func TestUser_ValidateUser(t *testing.T) {
 				Password: plainPassword,
 			},
 			mockFn: func() {
-				columns := []string{"id", "password"}
-				rows := sqlmock.NewRows(columns).AddRow("invalid_id", hashedPassword)
-				mock.ExpectQuery(`SELECT id, password FROM users WHERE email = \?`).
+				columns := []string{"id", "password", "role"}
+				rows := sqlmock.NewRows(columns).AddRow("invalid_id", hashedPassword, "organizer")
+				mock.ExpectQuery(`SELECT id, password, role FROM users WHERE email = \?`).
 					WithArgs(testUser.Email).WillReturnRows(rows)
 			},
 			wantErr: true,
@@ -179,7 +180,7 @@ func TestUser_ValidateUser(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockFn()
 
-			err := tt.user.ValidateUser()
+			err := testRepositories().Users.ValidateCredentials(context.Background(), &tt.user)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -211,10 +212,10 @@ func TestGetUser(t *testing.T) {
 			name:   "Successful query",
 			userID: testUser.ID,
 			mockFn: func() {
-				columns := []string{"id", "email", "password"}
+				columns := []string{"id", "email", "password", "role"}
 				rows := sqlmock.NewRows(columns).
-					AddRow(testUser.ID, testUser.Email, testUser.Password)
-				mock.ExpectQuery(`SELECT \* FROM users WHERE id = \?`).
+					AddRow(testUser.ID, testUser.Email, testUser.Password, "admin")
+				mock.ExpectQuery(`SELECT id, email, password, role FROM users WHERE id = \?`).
 					WithArgs(testUser.ID).WillReturnRows(rows)
 			},
 			wantErr: false,
@@ -222,13 +223,14 @@ func TestGetUser(t *testing.T) {
 				ID:       testUser.ID,
 				Email:    testUser.Email,
 				Password: testUser.Password,
+				Role:     RoleAdmin,
 			},
 		},
 		{
 			name:   "User not found",
 			userID: 999,
 			mockFn: func() {
-				mock.ExpectQuery(`SELECT \* FROM users WHERE id = \?`).
+				mock.ExpectQuery(`SELECT id, email, password, role FROM users WHERE id = \?`).
 					WithArgs(int64(999)).WillReturnError(sql.ErrNoRows)
 			},
 			wantErr:  true,
@@ -238,7 +240,7 @@ func TestGetUser(t *testing.T) {
 			name:   "Query error",
 			userID: testUser.ID,
 			mockFn: func() {
-				mock.ExpectQuery(`SELECT \* FROM users WHERE id = \?`).
+				mock.ExpectQuery(`SELECT id, email, password, role FROM users WHERE id = \?`).
 					WithArgs(testUser.ID).WillReturnError(errors.New("query error"))
 			},
 			wantErr:  true,
@@ -248,10 +250,10 @@ func TestGetUser(t *testing.T) {
 			name:   "Scan error",
 			userID: testUser.ID,
 			mockFn: func() {
-				columns := []string{"id", "email", "password"}
+				columns := []string{"id", "email", "password", "role"}
 				rows := sqlmock.NewRows(columns).
-					AddRow("invalid_id", testUser.Email, testUser.Password)
-				mock.ExpectQuery(`SELECT \* FROM users WHERE id = \?`).
+					AddRow("invalid_id", testUser.Email, testUser.Password, "admin")
+				mock.ExpectQuery(`SELECT id, email, password, role FROM users WHERE id = \?`).
 					WithArgs(testUser.ID).WillReturnRows(rows)
 			},
 			wantErr:  true,
@@ -263,7 +265,7 @@ func TestGetUser(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockFn()
 
-			user, err := GetUser(tt.userID)
+			user, err := testRepositories().Users.GetByID(context.Background(), tt.userID)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -274,6 +276,7 @@ func TestGetUser(t *testing.T) {
 				assert.Equal(t, tt.wantUser.ID, user.ID)
 				assert.Equal(t, tt.wantUser.Email, user.Email)
 				assert.Equal(t, tt.wantUser.Password, user.Password)
+				assert.Equal(t, tt.wantUser.Role, user.Role)
 			}
 
 			assert.NoError(t, mock.ExpectationsWereMet())
@@ -297,13 +300,13 @@ func TestUser_ValidateUser_PasswordComparison(t *testing.T) {
 	}
 
 	// Mock successful database query
-	columns := []string{"id", "password"}
-	rows := sqlmock.NewRows(columns).AddRow(int64(1), hashedPassword)
-	mock.ExpectQuery(`SELECT id, password FROM users WHERE email = \?`).
+	columns := []string{"id", "password", "role"}
+	rows := sqlmock.NewRows(columns).AddRow(int64(1), hashedPassword, "organizer")
+	mock.ExpectQuery(`SELECT id, password, role FROM users WHERE email = \?`).
 		WithArgs(user.Email).WillReturnRows(rows)
 
 	// Should successfully validate
-	err = user.ValidateUser()
+	err = testRepositories().Users.ValidateCredentials(context.Background(), &user)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), user.ID)
 
@@ -326,7 +329,7 @@ func TestUser_Save_Integration(t *testing.T) {
 		WithArgs(user.Email, user.Password).
 		WillReturnResult(sqlmock.NewResult(42, 1))
 
-	err = user.Save()
+	err = testRepositories().Users.Save(context.Background(), &user)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(42), user.ID)
 
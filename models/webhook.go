@@ -0,0 +1,529 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"example.com/rest-api/utils"
+	"example.com/rest-api/webhook"
+)
+
+// Webhook event types. A webhook subscribed to WebhookEventAll receives every
+// type.
+const (
+	WebhookEventCreated         = "event.created"
+	WebhookEventUpdated         = "event.updated"
+	WebhookEventDeleted         = "event.deleted"
+	WebhookRegistrationCreated  = "registration.created"
+	WebhookRegistrationCanceled = "registration.canceled"
+	WebhookRegistrationPromoted = "registration.promoted"
+	WebhookOccurrenceUpdated    = "occurrence.updated"
+	WebhookOccurrenceRestored   = "occurrence.restored"
+	WebhookEventAll             = "*"
+)
+
+// defaultWebhookDeliveryLimit is how many deliveries the log shows by default
+const defaultWebhookDeliveryLimit = 50
+
+// WebhookEventTypes lists every type a webhook can subscribe to
+var WebhookEventTypes = []string{
+	WebhookEventCreated,
+	WebhookEventUpdated,
+	WebhookEventDeleted,
+	WebhookRegistrationCreated,
+	WebhookRegistrationCanceled,
+	WebhookRegistrationPromoted,
+	WebhookOccurrenceUpdated,
+	WebhookOccurrenceRestored,
+}
+
+var (
+	ErrWebhookNotFound     = errors.New("webhook not found")
+	ErrInvalidWebhookURL   = errors.New("url must be an absolute http or https URL")
+	ErrForbiddenWebhookURL = errors.New("url must not point to a private or local address")
+	ErrUnknownWebhookEvent = errors.New("unknown webhook event type")
+)
+
+// Webhook is an endpoint that receives the changes to the events its user
+// owns or co-organizes. The secret signs every payload and is only shown
+// when the webhook is created.
+type Webhook struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookPayload is the JSON body posted to webhooks. ID identifies the
+// change, so receivers can drop a payload they have already seen.
+type WebhookPayload struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// WebhookDelivery is one payload queued for one webhook, kept after sending
+// as the delivery log. ResponseCode is the status of the last attempt that
+// got a response.
+type WebhookDelivery struct {
+	ID            int64           `json:"id"`
+	WebhookID     int64           `json:"webhook_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	ResponseCode  *int            `json:"response_code"`
+	LastError     string          `json:"last_error"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// PendingWebhookDelivery is a delivery that is due, with where to send it
+type PendingWebhookDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// normalizeWebhookEvents checks the subscribed types, defaulting to all of them
+func normalizeWebhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return []string{WebhookEventAll}, nil
+	}
+
+	var normalized []string
+	seen := map[string]bool{}
+
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+
+		if event == WebhookEventAll {
+			return []string{WebhookEventAll}, nil
+		}
+
+		known := false
+		for _, eventType := range WebhookEventTypes {
+			known = known || eventType == event
+		}
+
+		if !known {
+			return nil, ErrUnknownWebhookEvent
+		}
+
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+
+	return normalized, nil
+}
+
+// Save validates the webhook and stores it with a new signing secret
+func (r sqlWebhooks) Save(ctx context.Context, w *Webhook) error {
+	target, err := url.Parse(w.URL)
+
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+
+	// Host names are checked again when the sender connects, after they
+	// resolve
+	host := target.Hostname()
+
+	if ip := net.ParseIP(host); strings.EqualFold(host, "localhost") || (ip != nil && webhook.Forbidden(ip)) {
+		return ErrForbiddenWebhookURL
+	}
+
+	w.Events, err = normalizeWebhookEvents(w.Events)
+
+	if err != nil {
+		return err
+	}
+
+	w.Secret, err = utils.RandomToken(32)
+
+	if err != nil {
+		return err
+	}
+
+	w.Active = true
+	w.CreatedAt = time.Now().UTC()
+
+	query := `INSERT INTO webhooks (user_id, url, secret, events, active, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+	result, err := r.conn.ExecContext(ctx, query, w.UserID, w.URL, w.Secret, strings.Join(w.Events, ","), w.Active, w.CreatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	w.ID, err = result.LastInsertId()
+
+	return err
+}
+
+// Subscribed reports whether the webhook receives the event type
+func (w *Webhook) Subscribed(eventType string) bool {
+	for _, event := range w.Events {
+		if event == WebhookEventAll || event == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+const webhookColumns = "id, user_id, url, secret, events, active, created_at"
+
+func scanWebhook(row rowScanner) (Webhook, error) {
+	var webhook Webhook
+	var events string
+
+	err := row.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Secret, &events, &webhook.Active, &webhook.CreatedAt)
+	webhook.Events = strings.Split(events, ",")
+
+	return webhook, err
+}
+
+// List lists the user's webhooks, oldest first
+func (r sqlWebhooks) List(ctx context.Context, userID int64) ([]Webhook, error) {
+	rows, err := r.conn.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE user_id = ? ORDER BY id`, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Get returns one of the user's webhooks, or ErrWebhookNotFound
+func (r sqlWebhooks) Get(ctx context.Context, id, userID int64) (*Webhook, error) {
+	row := r.conn.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = ? AND user_id = ?`, id, userID)
+	webhook, err := scanWebhook(row)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// Delete removes one of the user's webhooks along with its deliveries
+func (r sqlWebhooks) Delete(ctx context.Context, id, userID int64) error {
+	result, err := r.conn.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ? AND user_id = ?`, id, userID)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func webhookRecipients(ctx context.Context, q querier, eventID, ownerID int64) ([]int64, error) {
+	recipients := []int64{ownerID}
+
+	rows, err := q.QueryContext(ctx, `SELECT user_id FROM event_organizers WHERE event_id = ?`, eventID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID int64
+
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+
+		recipients = append(recipients, userID)
+	}
+
+	return recipients, rows.Err()
+}
+
+// Queue adds a delivery of the change to the outbox for every active
+// webhook of the given users that subscribes to its type. Changes to events
+// and registrations are queued by the models themselves, in the transaction
+// that stores them.
+func (r sqlWebhooks) Queue(ctx context.Context, userIDs []int64, eventType string, data any) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := queueWebhooks(ctx, tx, userIDs, eventType, data); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// queueEventWebhooks queues the change to the event for its owner's and
+// co-organizers' webhooks inside tx, so the deliveries are stored if and only
+// if the change is
+func queueEventWebhooks(ctx context.Context, tx *sql.Tx, eventID, ownerID int64, eventType string, data any) error {
+	recipients, err := webhookRecipients(ctx, tx, eventID, ownerID)
+
+	if err != nil {
+		return err
+	}
+
+	return queueWebhooks(ctx, tx, recipients, eventType, data)
+}
+
+func queueWebhooks(ctx context.Context, tx *sql.Tx, userIDs []int64, eventType string, data any) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	id, err := utils.RandomToken(16)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	payload, err := json.Marshal(WebhookPayload{ID: id, Type: eventType, CreatedAt: now, Data: data})
+
+	if err != nil {
+		return err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(userIDs)), ", ")
+	args := make([]any, len(userIDs))
+
+	for i, userID := range userIDs {
+		args[i] = userID
+	}
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE active = ? AND user_id IN (` + placeholders + `)`
+	rows, err := tx.QueryContext(ctx, query, append([]any{true}, args...)...)
+
+	if err != nil {
+		return err
+	}
+
+	var webhooks []Webhook
+
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		if webhook.Subscribed(eventType) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	for _, webhook := range webhooks {
+		_, err = tx.ExecContext(ctx, query, webhook.ID, eventType, string(payload), DeliveryStatusPending, 0, now, now)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+const webhookDeliveryColumns = `d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.response_code, d.last_error,
+	d.next_attempt_at, d.delivered_at, d.created_at`
+
+func scanWebhookDelivery(row rowScanner, extra ...any) (WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	var payload string
+	var lastError sql.NullString
+	var responseCode sql.NullInt64
+
+	dest := []any{&delivery.ID, &delivery.WebhookID, &delivery.EventType, &payload, &delivery.Status, &delivery.Attempts,
+		&responseCode, &lastError, &delivery.NextAttemptAt, &delivery.DeliveredAt, &delivery.CreatedAt}
+
+	err := row.Scan(append(dest, extra...)...)
+
+	delivery.Payload = json.RawMessage(payload)
+	delivery.LastError = lastError.String
+
+	if responseCode.Valid {
+		code := int(responseCode.Int64)
+		delivery.ResponseCode = &code
+	}
+
+	return delivery, err
+}
+
+// Pending returns up to limit deliveries of active
+// webhooks whose next attempt is due, oldest first
+func (r sqlWebhooks) Pending(ctx context.Context, limit int) ([]PendingWebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `, w.url, w.secret
+		FROM webhook_deliveries d
+		INNER JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = ? AND w.active = ? AND ` + r.dialect.Timestamp("d.next_attempt_at") + ` <= ` + r.dialect.Timestamp("?") + `
+		ORDER BY d.id
+		LIMIT ?
+	`
+	rows, err := r.conn.QueryContext(ctx, query, DeliveryStatusPending, true, time.Now().UTC(), limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var deliveries []PendingWebhookDelivery
+
+	for rows.Next() {
+		var delivery PendingWebhookDelivery
+
+		delivery.WebhookDelivery, err = scanWebhookDelivery(rows, &delivery.URL, &delivery.Secret)
+
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Claim reserves the delivery for sending. It reports false if another run
+// claimed it first, in which case it must not be sent.
+func (r sqlWebhooks) Claim(ctx context.Context, d *WebhookDelivery) (bool, error) {
+	return r.claimDelivery(ctx, "webhook_deliveries", d.ID)
+}
+
+// MarkDelivered records an attempt the endpoint accepted
+func (r sqlWebhooks) MarkDelivered(ctx context.Context, d *WebhookDelivery, responseCode int) error {
+	now := time.Now().UTC()
+	d.Attempts++
+
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = ?, response_code = ?, last_error = NULL, delivered_at = ? WHERE id = ?`
+	_, err := r.conn.ExecContext(ctx, query, DeliveryStatusSent, d.Attempts, responseCode, now, d.ID)
+
+	if err != nil {
+		return err
+	}
+
+	d.Status, d.ResponseCode, d.LastError, d.DeliveredAt = DeliveryStatusSent, &responseCode, "", &now
+
+	return nil
+}
+
+// MarkFailed records a failed attempt, with the response code if the endpoint
+// answered at all. Like email deliveries it is retried with exponential
+// backoff until maxAttempts is reached, then marked failed.
+func (r sqlWebhooks) MarkFailed(ctx context.Context, d *WebhookDelivery, responseCode int, sendErr error, maxAttempts int) error {
+	d.Attempts++
+	d.LastError = sendErr.Error()
+	d.NextAttemptAt = time.Now().UTC().Add(deliveryBackoff(d.Attempts))
+	d.Status = DeliveryStatusPending
+	d.ResponseCode = nil
+
+	if responseCode != 0 {
+		d.ResponseCode = &responseCode
+	}
+
+	if d.Attempts >= maxAttempts {
+		d.Status = DeliveryStatusFailed
+	}
+
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = ?, response_code = ?, last_error = ?, next_attempt_at = ? WHERE id = ?`
+	_, err := r.conn.ExecContext(ctx, query, d.Status, d.Attempts, d.ResponseCode, d.LastError, d.NextAttemptAt, d.ID)
+
+	return err
+}
+
+// Deliveries lists the most recent deliveries of a webhook, newest
+// first. A limit of zero uses the default of 50.
+func (r sqlWebhooks) Deliveries(ctx context.Context, webhookID int64, limit int) ([]WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = defaultWebhookDeliveryLimit
+	}
+
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries d WHERE d.webhook_id = ? ORDER BY d.id DESC LIMIT ?`
+	rows, err := r.conn.QueryContext(ctx, query, webhookID, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,302 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhook_Save(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+
+	tests := []struct {
+		name       string
+		webhook    Webhook
+		wantErr    error
+		wantEvents []string
+	}{
+		{
+			name:       "All events by default",
+			webhook:    Webhook{URL: "https://example.com/hooks"},
+			wantEvents: []string{WebhookEventAll},
+		},
+		{
+			name:       "Duplicate types are dropped",
+			webhook:    Webhook{URL: "http://hooks.example.com:9000/hooks", Events: []string{WebhookEventCreated, " event.created", WebhookRegistrationCreated}},
+			wantEvents: []string{WebhookEventCreated, WebhookRegistrationCreated},
+		},
+		{
+			name:    "Relative URL",
+			webhook: Webhook{URL: "/hooks"},
+			wantErr: ErrInvalidWebhookURL,
+		},
+		{
+			name:    "Unsupported scheme",
+			webhook: Webhook{URL: "ftp://example.com/hooks"},
+			wantErr: ErrInvalidWebhookURL,
+		},
+		{
+			name:    "Loopback address",
+			webhook: Webhook{URL: "http://localhost:9000/hooks"},
+			wantErr: ErrForbiddenWebhookURL,
+		},
+		{
+			name:    "Cloud metadata address",
+			webhook: Webhook{URL: "http://169.254.169.254/latest/meta-data"},
+			wantErr: ErrForbiddenWebhookURL,
+		},
+		{
+			name:    "Private address",
+			webhook: Webhook{URL: "https://[fd00::1]/hooks"},
+			wantErr: ErrForbiddenWebhookURL,
+		},
+		{
+			name:    "Unknown event type",
+			webhook: Webhook{URL: "https://example.com/hooks", Events: []string{"event.exploded"}},
+			wantErr: ErrUnknownWebhookEvent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhook := tt.webhook
+			webhook.UserID = users[0].ID
+
+			err := testRepositories().Webhooks.Save(context.Background(), &webhook)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Len(t, webhook.Secret, 64)
+
+			stored, err := testRepositories().Webhooks.Get(context.Background(), webhook.ID, users[0].ID)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantEvents, stored.Events)
+			assert.Equal(t, webhook.Secret, stored.Secret)
+			assert.True(t, stored.Active)
+		})
+	}
+}
+
+func TestQueueWebhooks(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 4)
+	owner, organizer, other, stranger := users[0], users[1], users[2], users[3]
+	event := createTestEvent(t, owner.ID, nil, false)
+	assert.NoError(t, testRepositories().Events.AddOrganizer(context.Background(), &event, organizer.ID))
+
+	ownerHook := Webhook{UserID: owner.ID, URL: "https://owner.example.com/hooks"}
+	organizerHook := Webhook{UserID: organizer.ID, URL: "https://organizer.example.com/hooks", Events: []string{WebhookRegistrationCreated}}
+	strangerHook := Webhook{UserID: stranger.ID, URL: "https://stranger.example.com/hooks"}
+	for _, webhook := range []*Webhook{&ownerHook, &organizerHook, &strangerHook} {
+		assert.NoError(t, testRepositories().Webhooks.Save(context.Background(), webhook))
+	}
+
+	recipients, err := webhookRecipients(context.Background(), db.DB, event.ID, event.UserID)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int64{owner.ID, organizer.ID}, recipients)
+
+	// Updating the event queues its webhooks; the organizer only subscribed
+	// to registrations
+	event.Name = "Renamed"
+	assert.NoError(t, testRepositories().Events.Update(context.Background(), &event))
+
+	pending, err := testRepositories().Webhooks.Pending(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, ownerHook.ID, pending[0].WebhookID)
+	assert.Equal(t, ownerHook.URL, pending[0].URL)
+	assert.Equal(t, ownerHook.Secret, pending[0].Secret)
+
+	var payload struct {
+		ID   string
+		Type string
+		Data Event
+	}
+	assert.NoError(t, json.Unmarshal(pending[0].Payload, &payload))
+	assert.NotEmpty(t, payload.ID)
+	assert.Equal(t, WebhookEventUpdated, payload.Type)
+	assert.Equal(t, event.ID, payload.Data.ID)
+
+	registration := EventRegister{EventID: event.ID, UserID: other.ID}
+	assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &registration))
+
+	// A change that is not stored queues nothing
+	again := EventRegister{EventID: event.ID, UserID: other.ID}
+	assert.ErrorIs(t, testRepositories().Registrations.Register(context.Background(), &again), ErrAlreadyRegistered)
+
+	pending, err = testRepositories().Webhooks.Pending(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 3)
+
+	deliveries, err := testRepositories().Webhooks.Deliveries(context.Background(), strangerHook.ID, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	// Deleting a webhook drops what is still queued for it
+	assert.NoError(t, testRepositories().Webhooks.Delete(context.Background(), organizerHook.ID, organizer.ID))
+	assert.ErrorIs(t, testRepositories().Webhooks.Delete(context.Background(), organizerHook.ID, organizer.ID), ErrWebhookNotFound)
+
+	pending, err = testRepositories().Webhooks.Pending(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 2)
+}
+
+func TestWebhookDelivery_Attempts(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	webhook := Webhook{UserID: users[0].ID, URL: "https://example.com/hooks"}
+	assert.NoError(t, testRepositories().Webhooks.Save(context.Background(), &webhook))
+	assert.NoError(t, testRepositories().Webhooks.Queue(context.Background(), []int64{users[0].ID}, WebhookEventDeleted, map[string]int64{"ID": 1}))
+
+	pending, err := testRepositories().Webhooks.Pending(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	delivery := pending[0].WebhookDelivery
+	assert.NoError(t, testRepositories().Webhooks.MarkFailed(context.Background(), &delivery, 503, errors.New("endpoint responded with 503 Service Unavailable"), 3))
+
+	// The retry is not due yet
+	pending, err = testRepositories().Webhooks.Pending(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+
+	_, err = db.DB.Exec(`UPDATE webhook_deliveries SET next_attempt_at = ?`, time.Now().Add(-time.Minute).UTC())
+	assert.NoError(t, err)
+
+	pending, err = testRepositories().Webhooks.Pending(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, 503, *pending[0].ResponseCode)
+
+	delivery = pending[0].WebhookDelivery
+	assert.NoError(t, testRepositories().Webhooks.MarkDelivered(context.Background(), &delivery, 204))
+
+	log, err := testRepositories().Webhooks.Deliveries(context.Background(), webhook.ID, 0)
+	assert.NoError(t, err)
+	assert.Len(t, log, 1)
+	assert.Equal(t, DeliveryStatusSent, log[0].Status)
+	assert.Equal(t, 2, log[0].Attempts)
+	assert.Equal(t, 204, *log[0].ResponseCode)
+	assert.Empty(t, log[0].LastError)
+	assert.NotNil(t, log[0].DeliveredAt)
+}
+
+func TestWebhookDelivery_ClaimOnce(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	webhook := Webhook{UserID: users[0].ID, URL: "https://example.com/hooks"}
+	assert.NoError(t, testRepositories().Webhooks.Save(context.Background(), &webhook))
+	assert.NoError(t, testRepositories().Webhooks.Queue(context.Background(), []int64{users[0].ID}, WebhookEventCreated, nil))
+
+	// Two runs read the same pending delivery
+	first, err := testRepositories().Webhooks.Pending(context.Background(), 10)
+	assert.NoError(t, err)
+	second, err := testRepositories().Webhooks.Pending(context.Background(), 10)
+	assert.NoError(t, err)
+
+	claimed, err := testRepositories().Webhooks.Claim(context.Background(), &first[0].WebhookDelivery)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = testRepositories().Webhooks.Claim(context.Background(), &second[0].WebhookDelivery)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	// Held until the claim runs out, in case its sender died
+	pending, err := testRepositories().Webhooks.Pending(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+
+	_, err = db.DB.Exec(`UPDATE webhook_deliveries SET next_attempt_at = ?`, time.Now().Add(-time.Minute).UTC())
+	assert.NoError(t, err)
+
+	claimed, err = testRepositories().Webhooks.Claim(context.Background(), &second[0].WebhookDelivery)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestWebhookDelivery_GivesUp(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 1)
+	webhook := Webhook{UserID: users[0].ID, URL: "https://example.com/hooks"}
+	assert.NoError(t, testRepositories().Webhooks.Save(context.Background(), &webhook))
+	assert.NoError(t, testRepositories().Webhooks.Queue(context.Background(), []int64{users[0].ID}, WebhookEventCreated, nil))
+
+	pending, err := testRepositories().Webhooks.Pending(context.Background(), 10)
+	assert.NoError(t, err)
+
+	delivery := pending[0].WebhookDelivery
+	assert.NoError(t, testRepositories().Webhooks.MarkFailed(context.Background(), &delivery, 0, errors.New("connection refused"), 1))
+
+	log, err := testRepositories().Webhooks.Deliveries(context.Background(), webhook.ID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, DeliveryStatusFailed, log[0].Status)
+	assert.Nil(t, log[0].ResponseCode)
+	assert.Equal(t, "connection refused", log[0].LastError)
+}
+
+func TestWebhooks_QueuedWithTheChange(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	users := createTestUsers(t, 3)
+	owner, organizer, guest := users[0], users[1], users[2]
+	capacity := int64(1)
+	event := createTestEvent(t, owner.ID, &capacity, true)
+	assert.NoError(t, testRepositories().Events.AddOrganizer(context.Background(), &event, organizer.ID))
+
+	organizerHook := Webhook{UserID: organizer.ID, URL: "https://organizer.example.com/hooks"}
+	assert.NoError(t, testRepositories().Webhooks.Save(context.Background(), &organizerHook))
+
+	first := EventRegister{EventID: event.ID, UserID: owner.ID}
+	assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &first))
+	waiting := EventRegister{EventID: event.ID, UserID: guest.ID}
+	assert.NoError(t, testRepositories().Registrations.Register(context.Background(), &waiting))
+
+	// Canceling promotes the guest, and both changes are queued
+	assert.NoError(t, testRepositories().Registrations.Cancel(context.Background(), &first))
+
+	// Co-organizers are removed with the event but still hear about it
+	other := createTestEvent(t, owner.ID, nil, false)
+	assert.NoError(t, testRepositories().Events.AddOrganizer(context.Background(), &other, organizer.ID))
+	assert.NoError(t, testRepositories().Events.Delete(context.Background(), &other))
+
+	deliveries, err := testRepositories().Webhooks.Deliveries(context.Background(), organizerHook.ID, 0)
+	assert.NoError(t, err)
+
+	var types []string
+	for _, delivery := range deliveries {
+		types = append(types, delivery.EventType)
+	}
+
+	assert.Equal(t, []string{WebhookEventDeleted, WebhookRegistrationPromoted,
+		WebhookRegistrationCanceled, WebhookRegistrationCreated, WebhookRegistrationCreated}, types)
+}
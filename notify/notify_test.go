@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/rest-api/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompose(t *testing.T) {
+	msg, err := Compose("user@example.com", Content{
+		Type:      "upcoming_event",
+		Message:   "Reminder: <Go> meetup is in 2 hour(s)",
+		EventName: "Go & Rust",
+		EventTime: time.Date(2030, 1, 7, 18, 0, 0, 0, time.UTC),
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "user@example.com", msg.To)
+	assert.Equal(t, "Reminder: Go & Rust is coming up", msg.Subject)
+	assert.Contains(t, msg.Text, "Reminder: <Go> meetup is in 2 hour(s)")
+	assert.Contains(t, msg.Text, "Monday, January 7, 2030 at 18:00 UTC")
+	assert.Contains(t, msg.HTML, "Reminder: &lt;Go&gt; meetup")
+	assert.Contains(t, msg.HTML, "Go &amp; Rust")
+}
+
+func TestCompose_Locale(t *testing.T) {
+	msg, err := Compose("user@example.com", Content{
+		Type:      "upcoming_event",
+		Locale:    "bn-BD",
+		Message:   "অনুস্মারক",
+		EventName: "Go Meetup",
+		EventTime: time.Date(2030, 1, 7, 18, 0, 0, 0, time.UTC),
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "অনুস্মারক: Go Meetup আসছে", msg.Subject)
+	assert.Contains(t, msg.Text, "সময়:  সোমবার, ৭ জানুয়ারি ২০৩০, ১৮:০০ UTC")
+	assert.Contains(t, msg.HTML, "<strong>ইভেন্ট</strong>")
+}
+
+func TestCompose_UnknownType(t *testing.T) {
+	msg, err := Compose("user@example.com", Content{Type: "other", Message: "Hello"})
+	assert.NoError(t, err)
+	assert.Equal(t, "News about your event", msg.Subject)
+	assert.NotContains(t, msg.Text, "Event:")
+}
+
+func TestEncode(t *testing.T) {
+	content, err := encode("Go Events <no-reply@example.com>", Message{
+		To:      "user@example.com",
+		Subject: "Régistration",
+		Text:    "plain body",
+		HTML:    "<p>html body</p>",
+	}, time.Date(2030, 1, 7, 18, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+
+	encoded := string(content)
+	assert.Contains(t, encoded, "To: user@example.com\r\n")
+	assert.Contains(t, encoded, "Subject: =?utf-8?q?R=C3=A9gistration?=\r\n")
+	assert.Contains(t, encoded, "Content-Type: multipart/alternative; boundary=")
+	assert.Contains(t, encoded, "plain body")
+	assert.Contains(t, encoded, "<p>html body</p>")
+	assert.Less(t, strings.Index(encoded, "text/plain"), strings.Index(encoded, "text/html"))
+}
+
+func TestNew(t *testing.T) {
+	cfg := config.Default().Mail
+
+	notifier, err := New(cfg)
+	assert.NoError(t, err)
+	assert.Nil(t, notifier)
+
+	cfg.Driver = config.MailDriverSMTP
+	notifier, err = New(cfg)
+	assert.NoError(t, err)
+	assert.IsType(t, &SMTPNotifier{}, notifier)
+
+	cfg.Driver = "pigeon"
+	_, err = New(cfg)
+	assert.Error(t, err)
+}
+
+func TestFileNotifier(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	notifier := &FileNotifier{Dir: dir, From: "no-reply@example.com"}
+
+	assert.NoError(t, notifier.Send(context.Background(), Message{To: "user@example.com", Subject: "Hi", Text: "Hello", HTML: "<p>Hello</p>"}))
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.True(t, strings.HasSuffix(files[0].Name(), ".eml"))
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileNotifier writes each message as an .eml file into Dir, which most mail
+// clients can open. Meant for development.
+type FileNotifier struct {
+	Dir  string
+	From string
+}
+
+func (n *FileNotifier) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(n.Dir, 0o755); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	content, err := encode(n.From, msg, now)
+
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%d.eml", now.UTC().Format("20060102T150405"), now.UnixNano())
+
+	return os.WriteFile(filepath.Join(n.Dir, name), content, 0o644)
+}
+
+// MemoryNotifier keeps sent messages in memory for tests
+type MemoryNotifier struct {
+	mu   sync.Mutex
+	sent []Message
+	err  error
+}
+
+func (n *MemoryNotifier) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.err != nil {
+		return n.err
+	}
+
+	n.sent = append(n.sent, msg)
+
+	return nil
+}
+
+// Sent returns the messages sent so far
+func (n *MemoryNotifier) Sent() []Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([]Message(nil), n.sent...)
+}
+
+// SetErr makes following sends fail with err, or succeed again if nil
+func (n *MemoryNotifier) SetErr(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.err = err
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPNotifier sends messages through an SMTP server, authenticating with
+// PLAIN when a username is set
+type SMTPNotifier struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers msg the way smtp.SendMail does, but gives up when ctx ends,
+// whether while connecting or in the middle of the conversation
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	from, err := mail.ParseAddress(n.From)
+
+	if err != nil {
+		return err
+	}
+
+	content, err := encode(n.From, msg, time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(n.Host, strconv.Itoa(n.Port)))
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	// net/smtp takes no context, so the connection's deadline stands in for it
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	err = n.converse(conn, from.Address, msg.To, content)
+
+	// Report why the conversation was cut short
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+func (n *SMTPNotifier) converse(conn net.Conn, from, to string, content []byte) error {
+	client, err := smtp.NewClient(conn, n.Host)
+
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.Host}); err != nil {
+			return err
+		}
+	}
+
+	if n.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+
+		if err := client.Auth(smtp.PlainAuth("", n.Username, n.Password, n.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTPServer accepts one connection and answers it with a minimal SMTP
+// conversation, sending what it received on the channel. With stall it never
+// greets the client.
+func fakeSMTPServer(t *testing.T, stall bool) (*SMTPNotifier, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if stall {
+			time.Sleep(5 * time.Second)
+			return
+		}
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		var transcript strings.Builder
+		reply("220 localhost ready")
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			transcript.WriteString(line)
+
+			switch command := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(command, "EHLO"):
+				reply("250 localhost")
+			case command == "DATA":
+				reply("354 go ahead")
+
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					transcript.WriteString(line)
+				}
+
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				received <- transcript.String()
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	notifier := &SMTPNotifier{Host: host, From: "Go Events <no-reply@example.com>"}
+	notifier.Port, _ = net.LookupPort("tcp", port)
+
+	return notifier, received
+}
+
+func TestSMTPNotifier_Send(t *testing.T) {
+	notifier, received := fakeSMTPServer(t, false)
+
+	err := notifier.Send(context.Background(), Message{To: "user@example.com", Subject: "Hi", Text: "Hello", HTML: "<p>Hello</p>"})
+	assert.NoError(t, err)
+
+	transcript := <-received
+	assert.Contains(t, transcript, "MAIL FROM:<no-reply@example.com>")
+	assert.Contains(t, transcript, "RCPT TO:<user@example.com>")
+	assert.Contains(t, transcript, "Subject: Hi")
+}
+
+func TestSMTPNotifier_SendGivesUpWithContext(t *testing.T) {
+	notifier, _ := fakeSMTPServer(t, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := notifier.Send(ctx, Message{To: "user@example.com", Subject: "Hi", Text: "Hello", HTML: "<p>Hello</p>"})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+
+	"example.com/rest-api/i18n"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// The templates call t for catalog texts and when for the event time; both
+// are bound to the recipient's locale in Compose
+var (
+	textTemplates = texttemplate.Must(texttemplate.New("").Funcs(templateFuncs("")).ParseFS(templateFiles, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.New("").Funcs(templateFuncs("")).ParseFS(templateFiles, "templates/*.html"))
+)
+
+// subjects are the catalog keys of email subjects by notification type
+var subjects = map[string]string{
+	"upcoming_event":    "email.subject.upcoming_event",
+	"waitlist_promoted": "email.subject.waitlist_promoted",
+}
+
+const defaultSubject = "email.subject.default"
+
+// Content is what a notification email is about
+type Content struct {
+	Type      string // the notification type, which picks the subject
+	Locale    string // the recipient's language tag
+	Message   string // already in the recipient's locale
+	EventName string
+	EventTime time.Time // shown in its own location, the recipient's timezone
+}
+
+func templateFuncs(locale string) map[string]any {
+	return map[string]any{
+		"t":    func(key string) string { return i18n.Render(locale, key, nil) },
+		"when": func(t time.Time) string { return i18n.Time(locale, t, "long") },
+	}
+}
+
+// Compose renders the notification email for one recipient
+func Compose(to string, content Content) (Message, error) {
+	msg := Message{To: to, Subject: subject(content)}
+	funcs := templateFuncs(content.Locale)
+
+	textTemplate, err := textTemplates.Clone()
+	if err != nil {
+		return Message{}, err
+	}
+
+	htmlTemplate, err := htmlTemplates.Clone()
+	if err != nil {
+		return Message{}, err
+	}
+
+	var text, html bytes.Buffer
+
+	if err := textTemplate.Funcs(funcs).ExecuteTemplate(&text, "notification.txt", content); err != nil {
+		return Message{}, err
+	}
+
+	if err := htmlTemplate.Funcs(funcs).ExecuteTemplate(&html, "notification.html", content); err != nil {
+		return Message{}, err
+	}
+
+	msg.Text, msg.HTML = text.String(), html.String()
+
+	return msg, nil
+}
+
+func subject(content Content) string {
+	key, ok := subjects[content.Type]
+
+	if !ok {
+		key = defaultSubject
+	}
+
+	name := content.EventName
+
+	if name == "" {
+		name = i18n.Render(content.Locale, "email.your_event", nil)
+	}
+
+	return i18n.Render(content.Locale, key, struct{ Event string }{name})
+}
@@ -0,0 +1,356 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"example.com/rest-api/models"
+)
+
+// Message types sent to event subscribers besides the models.EventChange types
+const (
+	MessageSubscribed = "subscribed"
+	MessageError      = "error"
+)
+
+const (
+	// clientBuffer is how many messages may wait for a slow client before it
+	// is disconnected
+	clientBuffer = 64
+	// maxClientSubscriptions bounds how many events one connection watches
+	maxClientSubscriptions = 100
+	// changeBatchSize bounds how many stored changes one read fetches
+	changeBatchSize = 100
+)
+
+// changePollInterval is how often Follow looks for changes stored by other
+// replicas
+var changePollInterval = time.Second
+
+var ErrTooManySubscriptions = errors.New("too many event subscriptions")
+
+// Events is the hub the server publishes event changes to
+var Events = NewEventHub()
+
+// EventMessage is what subscribers receive. Fields not relevant to the type
+// are left out.
+type EventMessage struct {
+	Type       string                 `json:"type"`
+	EventID    int64                  `json:"event_id,omitempty"`
+	EventIDs   []int64                `json:"event_ids,omitempty"`
+	Event      *models.Event          `json:"event,omitempty"`
+	Occurrence string                 `json:"occurrence,omitempty"`
+	Counts     *models.AttendeeCounts `json:"counts,omitempty"`
+	Exception  *models.EventException `json:"exception,omitempty"`
+	Message    string                 `json:"message,omitempty"`
+}
+
+// ChangeLog is where every replica stores its event changes, in id order
+type ChangeLog interface {
+	LastChangeID(ctx context.Context) (int64, error)
+	ChangesAfter(ctx context.Context, afterID int64, limit int) ([]models.EventChange, error)
+}
+
+// EventHub fans event changes out to the clients subscribed to each event.
+// It is safe for concurrent use; publishing never blocks on a client.
+type EventHub struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[*Client]struct{}
+	clients     map[*Client]struct{}
+	wake        chan struct{}
+}
+
+// Client is one connection's subscriptions and queue of outgoing messages
+type Client struct {
+	UserID int64
+
+	hub  *EventHub
+	send chan []byte
+
+	// guarded by hub.mu
+	events    map[int64]struct{}
+	closed    bool
+	dropped   bool
+	goingAway bool // closed because the server is shutting down
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{
+		subscribers: map[int64]map[*Client]struct{}{},
+		clients:     map[*Client]struct{}{},
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Follow publishes the changes stored from now on by any replica, in id
+// order, until ctx ends. It reads the log every changePollInterval, and at
+// once when Wake reports a change stored by this process.
+func (h *EventHub) Follow(ctx context.Context, changes ChangeLog) {
+	ticker := time.NewTicker(changePollInterval)
+	defer ticker.Stop()
+
+	last, started := int64(0), false
+
+	for {
+		if !started {
+			var err error
+			last, err = changes.LastChangeID(ctx)
+			started = err == nil
+
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Error reading last event change: %v", err)
+			}
+		} else {
+			last = h.publishAfter(ctx, changes, last)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-h.wake:
+		}
+	}
+}
+
+// Wake makes Follow read the log now instead of at its next poll. The change
+// itself is published by Follow, so changes from every replica go out in the
+// same order. It never blocks, so it can be registered with
+// models.OnEventChanged.
+func (h *EventHub) Wake(models.EventChange) {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// publishAfter publishes the changes stored after the one with id last and
+// returns the id of the last one published
+func (h *EventHub) publishAfter(ctx context.Context, changes ChangeLog, last int64) int64 {
+	for {
+		batch, err := changes.ChangesAfter(ctx, last, changeBatchSize)
+
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Error reading event changes: %v", err)
+			}
+			return last
+		}
+
+		for _, change := range batch {
+			h.PublishChange(change)
+			last = change.ID
+		}
+
+		if len(batch) < changeBatchSize {
+			return last
+		}
+	}
+}
+
+// NewClient creates a client with no subscriptions
+func (h *EventHub) NewClient(userID int64) *Client {
+	c := &Client{
+		UserID: userID,
+		hub:    h,
+		send:   make(chan []byte, clientBuffer),
+		events: map[int64]struct{}{},
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.clients[c] = struct{}{}
+
+	return c
+}
+
+// Close disconnects every client, telling it the server is going away so it
+// reconnects elsewhere
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.clients {
+		c.goingAway = true
+		h.remove(c)
+	}
+}
+
+// Subscribe adds events to the client's subscriptions and returns all of them
+func (h *EventHub) Subscribe(c *Client, eventIDs []int64) ([]int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if c.closed {
+		return nil, nil
+	}
+
+	added := 0
+	for _, id := range eventIDs {
+		if _, ok := c.events[id]; !ok {
+			added++
+		}
+	}
+
+	if len(c.events)+added > maxClientSubscriptions {
+		return c.subscriptions(), ErrTooManySubscriptions
+	}
+
+	for _, id := range eventIDs {
+		c.events[id] = struct{}{}
+
+		if h.subscribers[id] == nil {
+			h.subscribers[id] = map[*Client]struct{}{}
+		}
+		h.subscribers[id][c] = struct{}{}
+	}
+
+	return c.subscriptions(), nil
+}
+
+// Unsubscribe removes events from the client's subscriptions and returns the
+// remaining ones
+func (h *EventHub) Unsubscribe(c *Client, eventIDs []int64) []int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, id := range eventIDs {
+		h.unsubscribe(c, id)
+	}
+
+	return c.subscriptions()
+}
+
+// PublishChange sends the change to every client subscribed to its event.
+// A client whose queue is full is disconnected rather than slowing down the
+// others; it can reconnect, subscribe again and reload the event.
+func (h *EventHub) PublishChange(change models.EventChange) {
+	message, err := json.Marshal(EventMessage{
+		Type:       change.Type,
+		EventID:    change.EventID,
+		Event:      change.Event,
+		Occurrence: change.Occurrence,
+		Counts:     change.Counts,
+		Exception:  change.Exception,
+	})
+
+	if err != nil {
+		log.Printf("Error encoding change to event %d: %v", change.EventID, err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.subscribers[change.EventID] {
+		select {
+		case c.send <- message:
+		default:
+			c.dropped = true
+			h.remove(c)
+		}
+	}
+
+	// Nobody needs to hear about a deleted event again
+	if change.Type == models.EventChangeDeleted {
+		for c := range h.subscribers[change.EventID] {
+			h.unsubscribe(c, change.EventID)
+		}
+	}
+}
+
+// Subscribers returns how many clients watch the event
+func (h *EventHub) Subscribers(eventID int64) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subscribers[eventID])
+}
+
+// Close drops the client's subscriptions and closes its queue. It is safe to
+// call more than once.
+func (c *Client) Close() {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+
+	c.hub.remove(c)
+}
+
+// reply queues a message for this client only, dropping it if the queue is
+// full or closed
+func (c *Client) reply(message EventMessage) {
+	encoded, err := json.Marshal(message)
+
+	if err != nil {
+		return
+	}
+
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	select {
+	case c.send <- encoded:
+	default:
+	}
+}
+
+// wasDropped reports whether the hub disconnected the client for being slow
+func (c *Client) wasDropped() bool {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+
+	return c.dropped
+}
+
+// wentAway reports whether the hub disconnected the client on shutdown
+func (c *Client) wentAway() bool {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+
+	return c.goingAway
+}
+
+// subscriptions must be called with hub.mu held
+func (c *Client) subscriptions() []int64 {
+	ids := make([]int64, 0, len(c.events))
+
+	for id := range c.events {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// unsubscribe must be called with h.mu held
+func (h *EventHub) unsubscribe(c *Client, eventID int64) {
+	delete(c.events, eventID)
+	delete(h.subscribers[eventID], c)
+
+	if len(h.subscribers[eventID]) == 0 {
+		delete(h.subscribers, eventID)
+	}
+}
+
+// remove must be called with h.mu held
+func (h *EventHub) remove(c *Client) {
+	if c.closed {
+		return
+	}
+
+	for id := range c.events {
+		h.unsubscribe(c, id)
+	}
+
+	c.closed = true
+	close(c.send)
+	delete(h.clients, c)
+}
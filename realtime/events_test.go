@@ -0,0 +1,311 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/rest-api/models"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func receive(t *testing.T, c *Client) EventMessage {
+	var message EventMessage
+
+	select {
+	case encoded := <-c.send:
+		assert.NoError(t, json.Unmarshal(encoded, &message))
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+
+	return message
+}
+
+func TestEventHub_PublishChange(t *testing.T) {
+	hub := NewEventHub()
+	watcher := hub.NewClient(1)
+	other := hub.NewClient(2)
+
+	subscribed, err := hub.Subscribe(watcher, []int64{10, 11})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int64{10, 11}, subscribed)
+	_, err = hub.Subscribe(other, []int64{11})
+	assert.NoError(t, err)
+
+	hub.PublishChange(models.EventChange{
+		Type:    models.EventChangeRegistrationCreated,
+		EventID: 10,
+		Counts:  &models.AttendeeCounts{Registered: 3, Waitlisted: 1},
+	})
+
+	message := receive(t, watcher)
+	assert.Equal(t, models.EventChangeRegistrationCreated, message.Type)
+	assert.Equal(t, int64(10), message.EventID)
+	assert.Equal(t, int64(3), message.Counts.Registered)
+	assert.Empty(t, other.send)
+
+	assert.Equal(t, []int64{10}, hub.Unsubscribe(watcher, []int64{11}))
+	assert.Equal(t, 1, hub.Subscribers(11))
+
+	// Deleting an event ends every subscription to it
+	hub.PublishChange(models.EventChange{Type: models.EventChangeDeleted, EventID: 10})
+	assert.Equal(t, models.EventChangeDeleted, receive(t, watcher).Type)
+	assert.Zero(t, hub.Subscribers(10))
+
+	watcher.Close()
+	watcher.Close()
+	other.Close()
+	assert.Zero(t, hub.Subscribers(11))
+}
+
+// memoryChangeLog is a ChangeLog shared by hubs standing in for replicas
+type memoryChangeLog struct {
+	mu      sync.Mutex
+	changes []models.EventChange
+	started chan struct{} // closed once a hub reads where the log ends
+}
+
+func (l *memoryChangeLog) store(change models.EventChange) models.EventChange {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	change.ID = int64(len(l.changes) + 1)
+	l.changes = append(l.changes, change)
+
+	return change
+}
+
+func (l *memoryChangeLog) LastChangeID(context.Context) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	defer close(l.started)
+	return int64(len(l.changes)), nil
+}
+
+func (l *memoryChangeLog) ChangesAfter(_ context.Context, afterID int64, limit int) ([]models.EventChange, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	after := l.changes[afterID:]
+	return after[:min(limit, len(after))], nil
+}
+
+func TestEventHub_FollowPublishesChangesOfEveryReplica(t *testing.T) {
+	interval := changePollInterval
+	changePollInterval = 20 * time.Millisecond
+	defer func() { changePollInterval = interval }()
+
+	changes := &memoryChangeLog{started: make(chan struct{})}
+	changes.store(models.EventChange{Type: models.EventChangeUpdated, EventID: 10})
+
+	hub := NewEventHub()
+	watcher := hub.NewClient(1)
+	_, err := hub.Subscribe(watcher, []int64{10})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Follow(ctx, changes)
+	<-changes.started
+
+	// Stored by another replica, so nothing wakes this hub. The change stored
+	// before Follow started is not sent.
+	remote := changes.store(models.EventChange{Type: models.EventChangeRegistrationCreated, EventID: 10,
+		Counts: &models.AttendeeCounts{Registered: 1}})
+	message := receive(t, watcher)
+	assert.Equal(t, remote.Type, message.Type)
+	assert.Equal(t, int64(1), message.Counts.Registered)
+
+	// Waking for a local change still sends an earlier remote one first
+	changes.store(models.EventChange{Type: models.EventChangeRegistrationCreated, EventID: 10,
+		Counts: &models.AttendeeCounts{Registered: 2}})
+	local := changes.store(models.EventChange{Type: models.EventChangeRegistrationCanceled, EventID: 10,
+		Counts: &models.AttendeeCounts{Registered: 1}})
+	hub.Wake(local)
+
+	assert.Equal(t, int64(2), receive(t, watcher).Counts.Registered)
+	assert.Equal(t, models.EventChangeRegistrationCanceled, receive(t, watcher).Type)
+	assert.Empty(t, watcher.send)
+}
+
+func TestEventHub_SubscriptionLimit(t *testing.T) {
+	hub := NewEventHub()
+	client := hub.NewClient(1)
+
+	ids := make([]int64, maxClientSubscriptions)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+
+	_, err := hub.Subscribe(client, ids)
+	assert.NoError(t, err)
+
+	// Subscribing again to the same events is fine, one more is not
+	_, err = hub.Subscribe(client, ids[:10])
+	assert.NoError(t, err)
+
+	subscribed, err := hub.Subscribe(client, []int64{maxClientSubscriptions + 1})
+	assert.ErrorIs(t, err, ErrTooManySubscriptions)
+	assert.Len(t, subscribed, maxClientSubscriptions)
+}
+
+func TestEventHub_DropsSlowClient(t *testing.T) {
+	hub := NewEventHub()
+	slow := hub.NewClient(1)
+	fast := hub.NewClient(2)
+
+	hub.Subscribe(slow, []int64{10})
+	hub.Subscribe(fast, []int64{10})
+
+	for i := 0; i <= clientBuffer; i++ {
+		hub.PublishChange(models.EventChange{Type: models.EventChangeUpdated, EventID: 10})
+		<-fast.send
+	}
+
+	assert.True(t, slow.wasDropped())
+	assert.False(t, fast.wasDropped())
+	assert.Equal(t, 1, hub.Subscribers(10))
+
+	received := 0
+	for range slow.send {
+		received++
+	}
+	assert.Equal(t, clientBuffer, received)
+}
+
+func TestEventHub_Serve(t *testing.T) {
+	hub := NewEventHub()
+	expiresAt := time.Now().Add(time.Hour)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.Serve(w, r, 1, expiresAt)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	read := func() EventMessage {
+		var message EventMessage
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		assert.NoError(t, conn.ReadJSON(&message))
+		return message
+	}
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+	assert.Equal(t, MessageError, read().Type)
+
+	assert.NoError(t, conn.WriteJSON(command{Action: "watch", EventIDs: []int64{7}}))
+	assert.Equal(t, "action must be subscribe or unsubscribe", read().Message)
+
+	assert.NoError(t, conn.WriteJSON(command{Action: ActionSubscribe, EventIDs: []int64{7, 3}}))
+	message := read()
+	assert.Equal(t, MessageSubscribed, message.Type)
+	assert.Equal(t, []int64{3, 7}, message.EventIDs)
+
+	event := models.Event{ID: 7, Name: "Renamed"}
+	hub.PublishChange(models.EventChange{Type: models.EventChangeUpdated, EventID: 7, Event: &event})
+
+	message = read()
+	assert.Equal(t, models.EventChangeUpdated, message.Type)
+	assert.Equal(t, "Renamed", message.Event.Name)
+
+	assert.NoError(t, conn.WriteJSON(command{Action: ActionUnsubscribe, EventIDs: []int64{3, 7}}))
+	message = read()
+	assert.Equal(t, MessageSubscribed, message.Type)
+	assert.Empty(t, message.EventIDs)
+	assert.Zero(t, hub.Subscribers(7))
+}
+
+func TestEventHub_ServeClosesOnExpiry(t *testing.T) {
+	hub := NewEventHub()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.Serve(w, r, 1, time.Now().Add(100*time.Millisecond))
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+}
+
+func TestEventHub_CloseSaysGoingAway(t *testing.T) {
+	hub := NewEventHub()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.Serve(w, r, 1, time.Now().Add(time.Hour))
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// Wait until the connection is served, so Close sees the client
+	assert.NoError(t, conn.WriteJSON(command{Action: ActionSubscribe, EventIDs: []int64{7}}))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	assert.NoError(t, err)
+
+	hub.Close()
+
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway))
+	assert.Zero(t, hub.Subscribers(7))
+}
+
+func TestEventHub_ServeSelectsTokenProtocol(t *testing.T) {
+	hub := NewEventHub()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "header.payload.signature", ProtocolToken(r))
+		hub.Serve(w, r, 1, time.Now().Add(time.Hour))
+	}))
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{TokenProtocol, "header.payload.signature"}}
+	conn, response, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// Browsers fail the handshake unless one offered protocol is selected,
+	// and the token must not be the one echoed back
+	assert.Equal(t, TokenProtocol, conn.Subprotocol())
+	assert.Equal(t, TokenProtocol, response.Header.Get("Sec-WebSocket-Protocol"))
+}
+
+func TestProtocolToken(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"access_token, abc.def.ghi", "abc.def.ghi"},
+		{"chat, access_token, abc.def.ghi", "abc.def.ghi"},
+		{"access_token", ""},
+		{"abc.def.ghi", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		if tt.header != "" {
+			r.Header.Set("Sec-WebSocket-Protocol", tt.header)
+		}
+
+		assert.Equal(t, tt.want, ProtocolToken(r), tt.header)
+	}
+}
@@ -0,0 +1,114 @@
+// Package realtime pushes newly created notifications to the connected
+// clients of the users they are for.
+package realtime
+
+import (
+	"sync"
+
+	"example.com/rest-api/models"
+)
+
+// subscriptionBuffer is how many notifications may wait for a slow client
+// before it is disconnected
+const subscriptionBuffer = 32
+
+// Notifications is the hub the server publishes new notifications to
+var Notifications = NewHub()
+
+// Hub fans notifications out to per-user subscriptions
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[*Subscription]struct{}
+}
+
+// Subscription receives the notifications of one user on C. C is closed when
+// the subscription is closed, or when the client fell too far behind; it
+// should then reconnect and catch up from the last notification it saw.
+type Subscription struct {
+	UserID int64
+	C      <-chan models.Notification
+
+	ch     chan models.Notification
+	hub    *Hub
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: map[int64]map[*Subscription]struct{}{}}
+}
+
+// Subscribe starts receiving the user's new notifications. Close the
+// subscription when done.
+func (h *Hub) Subscribe(userID int64) *Subscription {
+	ch := make(chan models.Notification, subscriptionBuffer)
+	sub := &Subscription{UserID: userID, C: ch, ch: ch, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = map[*Subscription]struct{}{}
+	}
+	h.subscribers[userID][sub] = struct{}{}
+
+	return sub
+}
+
+// Publish hands the notification to every subscription of its user without
+// blocking. A subscription whose buffer is full is dropped.
+func (h *Hub) Publish(n models.Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers[n.UserID] {
+		select {
+		case sub.ch <- n:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribers returns how many subscriptions the user has open
+func (h *Hub) Subscribers(userID int64) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subscribers[userID])
+}
+
+// Close closes every subscription, which ends the streams reading them; their
+// clients reconnect and catch up
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, subs := range h.subscribers {
+		for sub := range subs {
+			h.remove(sub)
+		}
+	}
+}
+
+// Close stops the subscription and closes C. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s)
+}
+
+// remove must be called with h.mu held
+func (h *Hub) remove(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+
+	sub.closed = true
+	close(sub.ch)
+
+	delete(h.subscribers[sub.UserID], sub)
+	if len(h.subscribers[sub.UserID]) == 0 {
+		delete(h.subscribers, sub.UserID)
+	}
+}
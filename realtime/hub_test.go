@@ -0,0 +1,87 @@
+package realtime
+
+import (
+	"strings"
+	"testing"
+
+	"example.com/rest-api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHub_PublishToUser(t *testing.T) {
+	hub := NewHub()
+
+	first := hub.Subscribe(1)
+	second := hub.Subscribe(1)
+	other := hub.Subscribe(2)
+	assert.Equal(t, 2, hub.Subscribers(1))
+
+	hub.Publish(models.Notification{ID: 10, UserID: 1, Message: "hello"})
+
+	for _, sub := range []*Subscription{first, second} {
+		notification := <-sub.C
+		assert.Equal(t, int64(10), notification.ID)
+	}
+	assert.Empty(t, other.C)
+
+	first.Close()
+	first.Close()
+	_, open := <-first.C
+	assert.False(t, open)
+	assert.Equal(t, 1, hub.Subscribers(1))
+
+	second.Close()
+	other.Close()
+	assert.Zero(t, hub.Subscribers(1))
+	assert.Zero(t, hub.Subscribers(2))
+}
+
+func TestHub_DropsSlowSubscriber(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(1)
+
+	for i := 0; i <= subscriptionBuffer; i++ {
+		hub.Publish(models.Notification{ID: int64(i + 1), UserID: 1})
+	}
+
+	assert.Zero(t, hub.Subscribers(1))
+
+	received := 0
+	for range sub.C {
+		received++
+	}
+	assert.Equal(t, subscriptionBuffer, received)
+
+	// Closing a dropped subscription is harmless
+	sub.Close()
+}
+
+func TestHub_Close(t *testing.T) {
+	hub := NewHub()
+	first, second := hub.Subscribe(1), hub.Subscribe(2)
+
+	hub.Close()
+
+	_, open := <-first.C
+	assert.False(t, open)
+	_, open = <-second.C
+	assert.False(t, open)
+	assert.Zero(t, hub.Subscribers(1))
+
+	// Subscriptions closed by the hub can still be closed by their stream
+	first.Close()
+}
+
+func TestWriteEvent(t *testing.T) {
+	var b strings.Builder
+
+	assert.NoError(t, WriteRetry(&b, 3000))
+	assert.NoError(t, WriteEvent(&b, 7, "notification", models.Notification{ID: 7, UserID: 1, Message: "line one\nline two"}))
+	assert.NoError(t, WriteComment(&b, "heart\nbeat"))
+
+	expected := "retry: 3000\n\n" +
+		"id: 7\nevent: notification\n" +
+		`data: {"id":7,"user_id":1,"event_id":0,"message":"line one\nline two","type":"","is_read":false,"created_at":"0001-01-01T00:00:00Z"}` + "\n\n" +
+		": heart beat\n\n"
+	assert.Equal(t, expected, b.String())
+}
@@ -0,0 +1,191 @@
+package realtime
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait is how long a write to the client may take
+	writeWait = 10 * time.Second
+	// pongWait is how long the client may stay silent, pongs included
+	pongWait = 60 * time.Second
+	// pingPeriod must be shorter than pongWait so pongs arrive in time
+	pingPeriod = pongWait * 9 / 10
+	// maxCommandSize bounds a message from the client
+	maxCommandSize = 4096
+)
+
+// Client commands
+const (
+	ActionSubscribe   = "subscribe"
+	ActionUnsubscribe = "unsubscribe"
+)
+
+// command is a message from the client
+type command struct {
+	Action   string  `json:"action"`
+	EventIDs []int64 `json:"event_ids"`
+}
+
+// TokenProtocol is the WebSocket subprotocol browsers offer before their
+// access token, as in new WebSocket(url, ["access_token", token]). The token
+// travels in the Sec-WebSocket-Protocol header, where it stays out of URLs
+// and access logs.
+const TokenProtocol = "access_token"
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Selected for clients that offered a token; the token itself is never
+	// echoed back
+	Subprotocols: []string{TokenProtocol},
+	// Clients authenticate with a token rather than a cookie, so another
+	// site cannot open a socket on a user's behalf and any origin is allowed
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// ProtocolToken returns the access token offered after TokenProtocol in the
+// request's WebSocket subprotocols, or "" if there is none
+func ProtocolToken(r *http.Request) string {
+	protocols := websocket.Subprotocols(r)
+
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == TokenProtocol {
+			return protocols[i+1]
+		}
+	}
+
+	return ""
+}
+
+// Serve upgrades the request to a WebSocket and serves the user's event
+// subscriptions until the client disconnects or its token expires at
+// expiresAt. On failure Upgrade has already written the HTTP error.
+func (h *EventHub) Serve(w http.ResponseWriter, r *http.Request, userID int64, expiresAt time.Time) error {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return err
+	}
+
+	client := h.NewClient(userID)
+
+	go client.writePump(conn, expiresAt)
+	client.readPump(conn)
+
+	return nil
+}
+
+// readPump handles commands until the connection fails, then closes the
+// client, which stops writePump
+func (c *Client) readPump(conn *websocket.Conn) {
+	defer c.Close()
+
+	conn.SetReadLimit(maxCommandSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var cmd command
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			c.reply(EventMessage{Type: MessageError, Message: "commands must be JSON objects"})
+			continue
+		}
+
+		c.handle(cmd)
+	}
+}
+
+func (c *Client) handle(cmd command) {
+	for _, id := range cmd.EventIDs {
+		if id <= 0 {
+			c.reply(EventMessage{Type: MessageError, Message: "event_ids must be positive event ids"})
+			return
+		}
+	}
+
+	var subscribed []int64
+	var err error
+
+	switch cmd.Action {
+	case ActionSubscribe:
+		subscribed, err = c.hub.Subscribe(c, cmd.EventIDs)
+	case ActionUnsubscribe:
+		subscribed = c.hub.Unsubscribe(c, cmd.EventIDs)
+	default:
+		c.reply(EventMessage{Type: MessageError, Message: "action must be subscribe or unsubscribe"})
+		return
+	}
+
+	if err != nil {
+		c.reply(EventMessage{Type: MessageError, Message: err.Error()})
+		return
+	}
+
+	sort.Slice(subscribed, func(i, j int) bool { return subscribed[i] < subscribed[j] })
+
+	// An empty list still says the client watches nothing
+	if subscribed == nil {
+		subscribed = []int64{}
+	}
+
+	c.reply(EventMessage{Type: MessageSubscribed, EventIDs: subscribed})
+}
+
+// writePump sends queued messages and pings. It closes the connection when
+// the queue is closed, a write fails or the token expires.
+func (c *Client) writePump(conn *websocket.Conn, expiresAt time.Time) {
+	ping := time.NewTicker(pingPeriod)
+	expiry := time.NewTimer(time.Until(expiresAt))
+
+	defer func() {
+		ping.Stop()
+		expiry.Stop()
+		conn.Close()
+	}()
+
+	closeWith := func(code int, reason string) {
+		message := websocket.FormatCloseMessage(code, reason)
+		conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+	}
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				if c.wasDropped() {
+					closeWith(websocket.CloseTryAgainLater, "client too slow")
+				} else if c.wentAway() {
+					closeWith(websocket.CloseGoingAway, "server shutting down")
+				} else {
+					closeWith(websocket.CloseNormalClosure, "")
+				}
+				return
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-expiry.C:
+			closeWith(websocket.ClosePolicyViolation, "token expired")
+			return
+		}
+	}
+}
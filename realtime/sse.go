@@ -0,0 +1,33 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteEvent writes one Server-Sent Event with the given id and event name
+// and data encoded as JSON
+func WriteEvent(w io.Writer, id int64, event string, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, encoded)
+	return err
+}
+
+// WriteComment writes an SSE comment line, which clients ignore. Sent
+// periodically it keeps idle connections from being closed by proxies.
+func WriteComment(w io.Writer, comment string) error {
+	_, err := io.WriteString(w, ": "+strings.ReplaceAll(comment, "\n", " ")+"\n\n")
+	return err
+}
+
+// WriteRetry tells the client how long to wait before reconnecting
+func WriteRetry(w io.Writer, milliseconds int) error {
+	_, err := fmt.Fprintf(w, "retry: %d\n\n", milliseconds)
+	return err
+}
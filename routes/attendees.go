@@ -0,0 +1,143 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func getAttendees(context *gin.Context) {
+	event, ok := eventFromParam(context)
+	if !ok {
+		return
+	}
+
+	if !requireEventAccess(context, event, models.EventAccessOrganizer, "view attendees of") {
+		return
+	}
+
+	query, err := parseAttendeeQuery(context, event.ID)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	page, err := repositories(context).Attendees.List(context.Request.Context(), query)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch attendees"})
+		return
+	}
+	context.JSON(http.StatusOK, page)
+}
+
+// exportAttendees serves the whole attendee list as a CSV or JSON download
+func exportAttendees(context *gin.Context) {
+	event, ok := eventFromParam(context)
+	if !ok {
+		return
+	}
+
+	if !requireEventAccess(context, event, models.EventAccessOrganizer, "view attendees of") {
+		return
+	}
+
+	format := context.DefaultQuery("format", "csv")
+
+	if format != "csv" && format != "json" {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "format must be csv or json"})
+		return
+	}
+
+	query, err := parseAttendeeQuery(context, event.ID)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	attendees, err := repositories(context).Attendees.All(context.Request.Context(), query)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch attendees"})
+		return
+	}
+
+	filename := fmt.Sprintf("event-%d-attendees.%s", event.ID, format)
+	disposition := `attachment; filename="` + filename + `"`
+
+	if format == "json" {
+		context.Header("Content-Disposition", disposition)
+		context.JSON(http.StatusOK, attendees)
+		return
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write([]string{"user_id", "email", "occurrence", "status", "registered_at"})
+
+	for _, attendee := range attendees {
+		writer.Write([]string{
+			strconv.FormatInt(attendee.UserID, 10),
+			csvCell(attendee.Email),
+			csvCell(attendee.Occurrence),
+			csvCell(attendee.Status),
+			attendee.RegisteredAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not export attendees"})
+		return
+	}
+
+	context.Header("Content-Disposition", disposition)
+	context.Data(http.StatusOK, "text/csv; charset=utf-8", buffer.Bytes())
+}
+
+// csvCell quotes a value that a spreadsheet would otherwise run as a formula,
+// such as an email address a user signed up with as "=HYPERLINK(...)"
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// parseAttendeeQuery reads the attendee filters from the query string
+func parseAttendeeQuery(context *gin.Context, eventID int64) (models.AttendeeQuery, error) {
+	query := models.AttendeeQuery{
+		EventID: eventID,
+		Status:  context.Query("status"),
+		Cursor:  context.Query("cursor"),
+	}
+
+	if occurrence, ok := context.GetQuery("occurrence"); ok {
+		query.Occurrence = &occurrence
+	}
+
+	if value := context.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return query, errors.New("limit must be a positive number")
+		}
+		query.Limit = limit
+	}
+
+	return query, query.Normalize()
+}
@@ -0,0 +1,178 @@
+package routes
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/rest-api/config"
+	"example.com/rest-api/db"
+	"example.com/rest-api/models"
+	"example.com/rest-api/test"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// attendeeFixture is an event with a co-organizer and three attendees, one of
+// whom signed up with a formula for an email address
+type attendeeFixture struct {
+	server *gin.Engine
+	event  models.Event
+	tokens map[string]string // by role in the fixture
+}
+
+func newAttendeeFixture(t *testing.T) attendeeFixture {
+	gin.SetMode(gin.TestMode)
+
+	fixture := attendeeFixture{server: gin.New(), tokens: map[string]string{}}
+	repos := models.NewSQLRepositories(db.DB, db.Dialect)
+	RegisterRoutes(fixture.server, config.Default(), repos)
+
+	users := map[string]*models.User{}
+
+	for _, name := range []string{"owner", "organizer", "stranger", "first", "second", "formula"} {
+		email := name + "@example.com"
+		if name == "formula" {
+			email = `=HYPERLINK("https://evil.example.com","click")`
+		}
+
+		user := &models.User{Email: email, Password: "hashed"}
+		assert.NoError(t, repos.Users.Save(context.Background(), user))
+		users[name] = user
+
+		tokens, err := repos.Tokens.Issue(context.Background(), user)
+		assert.NoError(t, err)
+		fixture.tokens[name] = tokens.Token
+	}
+
+	fixture.event = models.Event{Name: "Launch", Description: "Product launch", Location: "Dhaka",
+		DateTime: time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second), UserID: users["owner"].ID}
+	assert.NoError(t, repos.Events.Save(context.Background(), &fixture.event))
+	assert.NoError(t, repos.Events.AddOrganizer(context.Background(), &fixture.event, users["organizer"].ID))
+
+	for _, name := range []string{"first", "second", "formula"} {
+		registration := models.EventRegister{EventID: fixture.event.ID, UserID: users[name].ID}
+		assert.NoError(t, repos.Registrations.Register(context.Background(), &registration))
+	}
+
+	return fixture
+}
+
+func (f attendeeFixture) get(role, path string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.Header.Set("Authorization", f.tokens[role])
+
+	recorder := httptest.NewRecorder()
+	f.server.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func (f attendeeFixture) path(suffix string) string {
+	return "/events/" + strconv.FormatInt(f.event.ID, 10) + "/attendees" + suffix
+}
+
+func TestGetAttendees(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	fixture := newAttendeeFixture(t)
+
+	tests := []struct {
+		role       string
+		wantStatus int
+	}{
+		{"owner", http.StatusOK},
+		{"organizer", http.StatusOK},
+		{"stranger", http.StatusUnauthorized},
+		{"first", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			assert.Equal(t, tt.wantStatus, fixture.get(tt.role, fixture.path("")).Code)
+			assert.Equal(t, tt.wantStatus, fixture.get(tt.role, fixture.path("/export")).Code)
+		})
+	}
+
+	recorder := fixture.get("organizer", fixture.path("?limit=2"))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var first models.AttendeePage
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &first))
+	assert.Equal(t, int64(3), first.Total)
+	assert.Len(t, first.Attendees, 2)
+	assert.Equal(t, "first@example.com", first.Attendees[0].Email)
+	assert.NotEmpty(t, first.NextCursor)
+
+	recorder = fixture.get("organizer", fixture.path("?limit=2&cursor="+first.NextCursor))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var second models.AttendeePage
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &second))
+	assert.Len(t, second.Attendees, 1)
+	assert.Empty(t, second.NextCursor)
+
+	for _, query := range []string{"?limit=0", "?status=maybe", "?cursor=bogus"} {
+		assert.Equal(t, http.StatusBadRequest, fixture.get("owner", fixture.path(query)).Code, query)
+	}
+
+	for _, path := range []string{"/events/999999/attendees", "/events/999999/attendees/export", "/events/999999/organizers"} {
+		assert.Equal(t, http.StatusNotFound, fixture.get("owner", path).Code, path)
+	}
+}
+
+func TestExportAttendees(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	fixture := newAttendeeFixture(t)
+
+	recorder := fixture.get("owner", fixture.path("/export"))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Header().Get("Content-Disposition"), ".csv")
+
+	records, err := csv.NewReader(recorder.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 4)
+	assert.Equal(t, []string{"user_id", "email", "occurrence", "status", "registered_at"}, records[0])
+	assert.Equal(t, "first@example.com", records[1][1])
+
+	// The formula is quoted so spreadsheets show it as text
+	assert.Equal(t, `'=HYPERLINK("https://evil.example.com","click")`, records[3][1])
+
+	recorder = fixture.get("organizer", fixture.path("/export?format=json"))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Disposition"), ".json")
+
+	var attendees []models.Attendee
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &attendees))
+	assert.Len(t, attendees, 3)
+	assert.True(t, strings.HasPrefix(attendees[2].Email, "=HYPERLINK"))
+
+	assert.Equal(t, http.StatusBadRequest, fixture.get("owner", fixture.path("/export?format=xml")).Code)
+}
+
+func TestCSVCell(t *testing.T) {
+	for value, want := range map[string]string{
+		"ada@example.com": "ada@example.com",
+		"":                "",
+		"=1+1":            "'=1+1",
+		"+1":              "'+1",
+		"-1":              "'-1",
+		"@SUM(A1)":        "'@SUM(A1)",
+		"\tcmd":           "'\tcmd",
+		"\rcmd":           "'\rcmd",
+	} {
+		assert.Equal(t, want, csvCell(value), value)
+	}
+}
@@ -0,0 +1,100 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"example.com/rest-api/ical"
+	"github.com/gin-gonic/gin"
+)
+
+func getEventICS(context *gin.Context) {
+	ctx := context.Request.Context()
+
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id"})
+		return
+	}
+
+	event, err := repositories(context).Events.GetByID(ctx, eventId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Event not found"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event"})
+		return
+	}
+
+	calendar, err := repositories(context).Events.Calendar(ctx, event)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not export event"})
+		return
+	}
+
+	context.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="event-%d.ics"`, event.ID))
+	writeCalendar(context, calendar)
+}
+
+// getCalendarFeed serves the user's registrations to calendar apps, which
+// authenticate with the token in the URL rather than a JWT header
+func getCalendarFeed(context *gin.Context) {
+	ctx := context.Request.Context()
+
+	user, err := repositories(context).Tokens.UserByCalendarToken(ctx, context.Query("token"))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid calendar token"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch calendar"})
+		return
+	}
+
+	calendar, err := repositories(context).Users.Calendar(ctx, user)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch calendar"})
+		return
+	}
+
+	writeCalendar(context, calendar)
+}
+
+func createCalendarToken(context *gin.Context) {
+	token, err := repositories(context).Tokens.NewCalendarToken(context.Request.Context(), context.GetInt64("userId"))
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create calendar token"})
+		return
+	}
+
+	scheme := "http"
+	if context.Request.TLS != nil || context.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	feed := url.URL{
+		Scheme:   scheme,
+		Host:     context.Request.Host,
+		Path:     "/users/me/calendar.ics",
+		RawQuery: url.Values{"token": {token}}.Encode(),
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Calendar token created", "token": token, "url": feed.String()})
+}
+
+func writeCalendar(context *gin.Context, calendar *ical.Calendar) {
+	context.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(calendar.String()))
+}
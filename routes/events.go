@@ -1,21 +1,116 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"example.com/rest-api/middlewares"
 	"example.com/rest-api/models"
 	"github.com/gin-gonic/gin"
 )
 
 func getEvents(context *gin.Context) {
-	events, err := models.GetAllEvents()
+	query, err := parseEventQuery(context)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if query.Expand {
+		getOccurrences(context, query)
+		return
+	}
+
+	page, err := repositories(context).Events.List(context.Request.Context(), query)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid cursor"})
+		return
+	}
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch events"})
 		return
 	}
-	context.JSON(http.StatusOK, events)
+	context.JSON(http.StatusOK, page)
+}
+
+func searchEvents(context *gin.Context) {
+	filters, err := parseEventQuery(context)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	offset := 0
+	if value := context.Query("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			context.JSON(http.StatusBadRequest, gin.H{"message": "offset must be a non-negative number"})
+			return
+		}
+	}
+
+	page, err := repositories(context).Events.Search(context.Request.Context(), context.Query("q"), filters, offset)
+	if errors.Is(err, models.ErrEmptySearch) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not search events"})
+		return
+	}
+	context.JSON(http.StatusOK, page)
+}
+
+// parseEventQuery reads the listing filters from the query string
+func parseEventQuery(context *gin.Context) (models.EventQuery, error) {
+	query := models.EventQuery{
+		Location: context.Query("location"),
+		Name:     context.Query("name"),
+		Sort:     context.Query("sort"),
+		Cursor:   context.Query("cursor"),
+	}
+
+	for param, target := range map[string]**time.Time{"from": &query.From, "to": &query.To} {
+		value := context.Query(param)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return query, fmt.Errorf("%s must be an RFC 3339 timestamp", param)
+		}
+		*target = &parsed
+	}
+
+	if value := context.Query("user_id"); value != "" {
+		userId, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return query, errors.New("user_id must be a number")
+		}
+		query.UserID = userId
+	}
+
+	if value := context.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return query, errors.New("limit must be a positive number")
+		}
+		query.Limit = limit
+	}
+
+	if value := context.Query("expand"); value != "" {
+		expand, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, errors.New("expand must be true or false")
+		}
+		query.Expand = expand
+	}
+
+	return query, query.Normalize()
 }
 
 func getSingleEvent(context *gin.Context) {
@@ -28,7 +123,7 @@ func getSingleEvent(context *gin.Context) {
 		return
 	}
 
-	event, err := models.GetEventById(id)
+	event, err := repositories(context).Events.GetByID(context.Request.Context(), id)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not find event"})
@@ -39,6 +134,7 @@ func getSingleEvent(context *gin.Context) {
 }
 
 func createEvent(context *gin.Context) {
+	ctx := context.Request.Context()
 
 	var event models.Event
 	err := context.ShouldBindJSON(&event)
@@ -51,7 +147,21 @@ func createEvent(context *gin.Context) {
 
 	event.UserID = context.GetInt64("userId")
 
-	err = event.Save()
+	err = event.NormalizeRRule()
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	err = event.NormalizeTimezone()
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	err = repositories(context).Events.Save(ctx, &event)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create event"})
@@ -63,6 +173,7 @@ func createEvent(context *gin.Context) {
 }
 
 func updateEvent(context *gin.Context) {
+	ctx := context.Request.Context()
 
 	eventId := context.Param("id")
 
@@ -73,20 +184,19 @@ func updateEvent(context *gin.Context) {
 		return
 	}
 
-	event, err := models.GetEventById(id)
+	event, err := repositories(context).Events.GetByID(ctx, id)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not find event"})
 		return
 	}
 
-	userId := context.GetInt64("userId")
-
-	if event.UserID != userId {
-		context.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized to update event"})
+	if !requireEventAccess(context, event, models.EventAccessOrganizer, "update") {
 		return
 	}
 
+	ownerId := event.UserID
+
 	err = context.ShouldBindJSON(&event)
 
 	if err != nil {
@@ -95,9 +205,25 @@ func updateEvent(context *gin.Context) {
 		return
 	}
 
+	// Co-organizers and admins editing the event do not take it over
 	event.ID = id
+	event.UserID = ownerId
+
+	err = event.NormalizeRRule()
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
 
-	err = event.Update()
+	err = event.NormalizeTimezone()
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	err = repositories(context).Events.Update(ctx, event)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not update event", "error": err})
@@ -109,6 +235,7 @@ func updateEvent(context *gin.Context) {
 }
 
 func deleteEvent(context *gin.Context) {
+	ctx := context.Request.Context()
 
 	eventId := context.Param("id")
 
@@ -119,14 +246,18 @@ func deleteEvent(context *gin.Context) {
 		return
 	}
 
-	event, err := models.GetEventById(id)
+	event, err := repositories(context).Events.GetByID(ctx, id)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not find event"})
 		return
 	}
 
-	err = event.Delete()
+	if !requireEventAccess(context, event, models.EventAccessOwner, "delete") {
+		return
+	}
+
+	err = repositories(context).Events.Delete(ctx, event)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete event"})
@@ -137,3 +268,23 @@ func deleteEvent(context *gin.Context) {
 	context.JSON(http.StatusOK, gin.H{"message": "event deleted"})
 
 }
+
+// requireEventAccess checks the caller has at least the given access to the
+// event. Co-organizers may edit, only the owner or an admin may delete or
+// transfer. It writes the error response itself and returns false when the
+// request should stop.
+func requireEventAccess(context *gin.Context, event *models.Event, minimum models.EventAccess, action string) bool {
+	access, err := repositories(context).Events.Access(context.Request.Context(), event, context.GetInt64("userId"), middlewares.CurrentRole(context))
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not check event access"})
+		return false
+	}
+
+	if access < minimum {
+		context.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized to " + action + " event"})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/rest-api/config"
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubEvents serves events from memory
+type stubEvents struct {
+	models.EventRepository
+	events map[int64]models.Event
+}
+
+func (s stubEvents) GetByID(_ context.Context, id int64) (*models.Event, error) {
+	event, ok := s.events[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &event, nil
+}
+
+func TestGetSingleEvent_UsesTheGivenRepository(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := gin.New()
+	RegisterRoutes(server, config.Default(), models.Repositories{
+		Events: stubEvents{events: map[int64]models.Event{7: {ID: 7, Name: "From the stub"}}},
+	})
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/events/7", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var event models.Event
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &event))
+	assert.Equal(t, "From the stub", event.Name)
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/events/8", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+var jobStatuses = map[string]bool{
+	models.JobStatusQueued:  true,
+	models.JobStatusRunning: true,
+	models.JobStatusDone:    true,
+	models.JobStatusDead:    true,
+}
+
+// getJobs lists background jobs by status, dead jobs by default
+func getJobs(context *gin.Context) {
+	status := context.DefaultQuery("status", models.JobStatusDead)
+
+	if !jobStatuses[status] {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "status must be queued, running, done or dead"})
+		return
+	}
+
+	var err error
+	limit := 0
+	if value := context.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > 100 {
+			context.JSON(http.StatusBadRequest, gin.H{"message": "limit must be between 1 and 100"})
+			return
+		}
+	}
+
+	jobs, err := repositories(context).Jobs.List(context.Request.Context(), status, limit)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch jobs"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// retryJob queues a dead job again
+func retryJob(context *gin.Context) {
+	jobID, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse job id"})
+		return
+	}
+
+	err = repositories(context).Jobs.Retry(context.Request.Context(), jobID)
+
+	if errors.Is(err, models.ErrJobNotFound) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "No dead job with this id"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not retry job"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Job queued for retry"})
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func getMyEvents(context *gin.Context) {
+	query, err := parseUserListQuery(context)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	page, err := repositories(context).Users.ListEvents(context.Request.Context(), query)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch events"})
+		return
+	}
+	context.JSON(http.StatusOK, page)
+}
+
+func getMyRegistrations(context *gin.Context) {
+	query, err := parseUserListQuery(context)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	page, err := repositories(context).Users.ListRegistrations(context.Request.Context(), query)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch registrations"})
+		return
+	}
+	context.JSON(http.StatusOK, page)
+}
+
+// parseUserListQuery reads the dashboard filters from the query string
+func parseUserListQuery(context *gin.Context) (models.UserListQuery, error) {
+	query := models.UserListQuery{
+		UserID: context.GetInt64("userId"),
+		When:   context.Query("when"),
+		Status: context.Query("status"),
+		Cursor: context.Query("cursor"),
+	}
+
+	if value := context.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return query, errors.New("limit must be a positive number")
+		}
+		query.Limit = limit
+	}
+
+	return query, query.Normalize()
+}
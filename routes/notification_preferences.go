@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"net/http"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func getNotificationPreferences(context *gin.Context) {
+	preferences, err := repositories(context).Notifications.Preferences(context.Request.Context(), context.GetInt64("userId"))
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch notification preferences"})
+		return
+	}
+
+	context.JSON(http.StatusOK, preferences)
+}
+
+// updateNotificationPreferences changes the toggles given in the body and
+// replaces the quiet hours
+func updateNotificationPreferences(context *gin.Context) {
+	ctx := context.Request.Context()
+
+	var changes models.NotificationPreferences
+	err := context.ShouldBindJSON(&changes)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse preferences; times are written as HH:MM"})
+		return
+	}
+
+	userId := context.GetInt64("userId")
+	preferences, err := repositories(context).Notifications.Preferences(ctx, userId)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch notification preferences"})
+		return
+	}
+
+	// Merge only fails on invalid input
+	err = preferences.Merge(changes)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	err = repositories(context).Notifications.SavePreferences(ctx, preferences)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save notification preferences"})
+		return
+	}
+
+	context.JSON(http.StatusOK, preferences)
+}
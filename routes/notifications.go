@@ -1,15 +1,31 @@
 package routes
 
 import (
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"example.com/rest-api/jobs"
 	"example.com/rest-api/models"
+	"example.com/rest-api/realtime"
 	"github.com/gin-gonic/gin"
 )
 
+// streamHeartbeat is how often an idle stream sends a comment so proxies keep
+// the connection open, and looks for notifications stored by other instances
+var streamHeartbeat = 15 * time.Second
+
+const (
+	// streamRetry is how long clients wait before reconnecting, in milliseconds
+	streamRetry = 3000
+	// streamCatchUpBatch is how many missed notifications are loaded at a time
+	streamCatchUpBatch = 100
+)
+
 func getNotifications(context *gin.Context) {
+	ctx := context.Request.Context()
+
 	userID, exists := context.Get("userId")
 	if !exists {
 		context.JSON(http.StatusUnauthorized, gin.H{"message": "Not authorized"})
@@ -22,16 +38,161 @@ func getNotifications(context *gin.Context) {
 		return
 	}
 
-	notifications, err := models.GetNotificationsByUserID(userId)
+	notifications, err := repositories(context).Notifications.GetByUserID(ctx, userId)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch notifications"})
+		return
+	}
+
+	settings, err := repositories(context).Users.GetSettings(ctx, userId)
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch notifications"})
 		return
 	}
 
+	for i := range notifications {
+		notifications[i].Localize(settings)
+	}
+
 	context.JSON(http.StatusOK, notifications)
 }
 
+// streamNotifications pushes the user's new notifications as Server-Sent
+// Events. A reconnecting client sends the id of the last event it received
+// in Last-Event-ID (or the last_event_id query parameter) and first gets
+// every notification it missed. Notifications are written in the user's
+// locale and timezone as of connecting. Those stored by this process are
+// pushed at once, those stored by other instances with the next heartbeat.
+func streamNotifications(context *gin.Context) {
+	ctx := context.Request.Context()
+
+	userId := context.GetInt64("userId")
+
+	resume := context.GetHeader("Last-Event-ID")
+	if resume == "" {
+		resume = context.Query("last_event_id")
+	}
+
+	var lastEventId int64
+	if resume != "" {
+		parsed, err := strconv.ParseInt(resume, 10, 64)
+		if err != nil || parsed < 0 {
+			context.JSON(http.StatusBadRequest, gin.H{"message": "Last-Event-ID must be a notification id"})
+			return
+		}
+		lastEventId = parsed
+	}
+
+	notifications := repositories(context).Notifications
+
+	settings, err := repositories(context).Users.GetSettings(ctx, userId)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch settings"})
+		return
+	}
+
+	// Without a Last-Event-ID the stream starts with what is stored next
+	if resume == "" {
+		lastEventId, err = notifications.LastID(ctx, userId)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch notifications"})
+			return
+		}
+	}
+
+	// Subscribed after reading the starting point, so anything stored in
+	// between comes through the subscription or the next poll
+	subscription := realtime.Notifications.Subscribe(userId)
+	defer subscription.Close()
+
+	header := context.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	context.Status(http.StatusOK)
+
+	w := context.Writer
+	if err := realtime.WriteRetry(w, streamRetry); err != nil {
+		return
+	}
+
+	// Every notification up to polled has been sent. Notifications go out
+	// only through poll, so the client gets them strictly in id order.
+	polled := lastEventId
+
+	send := func(notification models.Notification) error {
+		notification.Localize(settings)
+		return realtime.WriteEvent(w, notification.ID, "notification", notification)
+	}
+
+	// poll sends what was stored after polled. A push from the hub only
+	// wakes it up early; notifications stored by other instances never reach
+	// the hub and arrive with the next heartbeat.
+	poll := func() error {
+		for {
+			stored, err := notifications.GetAfter(ctx, userId, polled, streamCatchUpBatch)
+			if err != nil {
+				log.Printf("Error loading notifications for user %d: %v", userId, err)
+				return err
+			}
+
+			for _, notification := range stored {
+				if err := send(notification); err != nil {
+					return err
+				}
+				polled = notification.ID
+			}
+
+			if len(stored) < streamCatchUpBatch {
+				return nil
+			}
+		}
+	}
+
+	if resume != "" {
+		if err := poll(); err != nil {
+			return
+		}
+	}
+
+	w.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification, ok := <-subscription.C:
+			// A closed subscription means the client fell behind; it
+			// reconnects and catches up from its Last-Event-ID
+			if !ok {
+				return
+			}
+			if notification.ID <= polled {
+				continue
+			}
+			if err := poll(); err != nil {
+				return
+			}
+			w.Flush()
+		case <-heartbeat.C:
+			if err := poll(); err != nil {
+				return
+			}
+			if err := realtime.WriteComment(w, "heartbeat"); err != nil {
+				return
+			}
+			w.Flush()
+		}
+	}
+}
+
 func markNotificationAsRead(context *gin.Context) {
+	ctx := context.Request.Context()
+
 	notificationIDStr := context.Param("id")
 	notificationID, err := strconv.ParseInt(notificationIDStr, 10, 64)
 	if err != nil {
@@ -46,7 +207,7 @@ func markNotificationAsRead(context *gin.Context) {
 	}
 
 	// Additional security: Verify the notification belongs to the user
-	notifications, err := models.GetNotificationsByUserID(userID.(int64))
+	notifications, err := repositories(context).Notifications.GetByUserID(ctx, userID.(int64))
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not verify notification ownership"})
 		return
@@ -65,7 +226,7 @@ func markNotificationAsRead(context *gin.Context) {
 		return
 	}
 
-	err = models.MarkNotificationAsRead(notificationID)
+	err = repositories(context).Notifications.MarkAsRead(ctx, notificationID)
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not mark notification as read"})
 		return
@@ -74,12 +235,16 @@ func markNotificationAsRead(context *gin.Context) {
 	context.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
 }
 
+// triggerNotificationCheck queues a reminder run and an email run ahead of
+// their schedules
 func triggerNotificationCheck(context *gin.Context) {
-	notificationService := jobs.NewNotificationService()
-	err := notificationService.ProcessManually()
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not process notifications"})
-		return
+	for _, jobType := range []string{jobs.JobSendReminders, jobs.JobSendEmails} {
+		_, err := repositories(context).Jobs.Enqueue(context.Request.Context(), jobType, nil, time.Now())
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not process notifications"})
+			return
+		}
 	}
 
 	context.JSON(http.StatusOK, gin.H{"message": "Notification check triggered successfully"})
@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/rest-api/config"
+	"example.com/rest-api/db"
+	"example.com/rest-api/models"
+	"example.com/rest-api/realtime"
+	"example.com/rest-api/test"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamNotifications_DeliversNotificationsFromOtherInstances(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	heartbeat := streamHeartbeat
+	streamHeartbeat = 20 * time.Millisecond
+	defer func() { streamHeartbeat = heartbeat }()
+
+	gin.SetMode(gin.TestMode)
+	repos := models.NewSQLRepositories(db.DB, db.Dialect)
+
+	router := gin.New()
+	RegisterRoutes(router, config.Default(), repos)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	user := models.User{Email: "stream@example.com", Password: "hashed"}
+	assert.NoError(t, repos.Users.Save(context.Background(), &user))
+
+	tokens, err := repos.Tokens.Issue(context.Background(), &user)
+	assert.NoError(t, err)
+
+	event := models.Event{Name: "Launch", Description: "Product launch", Location: "Dhaka",
+		DateTime: time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second), UserID: user.ID}
+	assert.NoError(t, repos.Events.Save(context.Background(), &event))
+
+	notify := func() models.Notification {
+		notification := models.Notification{UserID: user.ID, EventID: event.ID, Message: "Hello",
+			Type: models.NotificationTypeUpcomingEvent, CreatedAt: time.Now()}
+		assert.NoError(t, repos.Notifications.Save(context.Background(), &notification))
+		return notification
+	}
+
+	// Stored before connecting, so not sent without a Last-Event-ID
+	notify()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/notifications/stream", nil)
+	assert.NoError(t, err)
+	request.Header.Set("Authorization", tokens.Token)
+
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	ids := make(chan string, 10)
+	go func() {
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+				ids <- id
+			}
+		}
+	}()
+
+	next := func() string {
+		select {
+		case id := <-ids:
+			return id
+		case <-time.After(2 * time.Second):
+			t.Fatal("no notification received")
+			return ""
+		}
+	}
+
+	// Nothing publishes to this process's hub, as if another instance stored it
+	remote := notify()
+	assert.Equal(t, strconv.FormatInt(remote.ID, 10), next())
+
+	// A push for a later notification still sends the earlier one first, and
+	// the next poll does not send the pushed one again
+	earlier := notify()
+	local := notify()
+	realtime.Notifications.Publish(local)
+	assert.Equal(t, strconv.FormatInt(earlier.ID, 10), next())
+	assert.Equal(t, strconv.FormatInt(local.ID, 10), next())
+
+	last := notify()
+	assert.Equal(t, strconv.FormatInt(last.ID, 10), next())
+}
@@ -0,0 +1,167 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// getOccurrences serves GET /events?expand=true
+func getOccurrences(context *gin.Context, query models.EventQuery) {
+	page, err := repositories(context).Events.ListOccurrences(context.Request.Context(), query)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch events"})
+		return
+	}
+	context.JSON(http.StatusOK, page)
+}
+
+func getEventOccurrences(context *gin.Context) {
+	ctx := context.Request.Context()
+
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id"})
+		return
+	}
+
+	var window [2]time.Time
+
+	for i, param := range []string{"from", "to"} {
+		window[i], err = time.Parse(time.RFC3339, context.Query(param))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"message": param + " must be an RFC 3339 timestamp"})
+			return
+		}
+	}
+
+	from, to := window[0], window[1]
+
+	if !to.After(from) || to.Sub(from) > models.MaxOccurrenceWindow {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "to must be after from and at most 366 days later"})
+		return
+	}
+
+	event, err := repositories(context).Events.GetByID(ctx, eventId)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event"})
+		return
+	}
+
+	occurrences, err := repositories(context).Events.Occurrences(ctx, event, from, to)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not expand event"})
+		return
+	}
+
+	if occurrences == nil {
+		occurrences = []models.Occurrence{}
+	}
+
+	context.JSON(http.StatusOK, gin.H{"occurrences": occurrences})
+}
+
+// occurrenceChange is the body of PUT /events/:id/occurrences/:occurrence.
+// Exactly one of Canceled or DateTime must be given.
+type occurrenceChange struct {
+	Canceled bool
+	DateTime *time.Time
+}
+
+func updateOccurrence(context *gin.Context) {
+	event, ok := ownEventForOccurrence(context)
+	if !ok {
+		return
+	}
+
+	var change occurrenceChange
+	err := context.ShouldBindJSON(&change)
+
+	if err != nil || change.Canceled == (change.DateTime != nil) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Provide either Canceled: true or a new DateTime"})
+		return
+	}
+
+	exception := models.EventException{
+		EventID:    event.ID,
+		Occurrence: context.Param("occurrence"),
+		Canceled:   change.Canceled,
+	}
+
+	if change.DateTime != nil {
+		newStart := change.DateTime.UTC()
+		exception.NewStart = &newStart
+	}
+
+	err = repositories(context).Events.SaveException(context.Request.Context(), &exception)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not update occurrence"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "occurrence updated", "exception": exception})
+}
+
+func restoreOccurrence(context *gin.Context) {
+	event, ok := ownEventForOccurrence(context)
+	if !ok {
+		return
+	}
+
+	err := repositories(context).Events.DeleteException(context.Request.Context(), event.ID, context.Param("occurrence"))
+
+	if errors.Is(err, models.ErrExceptionNotFound) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Occurrence has not been changed"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not restore occurrence"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "occurrence restored"})
+}
+
+// ownEventForOccurrence loads the event of an occurrence route, checking the
+// caller may manage it and the occurrence belongs to the series. It writes the error
+// response itself and returns false when the request should stop.
+func ownEventForOccurrence(context *gin.Context) (*models.Event, bool) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id"})
+		return nil, false
+	}
+
+	event, err := repositories(context).Events.GetByID(context.Request.Context(), eventId)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not find event"})
+		return nil, false
+	}
+
+	if !requireEventAccess(context, event, models.EventAccessOrganizer, "update") {
+		return nil, false
+	}
+
+	if event.RRule == "" || !event.ValidOccurrence(context.Param("occurrence")) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid occurrence for event"})
+		return nil, false
+	}
+
+	return event, true
+}
@@ -0,0 +1,174 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+type organizerRequest struct {
+	UserID int64 `json:"user_id" binding:"required"`
+}
+
+func getOrganizers(context *gin.Context) {
+	event, ok := eventFromParam(context)
+	if !ok {
+		return
+	}
+
+	if !requireEventAccess(context, event, models.EventAccessOrganizer, "view organizers of") {
+		return
+	}
+
+	organizers, err := repositories(context).Events.Organizers(context.Request.Context(), event.ID)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch organizers"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"owner_id": event.UserID, "organizers": organizers})
+}
+
+func addOrganizer(context *gin.Context) {
+	event, ok := eventFromParam(context)
+	if !ok {
+		return
+	}
+
+	if !requireEventAccess(context, event, models.EventAccessOwner, "manage organizers of") {
+		return
+	}
+
+	userId, ok := bindUserID(context)
+	if !ok {
+		return
+	}
+
+	err := repositories(context).Events.AddOrganizer(context.Request.Context(), event, userId)
+
+	if errors.Is(err, models.ErrAlreadyOrganizer) {
+		context.JSON(http.StatusConflict, gin.H{"message": "User already organizes this event"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not add organizer"})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "organizer added"})
+}
+
+func removeOrganizer(context *gin.Context) {
+	event, ok := eventFromParam(context)
+	if !ok {
+		return
+	}
+
+	userId, err := strconv.ParseInt(context.Param("userId"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse userId"})
+		return
+	}
+
+	// Co-organizers may step down themselves
+	if userId != context.GetInt64("userId") && !requireEventAccess(context, event, models.EventAccessOwner, "manage organizers of") {
+		return
+	}
+
+	err = repositories(context).Events.RemoveOrganizer(context.Request.Context(), event, userId)
+
+	if errors.Is(err, models.ErrNotOrganizer) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "User is not a co-organizer of this event"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not remove organizer"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "organizer removed"})
+}
+
+func transferEvent(context *gin.Context) {
+	event, ok := eventFromParam(context)
+	if !ok {
+		return
+	}
+
+	if !requireEventAccess(context, event, models.EventAccessOwner, "transfer") {
+		return
+	}
+
+	userId, ok := bindUserID(context)
+	if !ok {
+		return
+	}
+
+	err := repositories(context).Events.TransferOwnership(context.Request.Context(), event, userId)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not transfer event"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "event transferred", "owner_id": event.UserID})
+}
+
+// eventFromParam loads the event named by the :id route parameter. It writes
+// the error response itself and returns false when the request should stop.
+func eventFromParam(context *gin.Context) (*models.Event, bool) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id"})
+		return nil, false
+	}
+
+	event, err := repositories(context).Events.GetByID(context.Request.Context(), eventId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Event not found"})
+		return nil, false
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event"})
+		return nil, false
+	}
+
+	return event, true
+}
+
+// bindUserID reads {"user_id": ...} from the body and checks the user exists
+func bindUserID(context *gin.Context) (int64, bool) {
+	var request organizerRequest
+
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "user_id is required"})
+		return 0, false
+	}
+
+	_, err = repositories(context).Users.GetByID(context.Request.Context(), request.UserID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return 0, false
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not find user"})
+		return 0, false
+	}
+
+	return request.UserID, true
+}
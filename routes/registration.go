@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -10,6 +12,8 @@ import (
 )
 
 func register(context *gin.Context) {
+	ctx := context.Request.Context()
+
 	userId := context.GetInt64("userId")
 	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
 
@@ -18,7 +22,7 @@ func register(context *gin.Context) {
 		return
 	}
 
-	_, err = models.GetEventById(eventId)
+	_, err = repositories(context).Events.GetByID(ctx, eventId)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event"})
@@ -30,20 +34,89 @@ func register(context *gin.Context) {
 
 	EventRegister.EventID = eventId
 	EventRegister.UserID = userId
+	EventRegister.Occurrence = context.Query("occurrence")
+
+	err = repositories(context).Registrations.Register(ctx, &EventRegister)
+
+	if errors.Is(err, models.ErrInvalidOccurrence) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid occurrence for event"})
+		return
+	}
+
+	if errors.Is(err, models.ErrOccurrenceCanceled) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Occurrence is canceled"})
+		return
+	}
+
+	if errors.Is(err, models.ErrEventFull) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Event is full"})
+		return
+	}
 
-	err = EventRegister.Register()
+	if errors.Is(err, models.ErrAlreadyRegistered) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Already registered for event"})
+		return
+	}
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not register in event"})
 		return
 	}
 
-	context.JSON(http.StatusOK, gin.H{"message": "Event registration success"})
+	if EventRegister.Status == models.RegistrationStatusWaitlisted {
+		context.JSON(http.StatusOK, gin.H{"message": "Event is full, added to the waitlist", "status": EventRegister.Status})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Event registration success", "status": EventRegister.Status})
 }
 
 
+func getRegistration(context *gin.Context) {
+	ctx := context.Request.Context()
+
+	userId := context.GetInt64("userId")
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id"})
+		return
+	}
+
+	_, err = repositories(context).Events.GetByID(ctx, eventId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Event not found"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event"})
+		return
+	}
+
+	registration, err := repositories(context).Registrations.Get(ctx, eventId, userId, context.Query("occurrence"))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusOK, gin.H{"registered": false, "status": "none"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch registration"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"registered":    registration.Status == models.RegistrationStatusRegistered,
+		"status":        registration.Status,
+		"registered_at": registration.CreatedAt,
+	})
+}
 
 func cancel(context *gin.Context) {
+	ctx := context.Request.Context()
+
 	userId := context.GetInt64("userId")
 	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
 
@@ -52,7 +125,7 @@ func cancel(context *gin.Context) {
 		return
 	}
 
-	_, err = models.GetEventById(eventId)
+	_, err = repositories(context).Events.GetByID(ctx, eventId)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event"})
@@ -64,8 +137,14 @@ func cancel(context *gin.Context) {
 
 	EventRegister.EventID = eventId
 	EventRegister.UserID = userId
+	EventRegister.Occurrence = context.Query("occurrence")
+
+	err = repositories(context).Registrations.Cancel(ctx, &EventRegister)
 
-	err = EventRegister.Cancel()
+	if errors.Is(err, models.ErrNotRegistered) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Not registered for event"})
+		return
+	}
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not cancel event"})
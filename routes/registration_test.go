@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"example.com/rest-api/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRegistration(t *testing.T) {
+	cleanup, err := test.SetupSQLiteDB()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	fixture := newAttendeeFixture(t)
+	path := "/events/" + strconv.FormatInt(fixture.event.ID, 10) + "/registration"
+
+	recorder := fixture.get("first", path)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"status":"registered"`)
+
+	recorder = fixture.get("stranger", path)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"status":"none"`)
+
+	assert.Equal(t, http.StatusNotFound, fixture.get("first", "/events/999999/registration").Code)
+}
@@ -0,0 +1,142 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+type reminderRequest struct {
+	Offsets *[]string `json:"offsets" binding:"required"`
+}
+
+func getEventReminders(context *gin.Context) {
+	event, ok := eventFromParam(context)
+	if !ok {
+		return
+	}
+
+	getReminders(context, 0, event.ID)
+}
+
+func updateEventReminders(context *gin.Context) {
+	event, ok := eventFromParam(context)
+	if !ok {
+		return
+	}
+
+	if !requireEventAccess(context, event, models.EventAccessOrganizer, "set reminders for") {
+		return
+	}
+
+	updateReminders(context, 0, event.ID)
+}
+
+func deleteEventReminders(context *gin.Context) {
+	event, ok := eventFromParam(context)
+	if !ok {
+		return
+	}
+
+	if !requireEventAccess(context, event, models.EventAccessOrganizer, "set reminders for") {
+		return
+	}
+
+	deleteReminders(context, 0, event.ID)
+}
+
+func getMyReminders(context *gin.Context) {
+	getReminders(context, context.GetInt64("userId"), 0)
+}
+
+func updateMyReminders(context *gin.Context) {
+	updateReminders(context, context.GetInt64("userId"), 0)
+}
+
+func deleteMyReminders(context *gin.Context) {
+	deleteReminders(context, context.GetInt64("userId"), 0)
+}
+
+func getMyEventReminders(context *gin.Context) {
+	event, ok := eventFromParam(context)
+	if !ok {
+		return
+	}
+
+	getReminders(context, context.GetInt64("userId"), event.ID)
+}
+
+func updateMyEventReminders(context *gin.Context) {
+	event, ok := eventFromParam(context)
+	if !ok {
+		return
+	}
+
+	updateReminders(context, context.GetInt64("userId"), event.ID)
+}
+
+func deleteMyEventReminders(context *gin.Context) {
+	event, ok := eventFromParam(context)
+	if !ok {
+		return
+	}
+
+	deleteReminders(context, context.GetInt64("userId"), event.ID)
+}
+
+// getReminders responds with the schedule that applies, with userId or
+// eventId 0 as in models.ReminderRepository.Settings
+func getReminders(context *gin.Context, userId, eventId int64) {
+	settings, err := repositories(context).Reminders.Settings(context.Request.Context(), userId, eventId)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch reminders"})
+		return
+	}
+
+	context.JSON(http.StatusOK, settings)
+}
+
+func updateReminders(context *gin.Context, userId, eventId int64) {
+	var request reminderRequest
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Provide offsets, e.g. [\"24h\", \"1h\"], or [] for no reminders"})
+		return
+	}
+
+	schedule, err := models.ParseReminderSchedule(*request.Offsets)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	err = repositories(context).Reminders.SetSchedule(context.Request.Context(), userId, eventId, schedule)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save reminders"})
+		return
+	}
+
+	getReminders(context, userId, eventId)
+}
+
+func deleteReminders(context *gin.Context, userId, eventId int64) {
+	err := repositories(context).Reminders.DeleteSchedule(context.Request.Context(), userId, eventId)
+
+	if errors.Is(err, models.ErrReminderScheduleNotFound) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "No reminder schedule set"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not reset reminders"})
+		return
+	}
+
+	getReminders(context, userId, eventId)
+}
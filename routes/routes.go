@@ -1,31 +1,96 @@
 package routes
 
 import (
+	"example.com/rest-api/config"
 	"example.com/rest-api/middlewares"
+	"example.com/rest-api/models"
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(server *gin.Engine) {
+// repositoriesKey is where handlers find the repositories in the gin context
+const repositoriesKey = "repositories"
+
+func RegisterRoutes(server *gin.Engine, cfg *config.Config, repos models.Repositories) {
+
+	server.Use(func(context *gin.Context) {
+		context.Set(repositoriesKey, repos)
+		context.Next()
+	})
+
+	// Streams stay open as long as the client listens
+	server.Use(middlewares.Timeout(cfg.Server.RequestTimeout, "/notifications/stream", "/ws"))
 
 	// events
 	server.GET("/events", getEvents)
+	server.GET("/events/search", searchEvents)
 	server.GET("/events/:id", getSingleEvent)
+	server.GET("/events/:id/occurrences", getEventOccurrences)
+	server.GET("/events/:id/ics", getEventICS)
 
 	authenticated := server.Group("/")
-	authenticated.Use(middlewares.Authenticate)
-	authenticated.POST("/events", createEvent)
+	authenticated.Use(middlewares.Authenticate(repos.Tokens))
+	authenticated.POST("/events", middlewares.RequirePermission(models.PermissionCreateEvents), createEvent)
 	authenticated.PUT("/events/:id", updateEvent)
 	authenticated.DELETE("/events/:id", deleteEvent)
 	authenticated.POST("/events/:id/register", register)
+	authenticated.GET("/events/:id/registration", getRegistration)
 	authenticated.DELETE("/events/:id/cancel", cancel)
+	authenticated.PUT("/events/:id/occurrences/:occurrence", updateOccurrence)
+	authenticated.DELETE("/events/:id/occurrences/:occurrence", restoreOccurrence)
+	authenticated.GET("/events/:id/organizers", getOrganizers)
+	authenticated.POST("/events/:id/organizers", addOrganizer)
+	authenticated.DELETE("/events/:id/organizers/:userId", removeOrganizer)
+	authenticated.POST("/events/:id/transfer", transferEvent)
+	authenticated.GET("/events/:id/attendees", getAttendees)
+	authenticated.GET("/events/:id/attendees/export", exportAttendees)
+	authenticated.GET("/events/:id/reminders", getEventReminders)
+	authenticated.PUT("/events/:id/reminders", updateEventReminders)
+	authenticated.DELETE("/events/:id/reminders", deleteEventReminders)
 
 	// notifications
 	authenticated.GET("/notifications", getNotifications)
+	authenticated.GET("/notifications/stream", streamNotifications)
 	authenticated.PUT("/notifications/:id/read", markNotificationAsRead)
-	authenticated.POST("/notifications/trigger", triggerNotificationCheck)
+	authenticated.POST("/notifications/trigger", middlewares.RequirePermission(models.PermissionTriggerNotifications), triggerNotificationCheck)
+
+	// background jobs
+	authenticated.GET("/jobs", middlewares.RequirePermission(models.PermissionManageJobs), getJobs)
+	authenticated.POST("/jobs/:id/retry", middlewares.RequirePermission(models.PermissionManageJobs), retryJob)
+
+	// live event updates
+	authenticated.GET("/ws", eventSocket)
+
+	// webhooks
+	authenticated.GET("/webhooks", getWebhooks)
+	authenticated.POST("/webhooks", createWebhook)
+	authenticated.DELETE("/webhooks/:id", deleteWebhook)
+	authenticated.GET("/webhooks/:id/deliveries", getWebhookDeliveries)
 
 	// users
 	server.POST("/signup", signup)
 	server.POST("/login", login)
+	server.POST("/token/refresh", refreshToken)
+	authenticated.POST("/logout", logout)
+	authenticated.PUT("/users/me/password", changePassword)
 	server.GET("/user/:id", getUserByID)
+	server.GET("/users/me/calendar.ics", getCalendarFeed)
+	authenticated.POST("/users/me/calendar-token", createCalendarToken)
+	authenticated.GET("/users/me/events", getMyEvents)
+	authenticated.GET("/users/me/registrations", getMyRegistrations)
+	authenticated.GET("/users/me/settings", getSettings)
+	authenticated.PUT("/users/me/settings", updateSettings)
+	authenticated.GET("/users/me/notification-preferences", getNotificationPreferences)
+	authenticated.PUT("/users/me/notification-preferences", updateNotificationPreferences)
+	authenticated.GET("/users/me/reminders", getMyReminders)
+	authenticated.PUT("/users/me/reminders", updateMyReminders)
+	authenticated.DELETE("/users/me/reminders", deleteMyReminders)
+	authenticated.GET("/users/me/events/:id/reminders", getMyEventReminders)
+	authenticated.PUT("/users/me/events/:id/reminders", updateMyEventReminders)
+	authenticated.DELETE("/users/me/events/:id/reminders", deleteMyEventReminders)
+	authenticated.PUT("/users/:id/role", middlewares.RequirePermission(models.PermissionManageUsers), updateUserRole)
+}
+
+// repositories returns the repositories the routes were registered with
+func repositories(context *gin.Context) models.Repositories {
+	return context.MustGet(repositoriesKey).(models.Repositories)
 }
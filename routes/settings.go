@@ -0,0 +1,69 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"example.com/rest-api/i18n"
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// settingsRequest holds the settings to change; omitted ones are kept
+type settingsRequest struct {
+	Timezone *string `json:"timezone"`
+	Locale   *string `json:"locale"`
+}
+
+func getSettings(context *gin.Context) {
+	settings, err := repositories(context).Users.GetSettings(context.Request.Context(), context.GetInt64("userId"))
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch settings"})
+		return
+	}
+
+	context.JSON(http.StatusOK, settings)
+}
+
+func updateSettings(context *gin.Context) {
+	ctx := context.Request.Context()
+
+	var request settingsRequest
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse settings"})
+		return
+	}
+
+	userId := context.GetInt64("userId")
+	settings, err := repositories(context).Users.GetSettings(ctx, userId)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch settings"})
+		return
+	}
+
+	if request.Timezone != nil {
+		settings.Timezone = *request.Timezone
+	}
+
+	if request.Locale != nil {
+		settings.Locale = *request.Locale
+	}
+
+	err = repositories(context).Users.UpdateSettings(ctx, userId, *settings)
+
+	if errors.Is(err, models.ErrInvalidTimezone) || errors.Is(err, i18n.ErrUnsupportedLocale) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save settings"})
+		return
+	}
+
+	context.JSON(http.StatusOK, settings)
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"log"
+
+	"example.com/rest-api/realtime"
+	"example.com/rest-api/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// eventSocket serves GET /ws, where clients subscribe to events and receive
+// their changes and attendee counts live
+func eventSocket(context *gin.Context) {
+	claims := context.MustGet("tokenClaims").(*utils.Claims)
+
+	err := realtime.Events.Serve(context.Writer, context.Request, claims.UserID, claims.ExpiresAt)
+
+	if err != nil {
+		log.Printf("Could not open WebSocket for user %d: %v", claims.UserID, err)
+	}
+}
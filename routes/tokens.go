@@ -0,0 +1,104 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"example.com/rest-api/models"
+	"example.com/rest-api/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type passwordChangeRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+func refreshToken(context *gin.Context) {
+	var request refreshRequest
+
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "refresh_token is required"})
+		return
+	}
+
+	tokens, err := repositories(context).Tokens.Refresh(context.Request.Context(), request.RefreshToken)
+
+	if errors.Is(err, models.ErrRefreshTokenReused) {
+		context.JSON(http.StatusUnauthorized, gin.H{"message": "Refresh token was already used, please log in again"})
+		return
+	}
+
+	if errors.Is(err, models.ErrInvalidRefreshToken) {
+		context.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid refresh token"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not refresh token"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"token": tokens.Token, "refresh_token": tokens.RefreshToken})
+}
+
+func logout(context *gin.Context) {
+	claims := context.MustGet("tokenClaims").(*utils.Claims)
+
+	// The refresh token is optional; without it only the access token is revoked
+	var request refreshRequest
+	_ = context.ShouldBindJSON(&request)
+
+	err := repositories(context).Tokens.Logout(context.Request.Context(), claims, request.RefreshToken)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not log out"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+func changePassword(context *gin.Context) {
+	ctx := context.Request.Context()
+
+	var request passwordChangeRequest
+
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "current_password and new_password are required"})
+		return
+	}
+
+	claims := context.MustGet("tokenClaims").(*utils.Claims)
+	user := models.User{ID: claims.UserID, Email: claims.Email, Role: models.Role(claims.Role)}
+
+	err = repositories(context).Users.ChangePassword(ctx, &user, request.CurrentPassword, request.NewPassword)
+
+	if errors.Is(err, models.ErrIncorrectPassword) {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Current password is incorrect"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not change password"})
+		return
+	}
+
+	// Every earlier token is now invalid, so hand back a fresh pair
+	tokens, err := repositories(context).Tokens.Issue(ctx, &user)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Password changed, please log in again"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Password changed", "token": tokens.Token, "refresh_token": tokens.RefreshToken})
+}
@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -26,7 +28,7 @@ func signup(context *gin.Context) {
 		return
 	}
 
-	err = user.Save()
+	err = repositories(context).Users.Save(context.Request.Context(), &user)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save user"})
@@ -37,6 +39,8 @@ func signup(context *gin.Context) {
 }
 
 func login(context *gin.Context) {
+	ctx := context.Request.Context()
+
 	var user models.User
 
 	err := context.ShouldBindJSON(&user)
@@ -46,21 +50,21 @@ func login(context *gin.Context) {
 		return
 	}
 
-	err = user.ValidateUser()
+	err = repositories(context).Users.ValidateCredentials(ctx, &user)
 
 	if err != nil {
 		context.JSON(http.StatusForbidden, err)
 		return
 	}
 
-	token, err := utils.GenerateToken(user.Email, user.ID)
+	tokens, err := repositories(context).Tokens.Issue(ctx, &user)
 
 	if err != nil {
 		context.JSON(http.StatusForbidden, gin.H{"message": "Could not authenticate user"})
 		return
 	}
 
-	context.JSON(http.StatusOK, gin.H{"message": "login success", "token": token})
+	context.JSON(http.StatusOK, gin.H{"message": "login success", "token": tokens.Token, "refresh_token": tokens.RefreshToken})
 }
 
 func getUserByID(context *gin.Context) {
@@ -71,7 +75,7 @@ func getUserByID(context *gin.Context) {
 		return
 	}
 
-	user, err := models.GetUser(userId)
+	user, err := repositories(context).Users.GetByID(context.Request.Context(), userId)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not find user"})
@@ -80,4 +84,48 @@ func getUserByID(context *gin.Context) {
 	context.JSON(http.StatusOK, user)
 
 	
-}
\ No newline at end of file
+}
+
+type roleChange struct {
+	Role models.Role `json:"role" binding:"required"`
+}
+
+func updateUserRole(context *gin.Context) {
+	ctx := context.Request.Context()
+
+	userId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse userId"})
+		return
+	}
+
+	var change roleChange
+	err = context.ShouldBindJSON(&change)
+
+	if err != nil || !change.Role.Valid() {
+		context.JSON(http.StatusBadRequest, gin.H{"message": models.ErrInvalidRole.Error()})
+		return
+	}
+
+	_, err = repositories(context).Users.GetByID(ctx, userId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not find user"})
+		return
+	}
+
+	err = repositories(context).Users.SetRole(ctx, userId, change.Role)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not update role"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "role updated", "role": change.Role})
+}
@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+type webhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events"`
+}
+
+func getWebhooks(context *gin.Context) {
+	webhooks, err := repositories(context).Webhooks.List(context.Request.Context(), context.GetInt64("userId"))
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch webhooks"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"webhooks": webhooks, "event_types": models.WebhookEventTypes})
+}
+
+func createWebhook(context *gin.Context) {
+	var request webhookRequest
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Provide the webhook url"})
+		return
+	}
+
+	webhook := models.Webhook{
+		UserID: context.GetInt64("userId"),
+		URL:    request.URL,
+		Events: request.Events,
+	}
+
+	err = repositories(context).Webhooks.Save(context.Request.Context(), &webhook)
+
+	if errors.Is(err, models.ErrInvalidWebhookURL) || errors.Is(err, models.ErrForbiddenWebhookURL) ||
+		errors.Is(err, models.ErrUnknownWebhookEvent) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create webhook"})
+		return
+	}
+
+	// The secret is not shown again
+	context.JSON(http.StatusCreated, gin.H{"message": "webhook created", "webhook": webhook, "secret": webhook.Secret})
+}
+
+func deleteWebhook(context *gin.Context) {
+	webhookId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse webhook id"})
+		return
+	}
+
+	err = repositories(context).Webhooks.Delete(context.Request.Context(), webhookId, context.GetInt64("userId"))
+
+	if errors.Is(err, models.ErrWebhookNotFound) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Webhook not found"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete webhook"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "webhook deleted"})
+}
+
+func getWebhookDeliveries(context *gin.Context) {
+	ctx := context.Request.Context()
+
+	webhookId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse webhook id"})
+		return
+	}
+
+	limit := 0
+	if value := context.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > 100 {
+			context.JSON(http.StatusBadRequest, gin.H{"message": "limit must be between 1 and 100"})
+			return
+		}
+	}
+
+	webhook, err := repositories(context).Webhooks.Get(ctx, webhookId, context.GetInt64("userId"))
+
+	if errors.Is(err, models.ErrWebhookNotFound) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Webhook not found"})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch webhook"})
+		return
+	}
+
+	deliveries, err := repositories(context).Webhooks.Deliveries(ctx, webhook.ID, limit)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch deliveries"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
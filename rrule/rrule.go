@@ -0,0 +1,348 @@
+// Package rrule parses and expands the subset of iCalendar (RFC 5545)
+// recurrence rules that events support: DAILY, WEEKLY and MONTHLY
+// frequencies with INTERVAL, COUNT, UNTIL and BYDAY.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// maxPeriods bounds expansion of rules that never match, such as the 31st of
+// every second February
+const maxPeriods = 100000
+
+var dayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Weekday is a BYDAY entry. N selects the Nth such day of the month (negative
+// counts from the end) and is only used with MONTHLY; zero means every one.
+type Weekday struct {
+	Day time.Weekday
+	N   int
+}
+
+// Rule is a parsed RRULE. A zero Count and Until mean the series never ends.
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []Weekday
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10".
+// A leading "RRULE:" is accepted.
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+
+	if value == "" {
+		return nil, errors.New("rrule is empty")
+	}
+
+	rule := Rule{Interval: 1}
+
+	for _, part := range strings.Split(value, ";") {
+		key, val, found := strings.Cut(part, "=")
+
+		if !found || val == "" {
+			return nil, fmt.Errorf("rrule part %q must be KEY=VALUE", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(val))
+
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly {
+				return nil, fmt.Errorf("rrule FREQ must be DAILY, WEEKLY or MONTHLY, got %q", val)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("rrule INTERVAL must be a positive number, got %q", val)
+			}
+
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("rrule COUNT must be a positive number, got %q", val)
+			}
+
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseUntil(val)
+
+			if err != nil {
+				return nil, err
+			}
+
+			rule.Until = until
+		case "BYDAY":
+			for _, code := range strings.Split(val, ",") {
+				day, err := parseWeekday(code)
+
+				if err != nil {
+					return nil, err
+				}
+
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		default:
+			return nil, fmt.Errorf("rrule part %q is not supported", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("rrule FREQ is required")
+	}
+
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, errors.New("rrule cannot have both COUNT and UNTIL")
+	}
+
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly {
+			return nil, errors.New("rrule BYDAY ordinals such as 1MO are only allowed with FREQ=MONTHLY")
+		}
+	}
+
+	return &rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		until, err := time.Parse(layout, value)
+
+		if err != nil {
+			continue
+		}
+
+		// A date-only UNTIL includes the whole day
+		if layout == "20060102" {
+			until = until.Add(24*time.Hour - time.Second)
+		}
+
+		return until, nil
+	}
+
+	return time.Time{}, fmt.Errorf("rrule UNTIL must look like 20240131T235959Z, got %q", value)
+}
+
+func parseWeekday(code string) (Weekday, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	if len(code) < 2 {
+		return Weekday{}, fmt.Errorf("rrule BYDAY value %q is invalid", code)
+	}
+
+	day, ok := dayCodes[code[len(code)-2:]]
+
+	if !ok {
+		return Weekday{}, fmt.Errorf("rrule BYDAY value %q is invalid", code)
+	}
+
+	weekday := Weekday{Day: day}
+
+	if prefix := code[:len(code)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return Weekday{}, fmt.Errorf("rrule BYDAY value %q is invalid", code)
+		}
+
+		weekday.N = n
+	}
+
+	return weekday, nil
+}
+
+// String formats the rule in canonical RRULE form, without the "RRULE:" prefix
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+
+	if len(r.ByDay) > 0 {
+		codes := make([]string, len(r.ByDay))
+
+		for i, day := range r.ByDay {
+			codes[i] = day.String()
+		}
+
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+func (d Weekday) String() string {
+	code := strings.ToUpper(d.Day.String()[:2])
+
+	if d.N != 0 {
+		return strconv.Itoa(d.N) + code
+	}
+
+	return code
+}
+
+// Between returns the occurrences of a series starting at start that fall
+// within [from, to], in order. Occurrences keep start's time of day and
+// location.
+func (r *Rule) Between(start, from, to time.Time) []time.Time {
+	var occurrences []time.Time
+	seen := 0
+
+	for period := 0; period < maxPeriods; period++ {
+		for _, candidate := range r.period(start, period) {
+			if candidate.Before(start) {
+				continue
+			}
+
+			if candidate.After(to) || (!r.Until.IsZero() && candidate.After(r.Until)) {
+				return occurrences
+			}
+
+			seen++
+
+			if !candidate.Before(from) {
+				occurrences = append(occurrences, candidate)
+			}
+
+			if r.Count > 0 && seen >= r.Count {
+				return occurrences
+			}
+		}
+	}
+
+	return occurrences
+}
+
+// Includes reports whether t is an occurrence of a series starting at start
+func (r *Rule) Includes(start, t time.Time) bool {
+	return len(r.Between(start, t, t)) == 1
+}
+
+// period returns the candidate dates in the n-th period (day, week or month)
+// after start, in order
+func (r *Rule) period(start time.Time, n int) []time.Time {
+	switch r.Freq {
+	case Daily:
+		day := start.AddDate(0, 0, n*r.Interval)
+
+		if len(r.ByDay) > 0 && !r.matchesDay(day.Weekday()) {
+			return nil
+		}
+
+		return []time.Time{day}
+	case Weekly:
+		// Weeks start on Monday, as with the RFC's default WKST=MO
+		monday := start.AddDate(0, 0, -daysSinceMonday(start.Weekday())+7*n*r.Interval)
+
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []Weekday{{Day: start.Weekday()}}
+		}
+
+		var dates []time.Time
+		for _, day := range days {
+			dates = append(dates, monday.AddDate(0, 0, daysSinceMonday(day.Day)))
+		}
+
+		return sortDates(dates)
+	case Monthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(n*r.Interval), 1,
+			start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		length := first.AddDate(0, 1, -1).Day()
+
+		if len(r.ByDay) == 0 {
+			if start.Day() > length {
+				return nil
+			}
+
+			return []time.Time{first.AddDate(0, 0, start.Day()-1)}
+		}
+
+		var dates []time.Time
+		for _, day := range r.ByDay {
+			offset := (int(day.Day) - int(first.Weekday()) + 7) % 7
+			var matches []time.Time
+
+			for d := offset; d < length; d += 7 {
+				matches = append(matches, first.AddDate(0, 0, d))
+			}
+
+			switch {
+			case day.N == 0:
+				dates = append(dates, matches...)
+			case day.N > 0 && day.N <= len(matches):
+				dates = append(dates, matches[day.N-1])
+			case day.N < 0 && -day.N <= len(matches):
+				dates = append(dates, matches[len(matches)+day.N])
+			}
+		}
+
+		return sortDates(dates)
+	}
+
+	return nil
+}
+
+func (r *Rule) matchesDay(weekday time.Weekday) bool {
+	for _, day := range r.ByDay {
+		if day.Day == weekday {
+			return true
+		}
+	}
+
+	return false
+}
+
+func daysSinceMonday(weekday time.Weekday) int {
+	return (int(weekday) + 6) % 7
+}
+
+// sortDates orders dates and drops duplicates, e.g. from BYDAY=MO,1MO
+func sortDates(dates []time.Time) []time.Time {
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	var unique []time.Time
+	for _, date := range dates {
+		if len(unique) == 0 || !date.Equal(unique[len(unique)-1]) {
+			unique = append(unique, date)
+		}
+	}
+
+	return unique
+}
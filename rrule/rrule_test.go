@@ -0,0 +1,122 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func dates(t *testing.T, values ...string) []time.Time {
+	var result []time.Time
+	for _, value := range values {
+		parsed, err := time.Parse(time.RFC3339, value)
+		assert.NoError(t, err)
+		result = append(result, parsed)
+	}
+	return result
+}
+
+func TestParse(t *testing.T) {
+	rule, err := Parse("RRULE:freq=weekly;INTERVAL=2;BYDAY=MO,we;UNTIL=20300131")
+	assert.NoError(t, err)
+	assert.Equal(t, Weekly, rule.Freq)
+	assert.Equal(t, 2, rule.Interval)
+	assert.Equal(t, []Weekday{{Day: time.Monday}, {Day: time.Wednesday}}, rule.ByDay)
+	assert.Equal(t, time.Date(2030, 1, 31, 23, 59, 59, 0, time.UTC), rule.Until)
+	assert.Equal(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;UNTIL=20300131T235959Z", rule.String())
+
+	rule, err = Parse("FREQ=MONTHLY;BYDAY=-1FR;COUNT=3")
+	assert.NoError(t, err)
+	assert.Equal(t, []Weekday{{Day: time.Friday, N: -1}}, rule.ByDay)
+	assert.Equal(t, "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", rule.String())
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20300101",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ",
+	} {
+		_, err := Parse(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	start := time.Date(2030, 1, 6, 18, 0, 0, 0, time.UTC) // a Sunday
+	farFuture := time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		rule     string
+		start    time.Time
+		from, to time.Time
+		expected []time.Time
+	}{
+		{
+			name:  "Daily with count",
+			rule:  "FREQ=DAILY;INTERVAL=2;COUNT=3",
+			start: start, from: start, to: farFuture,
+			expected: dates(t, "2030-01-06T18:00:00Z", "2030-01-08T18:00:00Z", "2030-01-10T18:00:00Z"),
+		},
+		{
+			name:  "Weekly by day skips days before start",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,SU;COUNT=4",
+			start: start, from: start, to: farFuture,
+			expected: dates(t, "2030-01-06T18:00:00Z", "2030-01-07T18:00:00Z", "2030-01-13T18:00:00Z", "2030-01-14T18:00:00Z"),
+		},
+		{
+			name:  "Weekly until is inclusive",
+			rule:  "FREQ=WEEKLY;UNTIL=20300120T180000Z",
+			start: start, from: start, to: farFuture,
+			expected: dates(t, "2030-01-06T18:00:00Z", "2030-01-13T18:00:00Z", "2030-01-20T18:00:00Z"),
+		},
+		{
+			name:  "Window keeps counting from the start",
+			rule:  "FREQ=DAILY;COUNT=5",
+			start: start, from: start.AddDate(0, 0, 3), to: farFuture,
+			expected: dates(t, "2030-01-09T18:00:00Z", "2030-01-10T18:00:00Z"),
+		},
+		{
+			name:  "Monthly skips months without the day",
+			rule:  "FREQ=MONTHLY;COUNT=3",
+			start: time.Date(2030, 1, 31, 9, 0, 0, 0, time.UTC), from: start, to: farFuture,
+			expected: dates(t, "2030-01-31T09:00:00Z", "2030-03-31T09:00:00Z", "2030-05-31T09:00:00Z"),
+		},
+		{
+			name:  "Monthly first Tuesday and last Friday",
+			rule:  "FREQ=MONTHLY;BYDAY=1TU,-1FR",
+			start: start, from: start, to: time.Date(2030, 2, 28, 23, 0, 0, 0, time.UTC),
+			expected: dates(t, "2030-01-25T18:00:00Z", "2030-02-05T18:00:00Z", "2030-02-22T18:00:00Z"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, rule.Between(tt.start, tt.from, tt.to))
+		})
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	start := time.Date(2030, 1, 6, 18, 0, 0, 0, time.UTC)
+	rule, err := Parse("FREQ=WEEKLY;COUNT=2")
+	assert.NoError(t, err)
+
+	assert.True(t, rule.Includes(start, start.AddDate(0, 0, 7)))
+	assert.False(t, rule.Includes(start, start.AddDate(0, 0, 14)))
+	assert.False(t, rule.Includes(start, start.AddDate(0, 0, 1)))
+	assert.False(t, rule.Includes(start, start.Add(time.Hour)))
+}
@@ -1,10 +1,12 @@
 package test
 
 import (
+	"database/sql"
 	"database/sql/driver"
 	"time"
 
 	"example.com/rest-api/db"
+	"example.com/rest-api/migrations"
 	"github.com/DATA-DOG/go-sqlmock"
 )
 
@@ -15,19 +17,52 @@ func SetupMockDB() (sqlmock.Sqlmock, func(), error) {
 		return nil, nil, err
 	}
 
-	// Replace the global DB with our mock
-	originalDB := db.DB
-	db.DB = mockDB
+	// Replace the global DB with our mock; expectations are written in MySQL
+	originalDB, originalDialect := db.DB, db.Dialect
+	db.DB, db.Dialect = mockDB, db.MySQL
 
 	// Return cleanup function
 	cleanup := func() {
 		mockDB.Close()
-		db.DB = originalDB
+		db.DB, db.Dialect = originalDB, originalDialect
 	}
 
 	return mock, cleanup, nil
 }
 
+// SetupSQLiteDB creates a migrated in-memory SQLite database for tests that
+// need real SQL semantics rather than mocked expectations
+func SetupSQLiteDB() (func(), error) {
+	sqliteDB, err := sql.Open(db.SQLite.DriverName(), db.SQLiteDSN("file::memory:?_pragma=foreign_keys(1)"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Every connection to :memory: is a separate database
+	sqliteDB.SetMaxOpenConns(1)
+
+	migrator, err := migrations.New(sqliteDB, db.SQLite.Name())
+	if err != nil {
+		sqliteDB.Close()
+		return nil, err
+	}
+
+	if err := migrator.Up(); err != nil {
+		sqliteDB.Close()
+		return nil, err
+	}
+
+	originalDB, originalDialect := db.DB, db.Dialect
+	db.DB, db.Dialect = sqliteDB, db.SQLite
+
+	cleanup := func() {
+		sqliteDB.Close()
+		db.DB, db.Dialect = originalDB, originalDialect
+	}
+
+	return cleanup, nil
+}
+
 // TestEvent represents a test event structure
 type TestEvent struct {
 	ID          int64
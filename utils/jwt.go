@@ -1,25 +1,98 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"time"
 
+	"example.com/rest-api/config"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-const secretKey = "supersecret"
+var (
+	secretKey       = config.DefaultJWTSecret
+	tokenTTL        = 2 * time.Hour
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Claims are the fields of an access token the server relies on
+type Claims struct {
+	UserID    int64
+	Email     string
+	Role      string
+	ID        string // jti, used to revoke a single token
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// InitJWT sets the signing secret and token lifetimes from the loaded configuration
+func InitJWT(cfg config.JWTConfig) {
+	secretKey = cfg.Secret
+	tokenTTL = cfg.TTL
+	refreshTokenTTL = cfg.RefreshTTL
+}
+
+func GenerateToken(email string, userId int64, role string) (string, error) {
+	jti, err := RandomToken(16)
+
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 
-func GenerateToken(email string, userId int64) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"userId": userId,
 		"email":  email,
-		"exp":    time.Now().Add(time.Hour * 2).Unix(),
+		"role":   role,
+		"jti":    jti,
+		"iat":    now.Unix(),
+		"exp":    now.Add(tokenTTL).Unix(),
 	})
 
 	return token.SignedString([]byte(secretKey))
 }
 
+// NewRefreshToken returns an opaque refresh token and when it expires
+func NewRefreshToken() (string, time.Time, error) {
+	token, err := RandomToken(32)
+
+	return token, time.Now().Add(refreshTokenTTL), err
+}
+
+// RandomToken returns n random bytes, hex encoded
+func RandomToken(n int) (string, error) {
+	raw := make([]byte, n)
+
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(raw), nil
+}
+
+// HashToken is how opaque tokens are stored, so a database leak does not
+// leak usable tokens
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func VerifyToken(token string) (int64, error) {
+	claims, err := ParseToken(token)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return claims.UserID, nil
+}
+
+// ParseToken checks the token's signature and expiry and returns its claims.
+// Tokens issued before jti and iat were added have them empty.
+func ParseToken(token string) (*Claims, error) {
 	parsedToken, err := jwt.Parse(token, func (token *jwt.Token) (interface{}, error) {
 		_, ok := token.Method.(*jwt.SigningMethodHMAC)
 
@@ -31,32 +104,45 @@ func VerifyToken(token string) (int64, error) {
 	})
 
 	if err != nil {
-		return 0, errors.New("could not parse token")
+		return nil, errors.New("could not parse token")
 	
 	}
 
 	isTokenValid := parsedToken.Valid
 
 	if !isTokenValid {
-		return 0, errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 
 	if !ok {
-		return 0, errors.New("invalid claims")
+		return nil, errors.New("invalid claims")
 	}
 
 	// Check if userId claim exists
 	userIdClaim, exists := claims["userId"]
 	if !exists {
-		return 0, errors.New("userId claim missing")
+		return nil, errors.New("userId claim missing")
 	}
 
 	userId, ok := userIdClaim.(float64)
 	if !ok {
-		return 0, errors.New("userId claim invalid type")
+		return nil, errors.New("userId claim invalid type")
+	}
+
+	result := Claims{UserID: int64(userId)}
+	result.Email, _ = claims["email"].(string)
+	result.Role, _ = claims["role"].(string)
+	result.ID, _ = claims["jti"].(string)
+
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		result.IssuedAt = issuedAt.Time
+	}
+
+	if expiresAt, err := claims.GetExpirationTime(); err == nil && expiresAt != nil {
+		result.ExpiresAt = expiresAt.Time
 	}
 
-	return int64(userId), nil
+	return &result, nil
 }
@@ -49,7 +49,7 @@ func TestGenerateToken(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			token, err := GenerateToken(tt.email, tt.userID)
+			token, err := GenerateToken(tt.email, tt.userID, "organizer")
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -69,7 +69,7 @@ func TestVerifyToken(t *testing.T) {
 	// Create a valid token first
 	email := "test@example.com"
 	userID := int64(123)
-	validToken, err := GenerateToken(email, userID)
+	validToken, err := GenerateToken(email, userID, "organizer")
 	assert.NoError(t, err)
 
 	tests := []struct {
@@ -166,7 +166,7 @@ func TestTokenRoundTrip(t *testing.T) {
 	userID := int64(456)
 
 	// Generate token
-	token, err := GenerateToken(email, userID)
+	token, err := GenerateToken(email, userID, "organizer")
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 
@@ -181,9 +181,9 @@ func TestGenerateToken_DifferentTokensForSameUser(t *testing.T) {
 	userID := int64(123)
 
 	// Generate two tokens for the same user
-	token1, err1 := GenerateToken(email, userID)
+	token1, err1 := GenerateToken(email, userID, "organizer")
 	time.Sleep(time.Second) // Ensure different timestamps (1 second for more reliable difference)
-	token2, err2 := GenerateToken(email, userID)
+	token2, err2 := GenerateToken(email, userID, "organizer")
 
 	assert.NoError(t, err1)
 	assert.NoError(t, err2)
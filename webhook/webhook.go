@@ -0,0 +1,175 @@
+// Package webhook signs webhook payloads and posts them to their endpoints.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Headers set on every webhook request
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrForbiddenAddress = errors.New("webhook endpoint is a private or local address")
+)
+
+// Request is one payload for one endpoint
+type Request struct {
+	URL        string
+	Secret     string
+	Event      string
+	DeliveryID int64
+	Payload    []byte
+}
+
+// Sender posts signed payloads
+type Sender struct {
+	Client *http.Client
+}
+
+// NewSender returns a Sender whose requests give up after timeout. It only
+// connects to public addresses and does not follow redirects, so webhooks
+// cannot be used to probe the network the server runs in.
+func NewSender(timeout time.Duration) *Sender {
+	return &Sender{Client: newClient(timeout, Forbidden)}
+}
+
+// Forbidden reports whether webhooks may not be sent to the address:
+// loopback, private, link-local, multicast and unspecified addresses
+func Forbidden(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// newClient returns a client that refuses to connect to forbidden addresses.
+// The check runs on the address actually dialed, after DNS resolution, so a
+// host name that resolves to an internal address is refused too.
+func newClient(timeout time.Duration, forbidden func(net.IP) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || forbidden(ip) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// A proxy would make the connection, out of reach of the check
+	transport.Proxy = nil
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// A redirect is reported as the endpoint's response rather than
+		// followed, wherever it points
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Send posts the payload and returns the response status. Any status outside
+// 2xx is an error; the status is zero if there was no response at all, as
+// when ctx ends first.
+func (s *Sender) Send(ctx context.Context, r Request) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(r.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-events-webhooks/1.0")
+	req.Header.Set(HeaderEvent, r.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(r.DeliveryID, 10))
+	req.Header.Set(HeaderSignature, Sign(r.Secret, time.Now(), r.Payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value "t=<unix time>,v1=<hex HMAC>", the
+// HMAC-SHA256 with the secret of the timestamp, a dot and the payload.
+// Signing the timestamp lets receivers reject replayed requests.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + unix + ",v1=" + mac(secret, unix, payload)
+}
+
+// Verify checks a signature header made by Sign, rejecting it if the
+// timestamp is more than tolerance away from now
+func Verify(secret, header string, payload []byte, tolerance time.Duration) error {
+	var unix, signature string
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+
+		switch key {
+		case "t":
+			unix = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil || signature == "" {
+		return ErrInvalidSignature
+	}
+
+	age := time.Since(time.Unix(seconds, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(mac(secret, unix, payload))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func mac(secret, unix string, payload []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(unix + "."))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
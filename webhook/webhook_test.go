@@ -0,0 +1,136 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	payload := []byte(`{"type":"event.created"}`)
+	now := time.Now()
+
+	header := Sign("secret", now, payload)
+	assert.Regexp(t, `^t=\d+,v1=[0-9a-f]{64}$`, header)
+
+	assert.NoError(t, Verify("secret", header, payload, 5*time.Minute))
+	assert.ErrorIs(t, Verify("other", header, payload, 5*time.Minute), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", header, []byte(`{"type":"event.deleted"}`), 5*time.Minute), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", "v1=abc", payload, 5*time.Minute), ErrInvalidSignature)
+
+	stale := Sign("secret", now.Add(-10*time.Minute), payload)
+	assert.ErrorIs(t, Verify("secret", stale, payload, 5*time.Minute), ErrInvalidSignature)
+}
+
+// newTestSender is NewSender's client, minus the address check so it can
+// reach test servers on loopback
+func newTestSender() *Sender {
+	return &Sender{Client: newClient(time.Second, func(net.IP) bool { return false })}
+}
+
+func TestSender_Send(t *testing.T) {
+	payload := []byte(`{"id":"abc","type":"registration.created"}`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "registration.created", r.Header.Get(HeaderEvent))
+		assert.Equal(t, "42", r.Header.Get(HeaderDelivery))
+		assert.Equal(t, payload, body)
+		assert.NoError(t, Verify("secret", r.Header.Get(HeaderSignature), body, time.Minute))
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	code, err := newTestSender().Send(context.Background(), Request{
+		URL:        server.URL,
+		Secret:     "secret",
+		Event:      "registration.created",
+		DeliveryID: 42,
+		Payload:    payload,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, code)
+}
+
+func TestSender_SendFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+
+	sender := newTestSender()
+
+	code, err := sender.Send(context.Background(), Request{URL: server.URL, Payload: []byte(`{}`)})
+	assert.ErrorContains(t, err, "503")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	server.Close()
+
+	code, err = sender.Send(context.Background(), Request{URL: server.URL, Payload: []byte(`{}`)})
+	assert.Error(t, err)
+	assert.Zero(t, code)
+}
+
+func TestSender_RefusesForbiddenAddresses(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	sender := NewSender(time.Second)
+
+	for _, url := range []string{
+		server.URL,
+		"http://localhost:" + strconv.Itoa(server.Listener.Addr().(*net.TCPAddr).Port),
+		"http://10.0.0.1/hooks",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hooks",
+		"http://0.0.0.0/hooks",
+	} {
+		code, err := sender.Send(context.Background(), Request{URL: url, Payload: []byte(`{}`)})
+		assert.ErrorIs(t, err, ErrForbiddenAddress, url)
+		assert.Zero(t, code)
+	}
+
+	assert.Zero(t, calls)
+}
+
+func TestSender_DoesNotFollowRedirects(t *testing.T) {
+	var followed bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	}))
+	defer target.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer server.Close()
+
+	code, err := newTestSender().Send(context.Background(), Request{URL: server.URL, Payload: []byte(`{}`)})
+	assert.ErrorContains(t, err, "302")
+	assert.Equal(t, http.StatusFound, code)
+	assert.False(t, followed)
+}
+
+func TestForbidden(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"fe80::1", "fc00::1", "0.0.0.0", "::", "::ffff:127.0.0.1", "224.0.0.1"} {
+		assert.True(t, Forbidden(net.ParseIP(ip)), ip)
+	}
+
+	for _, ip := range []string{"93.184.216.34", "2606:2800:220:1::1"} {
+		assert.False(t, Forbidden(net.ParseIP(ip)), ip)
+	}
+}